@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MountPointsAPIPath is the prefix of the admin API changing the monitored
+// mount points at runtime, see HandleMountPoints.
+const MountPointsAPIPath = "/api/v1/mount-points"
+
+// ReloadResult describes the changes applied by a configuration reload.
+type ReloadResult struct {
+	MountPointsDiff
+	// Changed lists settings applied at runtime.
+	Changed []string `json:"changed"`
+	// RequiresRestart lists changed settings that only take effect after a restart.
+	RequiresRestart []string `json:"requires_restart"`
+}
+
+type AdminHandlers struct {
+	watchdog *Watchdog
+	reload   func() (*ReloadResult, error)
+}
+
+func NewAdminHandlers(watchdog *Watchdog, reload func() (*ReloadResult, error)) *AdminHandlers {
+	return &AdminHandlers{watchdog: watchdog, reload: reload}
+}
+
+// HandleReload re-reads the configuration and answers with a JSON diff of what changed.
+func (s *AdminHandlers) HandleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := s.reload()
+	if err != nil {
+		slog.Error("config reload failed", "error", err.Error())
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+	slog.Info("config reloaded", "added", result.Added, "removed", result.Removed, "updated", result.Updated,
+		"changed", result.Changed, "requires_restart", result.RequiresRestart)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleHold freezes the reported health of the mount points of an NFS server
+// with POST ?server=nfs1&duration=10m, releases it with DELETE ?server=nfs1
+// and lists the active holds with GET.
+func (s *AdminHandlers) HandleHold(w http.ResponseWriter, r *http.Request) {
+	server := r.URL.Query().Get("server")
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.watchdog.ServerHolds(time.Now()))
+	case http.MethodPost:
+		if server == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing server"})
+			return
+		}
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 || duration > MaxServerHold {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("duration must be between 0 and %s", MaxServerHold)})
+			return
+		}
+		hold := s.watchdog.HoldServer(server, duration, time.Now())
+		slog.Info("holding reported health of NFS server", "server", server, "until", hold.Until.Format(time.RFC3339))
+		writeJSON(w, http.StatusOK, hold)
+	case http.MethodDelete:
+		if !s.watchdog.ReleaseServer(server) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no hold for server " + server})
+			return
+		}
+		slog.Info("released hold of NFS server", "server", server)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleMountPoints changes the monitored mount points at runtime:
+// POST /api/v1/mount-points with {"mount_point": "<--mount-point value>"} adds
+// one, DELETE /api/v1/mount-points/<path or alias> removes one, and
+// POST /api/v1/mount-points/<path or alias>/pause (or /resume) pauses or
+// resumes its checks. Added mount points last until a restart, removed
+// configured ones until the next reload.
+// The .../silence paths are served by HandleSilence.
+func (s *AdminHandlers) HandleMountPoints(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, MountPointsAPIPath), "/")
+	if strings.HasSuffix(rest, "/silence") {
+		s.HandleSilence(w, r)
+		return
+	}
+	if rest == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.addMountPoint(w, r)
+		return
+	}
+
+	target, action, hasAction := "", "", false
+	if i := strings.LastIndex(rest, "/"); i >= 0 && (rest[i+1:] == "pause" || rest[i+1:] == "resume") {
+		target, action, hasAction = rest[:i], rest[i+1:], true
+	} else {
+		target = rest
+	}
+	path, ok := s.lookupMountPoint(target)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown mount point " + target})
+		return
+	}
+
+	switch {
+	case hasAction && r.Method == http.MethodPost:
+		paused := action == "pause"
+		if err := s.watchdog.SetMountPaused(path, paused); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		slog.Info(action+"d checks of mount point", "mountpoint", path)
+		writeJSON(w, http.StatusOK, map[string]any{"mount_point": path, "paused": paused})
+	case !hasAction && r.Method == http.MethodDelete:
+		diff, err := s.watchdog.RemoveMountPoint(path)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		slog.Info("removed mount point at runtime", "mountpoint", path)
+		writeJSON(w, http.StatusOK, diff)
+	case hasAction:
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		w.Header().Set("Allow", http.MethodDelete)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *AdminHandlers) addMountPoint(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MountPoint string `json:"mount_point"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MountPoint == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body must be {\"mount_point\": \"PATH[=ALIAS][?key=value&...]\"}"})
+		return
+	}
+	mp, err := ParseMountPoint(body.MountPoint)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	mp.Origin = OriginRuntime
+	diff, err := s.watchdog.AddMountPoint(mp)
+	switch {
+	case errors.Is(err, ErrMountPointConflict):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDraining):
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+	slog.Info("added mount point at runtime", "mountpoint", mp.Path)
+	writeJSON(w, http.StatusCreated, diff)
+}
+
+// HandleSilence puts a mount point into a maintenance window with
+// POST /api/v1/mount-points/<path or alias>/silence?duration=1h and ends it
+// with DELETE on the same path. Silences last until they expire, a restart or
+// the removal of the mount point.
+func (s *AdminHandlers) HandleSilence(w http.ResponseWriter, r *http.Request) {
+	target, ok := strings.CutSuffix(strings.Trim(strings.TrimPrefix(r.URL.Path, MountPointsAPIPath), "/"), "/silence")
+	if !ok || target == "" {
+		http.NotFound(w, r)
+		return
+	}
+	path, ok := s.lookupMountPoint(target)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown mount point " + target})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 || duration > MaxMountSilence {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("duration must be between 0 and %s", MaxMountSilence)})
+			return
+		}
+		silence, err := s.watchdog.SilenceMount(path, duration, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		slog.Info("silenced mount point", "mountpoint", path, "until", silence.Until.Format(time.RFC3339))
+		writeJSON(w, http.StatusOK, silence)
+	case http.MethodDelete:
+		found, err := s.watchdog.UnsilenceMount(path, time.Now())
+		if err != nil || !found {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no silence for mount point " + path})
+			return
+		}
+		slog.Info("ended silence of mount point", "mountpoint", path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// lookupMountPoint resolves a path without its leading slash, or an alias.
+func (s *AdminHandlers) lookupMountPoint(target string) (string, bool) {
+	if path := "/" + target; s.watchdog.isMonitored(path) {
+		return path, true
+	}
+	return s.watchdog.LookupAlias(target)
+}
+
+// HandleMounts answers with the mount table entries the last check of each
+// mount point matched, including the raw lines, as JSON.
+func (s *AdminHandlers) HandleMounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.watchdog.MountLookups())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleReload(t *testing.T) {
+	h := NewAdminHandlers(nil, func() (*ReloadResult, error) {
+		return &ReloadResult{
+			MountPointsDiff: MountPointsDiff{Added: []string{"/mnt/new"}, Removed: []string{}, Updated: []string{}},
+			Changed:         []string{"check_interval"},
+			RequiresRestart: []string{"listen_address"},
+		}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.HandleReload(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var body map[string][]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+	}
+	if len(body["added"]) != 1 || body["added"][0] != "/mnt/new" {
+		t.Errorf("unexpected added %v", body["added"])
+	}
+	if len(body["requires_restart"]) != 1 || body["requires_restart"][0] != "listen_address" {
+		t.Errorf("unexpected requires_restart %v", body["requires_restart"])
+	}
+}
+
+func TestHandleReloadErrors(t *testing.T) {
+	h := NewAdminHandlers(nil, func() (*ReloadResult, error) {
+		return nil, errors.New("broken config")
+	})
+
+	rec := httptest.NewRecorder()
+	h.HandleReload(rec, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d for GET, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleReload(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d for failed reload, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestHandleMounts(t *testing.T) {
+	nfs, local := t.TempDir(), t.TempDir()
+	mountsFile := writeMountsFixture(t, "tmpfs "+nfs+" tmpfs rw 0 0\n"+
+		"server:/export "+nfs+" nfs4 rw,hard 0 0\n"+
+		"tmpfs "+local+" tmpfs rw 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(nfs, local, "/mnt/missing"), WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    mountsFile,
+	})
+	w.CheckAll()
+	h := NewAdminHandlers(w, nil)
+
+	rec := httptest.NewRecorder()
+	h.HandleMounts(rec, httptest.NewRequest(http.MethodGet, "/admin/mounts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var lookups []MountLookup
+	if err := json.Unmarshal(rec.Body.Bytes(), &lookups); err != nil {
+		t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+	}
+	if len(lookups) != 3 {
+		t.Fatalf("expected 3 lookups, got %+v", lookups)
+	}
+
+	// Sorted by path: /mnt/missing comes before the temporary directories.
+	if l := lookups[1]; !l.Matched || !l.NFS || l.Entry.Line != "server:/export "+nfs+" nfs4 rw,hard 0 0" ||
+		len(l.Shadowed) != 1 || l.Shadowed[0].FSType != "tmpfs" {
+		t.Errorf("unexpected lookup of the shadowing NFS mount: %+v", l)
+	}
+	if l := lookups[2]; !l.Matched || l.NFS || l.Entry.FSType != "tmpfs" {
+		t.Errorf("unexpected lookup of the local mount: %+v", l)
+	}
+	if l := lookups[0]; l.Matched || l.Entry != nil || l.Error == "" || l.MountsFile != mountsFile {
+		t.Errorf("unexpected lookup of the missing mount: %+v", l)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleMounts(rec, httptest.NewRequest(http.MethodPost, "/admin/mounts", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d for POST, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandleHold(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second})
+	h := NewAdminHandlers(w, nil)
+
+	tests := []struct {
+		method, target string
+		wantStatus     int
+	}{
+		{http.MethodPost, "/admin/hold?duration=10m", http.StatusBadRequest},
+		{http.MethodPost, "/admin/hold?server=nfs1", http.StatusBadRequest},
+		{http.MethodPost, "/admin/hold?server=nfs1&duration=48h", http.StatusBadRequest},
+		{http.MethodPost, "/admin/hold?server=nfs1&duration=10m", http.StatusOK},
+		{http.MethodGet, "/admin/hold", http.StatusOK},
+		{http.MethodDelete, "/admin/hold?server=nfs1", http.StatusNoContent},
+		{http.MethodDelete, "/admin/hold?server=nfs1", http.StatusNotFound},
+		{http.MethodPut, "/admin/hold?server=nfs1", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.HandleHold(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestHandleMountPoints(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{CheckInterval: time.Second})
+	h := NewAdminHandlers(w, nil)
+
+	tests := []struct {
+		method, target, body string
+		wantStatus           int
+	}{
+		{http.MethodPost, "/api/v1/mount-points", `{"mount_point": "/mnt/b=bee?optional"}`, http.StatusCreated},
+		{http.MethodPost, "/api/v1/mount-points", `{"mount_point": "/mnt/c=bee"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/mount-points", `{"mount_point": "relative"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/mount-points", `not json`, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/mount-points", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/mount-points/bee/pause", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/mount-points/mnt/a/pause", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/mount-points/mnt/a/resume", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/mount-points/mnt/a/pause", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/mount-points/mnt/unknown/pause", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/mount-points/mnt/a/silence?duration=1h", "", http.StatusOK},
+		{http.MethodDelete, "/api/v1/mount-points/mnt/a/silence", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/mount-points/bee", "", http.StatusOK},
+		{http.MethodDelete, "/api/v1/mount-points/bee", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.HandleMountPoints(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d (%s)", tt.method, tt.target, tt.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+	if got := mountPointPaths(w.MountPoints()); len(got) != 1 || got[0] != "/mnt/a" {
+		t.Errorf("expected only /mnt/a to remain, got %v", got)
+	}
+	if w.IsMountPaused("/mnt/a") {
+		t.Error("expected /mnt/a to be resumed")
+	}
+}
+
+func TestHandleSilence(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{{Path: "/mnt/a", Alias: "a"}}, WatchdogOptions{CheckInterval: time.Second})
+	h := NewAdminHandlers(w, nil)
+
+	tests := []struct {
+		method, target string
+		wantStatus     int
+	}{
+		{http.MethodPost, "/api/v1/mount-points/mnt/a/silence", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/mount-points/mnt/a/silence?duration=720h", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/mount-points/mnt/unknown/silence?duration=1h", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/mount-points/mnt/a", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/mount-points/a/silence?duration=1h", http.StatusOK},
+		{http.MethodGet, "/api/v1/mount-points/mnt/a/silence", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/v1/mount-points/mnt/a/silence", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/mount-points/mnt/a/silence", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.HandleSilence(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d (%s)", tt.method, tt.target, tt.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+}
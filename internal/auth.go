@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Endpoint groups protected by an Authenticator.
+const (
+	AuthGroupMetrics = "metrics" // telemetry path and probe
+	AuthGroupHealth  = "health"  // health, per-mount health and readiness
+	AuthGroupStatus  = "status"  // status snapshot and events stream
+)
+
+// AuthGroups lists the endpoint groups, protected by default.
+var AuthGroups = []string{AuthGroupMetrics, AuthGroupHealth, AuthGroupStatus}
+
+// Authenticator accepts requests with basic auth credentials from an
+// htpasswd-style file or with a static bearer token.
+type Authenticator struct {
+	users map[string][]byte // user name to bcrypt hash
+	token string
+	// verified caches successful bcrypt comparisons, as a bcrypt comparison
+	// takes tens of milliseconds and probes and scrapes repeat the same ones.
+	verified sync.Map
+}
+
+// fakeHash is compared against for unknown users, so a request takes as long
+// as for a known user and does not reveal which user names exist.
+var fakeHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("nfs_mounter_agent"), bcrypt.DefaultCost)
+	return hash
+})
+
+// NewAuthenticator reads the basic auth users from basicFile, one
+// "user:bcrypt-hash" line each as written by `htpasswd -nB user`, and accepts
+// token as bearer token. Either may be empty, not both.
+func NewAuthenticator(basicFile, token string) (*Authenticator, error) {
+	if basicFile == "" && token == "" {
+		return nil, errors.New("no basic auth file and no bearer token")
+	}
+	a := &Authenticator{users: make(map[string][]byte), token: token}
+	if basicFile == "" {
+		return a, nil
+	}
+
+	f, err := os.Open(basicFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(text, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:bcrypt-hash", basicFile, line)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s:%d: password of user %q is not a bcrypt hash: %w", basicFile, line, user, err)
+		}
+		a.users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(a.users) == 0 {
+		return nil, fmt.Errorf("%s: no users", basicFile)
+	}
+	return a, nil
+}
+
+// Require rejects requests that carry neither valid basic auth credentials
+// nor the bearer token with 401.
+func (a *Authenticator) Require(h http.Handler) http.Handler {
+	challenge := "Bearer"
+	if len(a.users) > 0 {
+		challenge = `Basic realm="nfs_mounter_agent"`
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authenticated(r) {
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) authenticated(r *http.Request) bool {
+	if a.token != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.token)) == 1 {
+			return true
+		}
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok || len(a.users) == 0 {
+		return false
+	}
+	hash, known := a.users[user]
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(fakeHash(), []byte(password))
+		return false
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + string(hash)))
+	if _, ok := a.verified.Load(key); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	a.verified.Store(key, struct{}{})
+	return true
+}
+
+// ParseAuthGroups parses a comma-separated list of endpoint groups.
+func ParseAuthGroups(value string) (map[string]bool, error) {
+	groups := make(map[string]bool)
+	for _, group := range splitList(value) {
+		if !slices.Contains(AuthGroups, group) {
+			return nil, fmt.Errorf("unknown endpoint group %q, expected %s", group, strings.Join(AuthGroups, ", "))
+		}
+		groups[group] = true
+	}
+	return groups, nil
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := writeFile(t, t.TempDir(), "users", []byte("# scrapers\nprometheus:"+string(hash)+"\n\n"))
+	a, err := NewAuthenticator(file, "token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := a.Require(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		setAuth    func(r *http.Request)
+		wantStatus int
+	}{
+		{"no credentials", func(*http.Request) {}, http.StatusUnauthorized},
+		{"valid basic auth", func(r *http.Request) { r.SetBasicAuth("prometheus", "secret") }, http.StatusOK},
+		{"cached basic auth", func(r *http.Request) { r.SetBasicAuth("prometheus", "secret") }, http.StatusOK},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("prometheus", "guess") }, http.StatusUnauthorized},
+		{"unknown user", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusUnauthorized},
+		{"valid token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		tt.setAuth(req)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, rec.Code)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != `Basic realm="nfs_mounter_agent"` {
+			t.Errorf("%s: unexpected challenge %q", tt.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"plain":   "prometheus:secret\n",
+		"no-hash": "prometheus\n",
+		"empty":   "# nobody\n",
+	} {
+		if _, err := NewAuthenticator(writeFile(t, dir, name, []byte(content)), ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := NewAuthenticator(filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := NewAuthenticator("", ""); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func TestParseAuthGroups(t *testing.T) {
+	groups, err := ParseAuthGroups("metrics, status")
+	if err != nil || !groups[AuthGroupMetrics] || !groups[AuthGroupStatus] || groups[AuthGroupHealth] {
+		t.Errorf("unexpected groups %v, error %v", groups, err)
+	}
+	if _, err := ParseAuthGroups("metrics,admin"); err == nil {
+		t.Error("expected an error for an unknown group")
+	}
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type availabilitySample struct {
+	at time.Time
+	ok bool
+}
+
+// availabilityHistory keeps the check results of a mount point observed within
+// the longest of several sliding time windows.
+type availabilityHistory struct {
+	windows []time.Duration
+	samples []availabilitySample
+}
+
+// add records a check result observed at now, prunes samples older than the
+// longest window and returns the share of passed checks in each window.
+func (w *availabilityHistory) add(now time.Time, ok bool) []float64 {
+	w.samples = append(w.samples, availabilitySample{at: now, ok: ok})
+
+	longest := w.windows[0]
+	for _, window := range w.windows {
+		longest = max(longest, window)
+	}
+	cutoff := now.Add(-longest)
+	keep := 0
+	for keep < len(w.samples) && !w.samples[keep].at.After(cutoff) {
+		keep++
+	}
+	w.samples = w.samples[keep:]
+
+	ratios := make([]float64, len(w.windows))
+	for i, window := range w.windows {
+		cutoff := now.Add(-window)
+		var total, passed int
+		for _, s := range w.samples {
+			if s.at.After(cutoff) {
+				total++
+				if s.ok {
+					passed++
+				}
+			}
+		}
+		// The sample added above is always in the window.
+		ratios[i] = float64(passed) / float64(total)
+	}
+	return ratios
+}
+
+// windowLabel formats a window for the window label without trailing zero
+// units: 5m, 1h, 1h30m, 90s is 1m30s.
+func windowLabel(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// ParseWindows parses a comma-separated list of positive durations, e.g.
+// "5m,1h", rejecting duplicates.
+func ParseWindows(value string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, item := range splitList(value) {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("window must be positive: %s", item)
+		}
+		for _, seen := range windows {
+			if seen == d {
+				return nil, fmt.Errorf("duplicate window %s", item)
+			}
+		}
+		windows = append(windows, d)
+	}
+	return windows, nil
+}
+
+// observeAvailability records the result of a check of mp and exports the
+// share of passed checks in every availability window.
+func (m *Watchdog) observeAvailability(mp MountPoint, at time.Time, ok bool) {
+	if len(m.availabilityWindows) == 0 {
+		return
+	}
+	m.mu.Lock()
+	windows, found := m.availability[mp.Path]
+	if !found {
+		windows = &availabilityHistory{windows: m.availabilityWindows}
+		m.availability[mp.Path] = windows
+	}
+	ratios := windows.add(at, ok)
+	m.mu.Unlock()
+
+	for i, window := range m.availabilityWindows {
+		m.nfsAvailability.WithLabelValues(m.labels.values(mp, windowLabel(window))...).Set(ratios[i])
+	}
+}
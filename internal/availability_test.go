@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAvailabilityWindows(t *testing.T) {
+	w := &availabilityHistory{windows: []time.Duration{5 * time.Minute, time.Hour}}
+	start := time.Unix(1000, 0)
+
+	w.add(start, false)
+	w.add(start.Add(10*time.Minute), true)
+	ratios := w.add(start.Add(11*time.Minute), true)
+	if ratios[0] != 1 || ratios[1] != 2.0/3 {
+		t.Errorf("expected 1 in the 5m window and 2/3 in the 1h window, got %v", ratios)
+	}
+
+	ratios = w.add(start.Add(time.Hour), false)
+	if ratios[0] != 0 || ratios[1] != 2.0/3 {
+		t.Errorf("expected the first failure to expire from the 1h window, got %v", ratios)
+	}
+	if len(w.samples) != 3 {
+		t.Errorf("expected expired samples to be pruned, got %d samples", len(w.samples))
+	}
+}
+
+func TestWindowLabel(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		5 * time.Minute:  "5m",
+		time.Hour:        "1h",
+		90 * time.Minute: "1h30m",
+		30 * time.Second: "30s",
+		90 * time.Second: "1m30s",
+	} {
+		if got := windowLabel(d); got != want {
+			t.Errorf("windowLabel(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows("5m, 1h")
+	if err != nil || len(windows) != 2 || windows[1] != time.Hour {
+		t.Errorf("unexpected windows %v, error %v", windows, err)
+	}
+	for _, value := range []string{"5m,5m", "0s", "soon"} {
+		if _, err := ParseWindows(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestAvailabilityMetric(t *testing.T) {
+	mp := MountPoint{Path: "/this/path/should/not/exist/for_nfs_watchdog_test"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:       time.Second,
+		AvailabilityWindows: []time.Duration{5 * time.Minute},
+	})
+	w.CheckMountPoint(mp)
+	if got := testutil.ToFloat64(w.nfsAvailability.WithLabelValues(mp.Path, mp.Path, "5m")); got != 0 {
+		t.Errorf("expected availability 0 after a failed check, got %v", got)
+	}
+}
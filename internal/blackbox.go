@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeTimeoutOffset is left of the scrape timeout Prometheus announces, so
+// the probe answers before the scrape gives up, as the blackbox exporter does.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// ProbeHandler runs a live check of an arbitrary path per request, e.g.
+// GET /probe?target=/var/vcap/store/foo, and answers with probe metrics in the
+// style of the blackbox exporter. Nothing is recorded in the watchdog state.
+type ProbeHandler struct {
+	namespace string
+	timeout   time.Duration
+	check     func(target string) (fsStat, error)
+
+	mu      sync.Mutex
+	running map[string]*probeRun
+}
+
+// probeRun is a probe of a target in flight, shared by the requests waiting
+// for it.
+type probeRun struct {
+	done chan struct{}
+	stat fsStat
+	err  error
+}
+
+// NewProbeHandler returns a probe handler waiting at most timeout for a check,
+// less when the scrape timeout of Prometheus is shorter.
+func NewProbeHandler(namespace string, watchdog *Watchdog, timeout time.Duration) *ProbeHandler {
+	return &ProbeHandler{
+		namespace: namespace,
+		timeout:   timeout,
+		check:     watchdog.probeTarget,
+		running:   make(map[string]*probeRun),
+	}
+}
+
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(target) {
+		http.Error(w, fmt.Sprintf("target %q is not an absolute path", target), http.StatusBadRequest)
+		return
+	}
+	target = filepath.Clean(target)
+
+	timeout := h.timeout
+	if value := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			http.Error(w, fmt.Sprintf("invalid X-Prometheus-Scrape-Timeout-Seconds %q", value), http.StatusBadRequest)
+			return
+		}
+		if scrape := time.Duration(seconds*float64(time.Second)) - scrapeTimeoutOffset; scrape > 0 {
+			timeout = min(timeout, scrape)
+		}
+	}
+
+	start := time.Now()
+	stat, err := h.probe(target, timeout)
+	duration := time.Since(start)
+
+	registry := prometheus.NewRegistry()
+	gauge := func(name, help string, value float64) {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		g.Set(value)
+		registry.MustRegister(g)
+	}
+	gauge("probe_duration_seconds", "Duration of the probe of the target", duration.Seconds())
+	if err != nil {
+		gauge("probe_success", "1 if the target passed the check, 0 otherwise", 0)
+		info := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: h.namespace,
+			Name:      "probe_error_info",
+			Help:      "1 with the error reason of a failed probe",
+		}, []string{"reason"})
+		info.WithLabelValues(errorReason(err)).Set(1)
+		registry.MustRegister(info)
+	} else {
+		gauge("probe_success", "1 if the target passed the check, 0 otherwise", 1)
+		if stat.sizeBytes > 0 {
+			gauge(prometheus.BuildFQName(h.namespace, "probe", "size_bytes"), "Size of the filesystem of the target in bytes", float64(stat.sizeBytes))
+			gauge(prometheus.BuildFQName(h.namespace, "probe", "free_bytes"), "Bytes available to unprivileged users on the filesystem of the target", float64(stat.availBytes))
+			gauge(prometheus.BuildFQName(h.namespace, "probe", "files_free"), "Free inodes on the filesystem of the target", float64(stat.filesFree))
+		}
+	}
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probe checks target and waits at most timeout for the result. A probe of
+// the same target still running, e.g. hung on an unresponsive server, is
+// waited for again instead of started anew, so a hung mount costs one
+// goroutine, not one per request.
+func (h *ProbeHandler) probe(target string, timeout time.Duration) (fsStat, error) {
+	h.mu.Lock()
+	run, running := h.running[target]
+	if !running {
+		run = &probeRun{done: make(chan struct{})}
+		h.running[target] = run
+		go func() {
+			run.stat, run.err = h.check(target)
+			h.mu.Lock()
+			delete(h.running, target)
+			h.mu.Unlock()
+			close(run.done)
+		}()
+	}
+	h.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-run.done:
+		return run.stat, run.err
+	case <-timer.C:
+		if running {
+			return fsStat{}, fmt.Errorf("%w: %s is still not responding after an earlier probe timed out", errCheckTimeout, target)
+		}
+		return fsStat{}, fmt.Errorf("%w: %s did not respond within %s", errCheckTimeout, target, timeout)
+	}
+}
+
+// probeTarget checks the filesystem containing target against a fresh read of
+// the mount table: a monitored mount point with its settings, any other path
+// as a directory on the NFS mount containing it. Unlike a check, it records
+// no state or metrics.
+func (m *Watchdog) probeTarget(target string) (fsStat, error) {
+	table := readMountTable(m.mounts)
+	mp := m.mountPoint(target)
+	if !m.isMonitored(target) {
+		entry, err := table.enclosing(target)
+		if err != nil {
+			return fsStat{}, fmt.Errorf("checking %s failed: %w", table.file, err)
+		}
+		rel, err := filepath.Rel(entry.MountPoint, target)
+		if err != nil {
+			return fsStat{}, err
+		}
+		mp = MountPoint{Path: entry.MountPoint}
+		if rel != "." {
+			mp.CheckSubpath = rel
+		}
+	}
+
+	if mp.Absent {
+		return fsStat{}, m.checkAbsent(mp.Path, table)
+	}
+	if _, err := m.checkPresent(mp, table); err != nil {
+		return fsStat{}, err
+	}
+	if err := checkSubpath(mp); err != nil {
+		return fsStat{}, err
+	}
+	st, err := statfs(mp.CheckDir())
+	switch {
+	case errors.Is(err, errStatfsUnsupported):
+		return fsStat{}, nil
+	case err != nil:
+		return fsStat{}, classified(reasonStatFailed, fmt.Errorf("statfs(%s) failed: %w", mp.CheckDir(), err))
+	}
+	return st, nil
+}
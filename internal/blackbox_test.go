@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serveProbe(h *ProbeHandler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/probe?target="+target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProbeHandler(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "foo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mountsFile := writeMountsFixture(t, "nfs1:/export "+root+" nfs4 rw 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	h := NewProbeHandler("test_ns", w, time.Second)
+
+	rec := serveProbe(h, filepath.Join(root, "foo"), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "\nprobe_success 1\n") {
+		t.Fatalf("expected a directory on an NFS mount to pass, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "test_ns_probe_size_bytes ") {
+		t.Errorf("expected the filesystem size of the target, got %s", rec.Body.String())
+	}
+
+	for target, reason := range map[string]string{
+		filepath.Join(root, "missing"): reasonSubpathMissing,
+		t.TempDir():                    reasonNotInMountTable,
+	} {
+		body := serveProbe(h, target, nil).Body.String()
+		if !strings.Contains(body, "\nprobe_success 0\n") || !strings.Contains(body, `test_ns_probe_error_info{reason="`+reason+`"} 1`) {
+			t.Errorf("%s: expected a failed probe with reason %s, got %s", target, reason, body)
+		}
+	}
+
+	// Probes leave the watchdog alone.
+	if len(w.Status()) != 0 || len(w.MountLookups()) != 0 {
+		t.Errorf("expected no watchdog state from probes, got %+v", w.Status())
+	}
+
+	for _, target := range []string{"", "relative/path"} {
+		if rec := serveProbe(h, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("target %q: expected 400, got %d", target, rec.Code)
+		}
+	}
+	if rec := serveProbe(h, root, http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"soon"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid scrape timeout, got %d", rec.Code)
+	}
+}
+
+func TestProbeHandlerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	h := &ProbeHandler{
+		namespace: "test_ns",
+		timeout:   time.Minute,
+		check: func(string) (fsStat, error) {
+			calls.Add(1)
+			<-release
+			return fsStat{}, nil
+		},
+		running: make(map[string]*probeRun),
+	}
+	defer close(release)
+
+	// The scrape timeout of Prometheus, less the offset, bounds the wait.
+	scrape := http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"0.6"}}
+	start := time.Now()
+	body := serveProbe(h, "/mnt/hung", scrape).Body.String()
+	if time.Since(start) > 10*time.Second || !strings.Contains(body, `test_ns_probe_error_info{reason="timeout"} 1`) {
+		t.Fatalf("expected a timeout within the scrape timeout, got %s", body)
+	}
+
+	// The hung probe is waited for again rather than started anew.
+	serveProbe(h, "/mnt/hung", scrape)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected one probe of the hung target, got %d", got)
+	}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ParseBuckets parses comma-separated histogram bucket upper bounds in
+// seconds, e.g. "0.01,0.1,1", which must be positive and increasing.
+func ParseBuckets(value string) ([]float64, error) {
+	var buckets []float64
+	for _, item := range splitList(value) {
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q", item)
+		}
+		if bound <= 0 {
+			return nil, fmt.Errorf("bucket must be positive: %s", item)
+		}
+		if n := len(buckets); n > 0 && bound <= buckets[n-1] {
+			return nil, fmt.Errorf("buckets must be increasing: %s after %g", item, buckets[n-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no buckets in %q", value)
+	}
+	return buckets, nil
+}
+
+// bucketsOrDefault returns buckets, or the Prometheus default buckets when
+// none are configured.
+func bucketsOrDefault(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		return prometheus.DefBuckets
+	}
+	return buckets
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets("0.01, 0.1,1")
+	if err != nil || len(buckets) != 3 || buckets[2] != 1 {
+		t.Errorf("unexpected buckets %v, error %v", buckets, err)
+	}
+	for _, value := range []string{"", "0.1,0.1", "1,0.5", "-1", "fast"} {
+		if _, err := ParseBuckets(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestCheckDurationHistogram(t *testing.T) {
+	mp := MountPoint{Path: "/this/path/should/not/exist/for_nfs_watchdog_test"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:        time.Second,
+		CheckDurationBuckets: []float64{0.5, 1},
+	})
+	w.CheckMountPoint(mp)
+	w.CheckMountPoint(mp)
+
+	expected := `
+# HELP test_ns_check_duration_seconds Duration of the full check of a mount point, including the write and lock tests
+# TYPE test_ns_check_duration_seconds histogram
+test_ns_check_duration_seconds_bucket{mountpoint="` + mp.Path + `",name="` + mp.Path + `",le="0.5"} 2
+test_ns_check_duration_seconds_bucket{mountpoint="` + mp.Path + `",name="` + mp.Path + `",le="1"} 2
+test_ns_check_duration_seconds_bucket{mountpoint="` + mp.Path + `",name="` + mp.Path + `",le="+Inf"} 2
+`
+	if err := testutil.CollectAndCompare(w.nfsCheckDuration, strings.NewReader(expected), "test_ns_check_duration_seconds_bucket"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTestDurationBuckets(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mp := MountPoint{Path: "/mnt/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		Registerer:       reg,
+		CheckInterval:    time.Second,
+		EnableWriteTest:  true,
+		EnableLockTest:   true,
+		WriteTestBuckets: []float64{0.001, 0.05},
+		LockTestBuckets:  []float64{0.002},
+	})
+	w.nfsWriteTestDuration.WithLabelValues(mp.Path, mp.Path).Observe(0.01)
+	w.nfsLockTestDuration.WithLabelValues(mp.Path, mp.Path).Observe(0.01)
+
+	expected := `
+# HELP test_ns_write_test_duration_seconds Duration of NFS mount write test
+# TYPE test_ns_write_test_duration_seconds histogram
+test_ns_write_test_duration_seconds_bucket{mountpoint="/mnt/a",name="/mnt/a",le="0.001"} 0
+test_ns_write_test_duration_seconds_bucket{mountpoint="/mnt/a",name="/mnt/a",le="0.05"} 1
+test_ns_write_test_duration_seconds_bucket{mountpoint="/mnt/a",name="/mnt/a",le="+Inf"} 1
+test_ns_write_test_duration_seconds_sum{mountpoint="/mnt/a",name="/mnt/a"} 0.01
+test_ns_write_test_duration_seconds_count{mountpoint="/mnt/a",name="/mnt/a"} 1
+# HELP test_ns_lock_test_duration_seconds Duration of taking and releasing a POSIX lock on the NFS mount
+# TYPE test_ns_lock_test_duration_seconds histogram
+test_ns_lock_test_duration_seconds_bucket{mountpoint="/mnt/a",name="/mnt/a",le="0.002"} 0
+test_ns_lock_test_duration_seconds_bucket{mountpoint="/mnt/a",name="/mnt/a",le="+Inf"} 1
+test_ns_lock_test_duration_seconds_sum{mountpoint="/mnt/a",name="/mnt/a"} 0.01
+test_ns_lock_test_duration_seconds_count{mountpoint="/mnt/a",name="/mnt/a"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "test_ns_write_test_duration_seconds", "test_ns_lock_test_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// errCheckTimeout marks a check that did not finish within the check timeout.
+var errCheckTimeout = errors.New("timeout")
+
+// statDependency stats the depends-on path of a mount point, replaceable in
+// tests.
+var statDependency = os.Stat
+
+// checkWithTimeout runs the checks of a mount point in a goroutine and gives up
+// after checkTimeout, as stat, statfs and the write test block indefinitely on
+// a hard mount whose server is gone. A check still hanging from an earlier
+// cycle is waited for again instead of started anew, so a hung mount costs one
+// goroutine, not one per cycle.
+func (m *Watchdog) checkWithTimeout(mp MountPoint, table *mountTable) error {
+	return m.withTimeout(mp.Path, mp.Path, func() error { return m.check(mp, table) })
+}
+
+// statDependencyWithTimeout stats the depends-on path of mp like
+// checkWithTimeout, as the path may be on a parent NFS mount that hangs too.
+func (m *Watchdog) statDependencyWithTimeout(mp MountPoint) error {
+	return m.withTimeout("depends-on:"+mp.Path, mp.DependsOn, func() error {
+		_, err := statDependency(mp.DependsOn)
+		return err
+	})
+}
+
+// withTimeout runs fn, bounded by checkTimeout, for the checkWithTimeout and
+// statDependencyWithTimeout of the mount point keyed key; target names what
+// did not respond.
+func (m *Watchdog) withTimeout(key, target string, fn func() error) error {
+	if m.checkTimeout <= 0 {
+		return fn()
+	}
+
+	m.mu.Lock()
+	done, running := m.running[key]
+	if !running {
+		done = make(chan error, 1)
+		m.running[key] = done
+		go func() {
+			err := fn()
+			m.mu.Lock()
+			delete(m.running, key)
+			m.mu.Unlock()
+			done <- err
+		}()
+	}
+	m.mu.Unlock()
+
+	timer := time.NewTimer(m.checkTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		if running {
+			return fmt.Errorf("%w: %s is still not responding after an earlier check timed out", errCheckTimeout, target)
+		}
+		return fmt.Errorf("%w: %s did not respond within %s", errCheckTimeout, target, m.checkTimeout)
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCheckTimeoutReportsHungCheck(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{CheckTimeout: 20 * time.Millisecond})
+	release := make(chan struct{})
+	var started atomic.Int32
+	w.check = func(MountPoint, *mountTable) error {
+		started.Add(1)
+		<-release
+		return nil
+	}
+
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy("/mnt/a"); healthy {
+		t.Errorf("expected a hung check to be unhealthy")
+	}
+
+	// A second cycle waits for the same hung check.
+	w.CheckAll()
+	if errText := w.Status()[0].Error; !strings.HasPrefix(errText, "timeout: ") {
+		t.Errorf("expected a timeout error, got %q", errText)
+	}
+	if got := started.Load(); got != 1 {
+		t.Errorf("expected the hung check not to be started again, started %d times", got)
+	}
+
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues("/mnt/a", "/mnt/a", "", "", "timeout", "timeout")); got != 2 {
+		t.Errorf("expected 2 timed out checks, got %v", got)
+	}
+
+	// Once the hung check returns, its result is picked up by the next cycle.
+	close(release)
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy("/mnt/a"); !healthy {
+		t.Errorf("expected the mount point to be healthy once the check returned")
+	}
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues("/mnt/a", "/mnt/a", "", "", "ok", "")); got != 1 {
+		t.Errorf("expected 1 ok check, got %v", got)
+	}
+}
+
+func TestCheckTimeoutDisabled(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{})
+	w.check = func(MountPoint, *mountTable) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy("/mnt/a"); !healthy {
+		t.Errorf("expected a slow check to succeed without a timeout")
+	}
+}
+
+func TestCheckTimeoutBoundsDependencyStat(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	original := statDependency
+	statDependency = func(string) (os.FileInfo, error) {
+		<-release
+		return nil, nil
+	}
+	t.Cleanup(func() { statDependency = original })
+
+	points := []MountPoint{{Path: "/mnt/child", DependsOn: "/mnt/parent/ready"}, {Path: "/mnt/other"}}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{CheckTimeout: 20 * time.Millisecond})
+	w.check = func(MountPoint, *mountTable) error { return nil }
+
+	finished := make(chan struct{})
+	go func() {
+		w.CheckAll()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the check cycle to finish although the dependency stat hangs")
+	}
+	if !w.IsMountPending("/mnt/child") {
+		t.Error("expected the mount point with the hanging dependency to be pending")
+	}
+	if healthy, _ := w.IsMountHealthy("/mnt/other"); !healthy {
+		t.Error("expected the other mount point to be checked")
+	}
+}
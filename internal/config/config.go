@@ -0,0 +1,246 @@
+// Package config loads the agent configuration from YAML or JSON files.
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"nfs_mounter_agent/internal"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Config is the content of a configuration file. Zero values mean "not set".
+type Config struct {
+	ListenAddress      string        `yaml:"listen_address"`
+	TelemetryPath      string        `yaml:"telemetry_path"`
+	TelemetryNamespace string        `yaml:"telemetry_namespace"`
+	CheckInterval      time.Duration `yaml:"check_interval"`
+	FailureThreshold   int           `yaml:"failure_threshold"`
+	SuccessThreshold   int           `yaml:"success_threshold"`
+	SpaceWarnPercent   float64       `yaml:"space_warn_percent"`
+	MountBackend       string        `yaml:"mount_backend"`
+	MountPoints        []MountPoint  `yaml:"mount_points"`
+}
+
+// MountPoint is a mount point entry of a configuration file.
+type MountPoint struct {
+	Path           string            `yaml:"path"`
+	Alias          string            `yaml:"alias"`
+	Optional       bool              `yaml:"optional"`
+	Critical       *bool             `yaml:"critical"`
+	Silenced       bool              `yaml:"silenced"`
+	CheckInterval  time.Duration     `yaml:"check_interval"`
+	Absent         bool              `yaml:"absent"`
+	EnclosingMount bool              `yaml:"enclosing_mount"`
+	CheckSubpath   string            `yaml:"check_subpath"`
+	DependsOn      string            `yaml:"depends_on"`
+	WriteTest      *bool             `yaml:"write_test"`
+	WriteTestDir   string            `yaml:"write_test_dir"`
+	WriteTestSize  ByteSize          `yaml:"write_test_size"`
+	WriteFsync     *bool             `yaml:"write_fsync"`
+	AllowedServers CIDRs             `yaml:"allowed_server_cidr"`
+	RequireOptions []string          `yaml:"require_options"`
+	ExpectOptions  []string          `yaml:"expect_options"`
+	RemountSource  string            `yaml:"remount_source"`
+	RemountOptions []string          `yaml:"remount_options"`
+	OnUnhealthy    string            `yaml:"on_unhealthy"`
+	OnHealthy      string            `yaml:"on_healthy"`
+	FSTypes        []string          `yaml:"fstype"`
+	Tags           map[string]string `yaml:"tags"`
+}
+
+// CIDRs is a list of network prefixes, parsed when the config is loaded.
+type CIDRs []netip.Prefix
+
+func (c *CIDRs) UnmarshalYAML(unmarshal func(any) error) error {
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	prefixes, err := internal.ParseCIDRs(values)
+	if err != nil {
+		return err
+	}
+	*c = prefixes
+	return nil
+}
+
+// ByteSize is a number of bytes, given as a number or as a string with a
+// binary unit, e.g. 64KiB, see internal.ParseByteSize.
+type ByteSize int
+
+func (b *ByteSize) UnmarshalYAML(unmarshal func(any) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	size, err := internal.ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
+// Load reads a configuration file, or all *.yml, *.yaml and *.json files of a
+// directory in lexical order. Mount points of all files are combined, scalar
+// settings of later files override earlier ones.
+func Load(path string) (*Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = configFiles(path); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{}
+	for _, file := range files {
+		fileCfg, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		cfg.merge(fileCfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func configFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yml", ".yaml", ".json":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func loadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	// JSON is a subset of YAML, so one decoder handles both formats.
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) merge(other *Config) {
+	if other.ListenAddress != "" {
+		c.ListenAddress = other.ListenAddress
+	}
+	if other.TelemetryPath != "" {
+		c.TelemetryPath = other.TelemetryPath
+	}
+	if other.TelemetryNamespace != "" {
+		c.TelemetryNamespace = other.TelemetryNamespace
+	}
+	if other.CheckInterval != 0 {
+		c.CheckInterval = other.CheckInterval
+	}
+	if other.FailureThreshold != 0 {
+		c.FailureThreshold = other.FailureThreshold
+	}
+	if other.SuccessThreshold != 0 {
+		c.SuccessThreshold = other.SuccessThreshold
+	}
+	if other.SpaceWarnPercent != 0 {
+		c.SpaceWarnPercent = other.SpaceWarnPercent
+	}
+	if other.MountBackend != "" {
+		c.MountBackend = other.MountBackend
+	}
+	c.MountPoints = append(c.MountPoints, other.MountPoints...)
+}
+
+// Validate checks settings and mount points.
+func (c *Config) Validate() error {
+	if c.CheckInterval < 0 {
+		return fmt.Errorf("check_interval must be positive, got %s", c.CheckInterval)
+	}
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold must be positive, got %d", c.FailureThreshold)
+	}
+	if c.SuccessThreshold < 0 {
+		return fmt.Errorf("success_threshold must be positive, got %d", c.SuccessThreshold)
+	}
+	if c.SpaceWarnPercent < 0 || c.SpaceWarnPercent >= 100 {
+		return fmt.Errorf("space_warn_percent must be between 0 and 100, got %g", c.SpaceWarnPercent)
+	}
+	if c.TelemetryPath != "" && !strings.HasPrefix(c.TelemetryPath, "/") {
+		return fmt.Errorf("telemetry_path must start with /, got %q", c.TelemetryPath)
+	}
+	if c.MountBackend != "" && !slices.Contains(internal.MountBackends, c.MountBackend) {
+		return fmt.Errorf("mount_backend must be one of %s, got %q", strings.Join(internal.MountBackends, ", "), c.MountBackend)
+	}
+	for _, mp := range c.MountPoints {
+		if mp.Critical != nil && *mp.Critical && mp.Optional {
+			return fmt.Errorf("mount point %q cannot be both optional and critical", mp.Path)
+		}
+		if err := mp.toMountPoint().Validate(); err != nil {
+			return err
+		}
+	}
+	return internal.ValidateMountPoints(c.WatchdogMountPoints())
+}
+
+// WatchdogMountPoints converts the configured mount points for the watchdog.
+func (c *Config) WatchdogMountPoints() []internal.MountPoint {
+	points := make([]internal.MountPoint, len(c.MountPoints))
+	for i, mp := range c.MountPoints {
+		points[i] = mp.toMountPoint()
+	}
+	return points
+}
+
+func (mp MountPoint) toMountPoint() internal.MountPoint {
+	return internal.MountPoint{
+		Path:               mp.Path,
+		Alias:              mp.Alias,
+		Optional:           mp.Optional || (mp.Critical != nil && !*mp.Critical),
+		Silenced:           mp.Silenced,
+		CheckInterval:      mp.CheckInterval,
+		Absent:             mp.Absent,
+		EnclosingMount:     mp.EnclosingMount,
+		CheckSubpath:       mp.CheckSubpath,
+		DependsOn:          mp.DependsOn,
+		WriteTest:          mp.WriteTest,
+		WriteTestDir:       mp.WriteTestDir,
+		WriteTestSize:      int(mp.WriteTestSize),
+		WriteFsync:         mp.WriteFsync,
+		AllowedServerCIDRs: mp.AllowedServers,
+		RequireOptions:     mp.RequireOptions,
+		ExpectOptions:      mp.ExpectOptions,
+		RemountSource:      mp.RemountSource,
+		RemountOptions:     mp.RemountOptions,
+		OnUnhealthy:        mp.OnUnhealthy,
+		OnHealthy:          mp.OnHealthy,
+		FSTypes:            mp.FSTypes,
+		Origin:             internal.OriginConfig,
+		Tags:               mp.Tags,
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("cannot write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yml", `
+listen_address: 127.0.0.1:9191
+telemetry_path: /nfs-metrics
+telemetry_namespace: nfs
+check_interval: 10s
+failure_threshold: 3
+success_threshold: 2
+space_warn_percent: 90
+mount_backend: exec
+mount_points:
+  - path: /var/vcap/store/job
+    alias: job
+    check_interval: 5m
+    require_options: [hard, timeo=600]
+    allowed_server_cidr: [10.20.0.0/16, "2001:db8::/32"]
+  - path: /archive
+    optional: true
+    fstype: [cifs, smb3]
+    tags:
+      team: payments
+  - path: /scratch
+    critical: false
+    write_test_dir: .nfsma
+    write_test_size: 64KiB
+    write_fsync: true
+  - path: /logs
+    write_test_size: 512
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ListenAddress != "127.0.0.1:9191" || cfg.TelemetryPath != "/nfs-metrics" || cfg.TelemetryNamespace != "nfs" || cfg.CheckInterval != 10*time.Second || cfg.MountBackend != "exec" {
+		t.Errorf("unexpected settings %+v", cfg)
+	}
+	if cfg.FailureThreshold != 3 || cfg.SuccessThreshold != 2 || cfg.SpaceWarnPercent != 90 {
+		t.Errorf("unexpected thresholds %+v", cfg)
+	}
+
+	points := cfg.WatchdogMountPoints()
+	if len(points) != 4 {
+		t.Fatalf("expected 4 mount points, got %d", len(points))
+	}
+	if points[0].Alias != "job" || points[0].CheckInterval != 5*time.Minute || len(points[0].RequireOptions) != 2 || len(points[0].AllowedServerCIDRs) != 2 {
+		t.Errorf("unexpected first mount point %+v", points[0])
+	}
+	if !points[1].Optional || len(points[1].FSTypes) != 2 {
+		t.Errorf("unexpected second mount point %+v", points[1])
+	}
+	if points[1].Tags["team"] != "payments" {
+		t.Errorf("expected tag team=payments, got %v", points[1].Tags)
+	}
+	if !points[2].Optional {
+		t.Errorf("expected a mount point not critical to be optional, got %+v", points[2])
+	}
+	if points[2].WriteTestPath() != "/scratch/.nfsma" || points[2].WriteTestSize != 64<<10 || points[2].WriteFsync == nil || !*points[2].WriteFsync {
+		t.Errorf("unexpected write test settings %+v", points[2])
+	}
+	if points[3].WriteTestSize != 512 {
+		t.Errorf("expected a size given as a number in bytes, got %d", points[3].WriteTestSize)
+	}
+}
+
+func TestLoadDirectoryMergesFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "10-base.yml", "check_interval: 30s\nmount_points:\n  - path: /a\n")
+	writeFile(t, dir, "20-extra.json", `{"check_interval": "5s", "mount_points": [{"path": "/b"}]}`)
+	writeFile(t, dir, "README.txt", "ignored")
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.CheckInterval != 5*time.Second {
+		t.Errorf("expected later file to override check_interval, got %s", cfg.CheckInterval)
+	}
+	if len(cfg.MountPoints) != 2 || cfg.MountPoints[0].Path != "/a" || cfg.MountPoints[1].Path != "/b" {
+		t.Errorf("unexpected mount points %+v", cfg.MountPoints)
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown.yml":   "unknown_setting: 1\n",
+		"relative.yml":  "mount_points:\n  - path: relative\n",
+		"duplicate.yml": "mount_points:\n  - path: /a\n  - path: /a\n",
+		"negative.yml":  "check_interval: -1s\n",
+		"tag.yml":       "mount_points:\n  - path: /a\n    tags: {result: x}\n",
+		"cidr.yml":      "mount_points:\n  - path: /a\n    allowed_server_cidr: [10.0.0.0]\n",
+		"newline.yml":   "mount_points:\n  - path: \"/a\\n\"\n",
+		"telemetry.yml": "telemetry_path: metrics\n",
+		"interval.yml":  "mount_points:\n  - path: /a\n    check_interval: -1m\n",
+		"critical.yml":  "mount_points:\n  - path: /a\n    optional: true\n    critical: true\n",
+		"backend.yml":   "mount_backend: fuse\n",
+		"failures.yml":  "failure_threshold: -1\n",
+		"successes.yml": "success_threshold: -1\n",
+		"space.yml":     "space_warn_percent: 100\n",
+	} {
+		if _, err := Load(writeFile(t, dir, name, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yml")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// agentLabels are used by metrics of the agent besides the per-mount ones,
+// including the histograms and the Go runtime and process metrics.
+var agentLabels = map[string]bool{"program": true, "version": true, "port": true, "webhook": true, "le": true, "quantile": true, "signal": true}
+
+// ParseConstLabel parses a constant label given as key=value, attached to all
+// exported metrics. The key must be a valid label name not used by any metric.
+func ParseConstLabel(value string) (key, labelValue string, err error) {
+	key, labelValue, ok := strings.Cut(value, "=")
+	if !ok {
+		return "", "", fmt.Errorf("label must be key=value: %q", value)
+	}
+	if !labelNameRE.MatchString(key) || strings.HasPrefix(key, "__") {
+		return "", "", fmt.Errorf("invalid label name %q", key)
+	}
+	if reservedLabels[key] || agentLabels[key] {
+		return "", "", fmt.Errorf("label %q is already used by the metrics", key)
+	}
+	return key, labelValue, nil
+}
+
+// ValidateConstLabels rejects constant labels colliding with a tag key of the
+// mount points, as both end up on the per-mount metrics.
+func ValidateConstLabels(labels map[string]string, points []MountPoint) error {
+	for _, key := range TagKeys(points) {
+		if _, ok := labels[key]; ok {
+			return fmt.Errorf("label %q is also a tag key of the mount points", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParseConstLabel(t *testing.T) {
+	key, value, err := ParseConstLabel("environment=prod=eu")
+	if err != nil || key != "environment" || value != "prod=eu" {
+		t.Errorf("expected environment=prod=eu, got %q=%q, error %v", key, value, err)
+	}
+	if _, value, err := ParseConstLabel("az="); err != nil || value != "" {
+		t.Errorf("expected an empty value to be accepted, got %q, error %v", value, err)
+	}
+	for _, invalid := range []string{"environment", "=prod", "1az=a", "__meta=a", "mountpoint=/mnt", "server=nfs", "le=1", "version=1"} {
+		if _, _, err := ParseConstLabel(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestValidateConstLabels(t *testing.T) {
+	points := []MountPoint{{Path: "/mnt/a", Tags: map[string]string{"team": "payments"}}}
+	if err := ValidateConstLabels(map[string]string{"environment": "prod"}, points); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := ValidateConstLabels(map[string]string{"team": "storage"}, points); err == nil {
+		t.Error("expected a label colliding with a tag key to be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// HandleDebug registers the net/http/pprof handlers under /debug/pprof/ and a
+// goroutine dump at /debug/goroutines in the pprof group, each wrapped by wrap,
+// e.g. to require the admin token. They are meant for diagnosing goroutines
+// stuck on a hung NFS server and are not registered unless enabled.
+func (r *Router) HandleDebug(wrap func(http.Handler) http.Handler) {
+	r.Handle(ListenGroupPprof, "/debug/pprof/", wrap(http.HandlerFunc(pprof.Index)))
+	r.Handle(ListenGroupPprof, "/debug/pprof/cmdline", wrap(http.HandlerFunc(pprof.Cmdline)))
+	r.Handle(ListenGroupPprof, "/debug/pprof/profile", wrap(http.HandlerFunc(pprof.Profile)))
+	r.Handle(ListenGroupPprof, "/debug/pprof/symbol", wrap(http.HandlerFunc(pprof.Symbol)))
+	r.Handle(ListenGroupPprof, "/debug/pprof/trace", wrap(http.HandlerFunc(pprof.Trace)))
+	r.Handle(ListenGroupPprof, "/debug/goroutines", wrap(http.HandlerFunc(HandleGoroutines)))
+}
+
+// HandleGoroutines dumps the stack of every goroutine as plain text, in the
+// format of an unrecovered panic, preceded by their count. Goroutines blocked
+// in a system call on an unresponsive mount show up with their wait time.
+func HandleGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = fmt.Fprintf(w, "goroutines: %d\n\n", runtime.NumGoroutine())
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDebug(t *testing.T) {
+	router := NewRouter()
+	router.HandleDebug(func(h http.Handler) http.Handler { return RequireBearerToken(h, "secret") })
+	mux := router.Mux([]string{ListenGroupPprof})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the wrapped pprof index to require the token, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/goroutines"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 with the token, got %d", path, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.Mux([]string{ListenGroupAdmin}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected no goroutine dump outside the pprof group, got %d", rec.Code)
+	}
+}
+
+func TestHandleGoroutines(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleGoroutines(rec, httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(body, "goroutines: ") {
+		t.Fatalf("expected a goroutine count, got %d %q", rec.Code, body)
+	}
+	if !strings.Contains(body, "TestHandleGoroutines") {
+		t.Error("expected the stack of the calling goroutine in the dump")
+	}
+
+	rec = httptest.NewRecorder()
+	HandleGoroutines(rec, httptest.NewRequest(http.MethodPost, "/debug/goroutines", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Discoverer scans the mount table for NFS mounts and monitors those whose
+// path matches include and not exclude, so mount points need not be listed one
+// by one. A discovered mount point that disappears from the mount table is
+// removed once it has been gone for the grace period, so a remount or a short
+// outage keeps its state and is reported unhealthy meanwhile.
+type Discoverer struct {
+	watchdog *Watchdog
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+	interval time.Duration
+	grace    time.Duration
+
+	// discovered maps the discovered mount points to the time they were
+	// first missing from the mount table, zero while mounted.
+	discovered map[string]time.Time
+	tableErr   string
+
+	discoveredMounts prometheus.Gauge
+	changesTotal     *prometheus.CounterVec
+}
+
+// NewDiscoverer scans every interval. A nil include matches all NFS mounts, a
+// nil exclude none.
+func NewDiscoverer(namespace string, watchdog *Watchdog, include, exclude *regexp.Regexp, interval, grace time.Duration) *Discoverer {
+	return &Discoverer{
+		watchdog:   watchdog,
+		include:    include,
+		exclude:    exclude,
+		interval:   interval,
+		grace:      grace,
+		discovered: make(map[string]time.Time),
+
+		discoveredMounts: promauto.With(watchdog.Registerer()).NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "discovered_mount_points",
+				Help:      "Number of mount points monitored because they were discovered in the mount table",
+			},
+		),
+		changesTotal: promauto.With(watchdog.Registerer()).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "discovery_changes_total",
+				Help:      "Number of discovered mount points by action (added, removed)",
+			},
+			[]string{"action"},
+		),
+	}
+}
+
+// Run scans every interval until ctx is cancelled.
+func (d *Discoverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Scan(now)
+		}
+	}
+}
+
+func (d *Discoverer) matches(path string) bool {
+	return (d.include == nil || d.include.MatchString(path)) && (d.exclude == nil || !d.exclude.MatchString(path))
+}
+
+// Scan reads the mount table once, monitors the matching NFS mounts not
+// monitored yet and removes discovered mount points missing for longer than
+// the grace period. Mount points configured otherwise are left alone. Scan is
+// not safe for concurrent use.
+func (d *Discoverer) Scan(now time.Time) {
+	table := readMountTable(d.watchdog.mounts)
+	if table.err != nil {
+		// Log once per distinct error; nothing is removed on a failed read.
+		if table.err.Error() != d.tableErr {
+			slog.Warn("cannot read the mount table, discovery skipped", "mounts_file", table.file, "error", table.err.Error())
+			d.tableErr = table.err.Error()
+		}
+		return
+	}
+	d.tableErr = ""
+
+	var found []string
+	for path, entry := range table.entries {
+		if entry.isNFS() && d.matches(path) {
+			found = append(found, path)
+		}
+	}
+	slices.Sort(found)
+
+	for _, path := range found {
+		if origin, ok := d.watchdog.mountPointOrigin(path); ok {
+			if origin == OriginDiscovered {
+				d.discovered[path] = time.Time{}
+			} else {
+				// Configured since, or added with the admin API: no longer ours.
+				delete(d.discovered, path)
+			}
+			continue
+		}
+		// Not monitored yet, or no longer after the admin API removed it.
+		if _, err := d.watchdog.AddMountPoint(MountPoint{Path: path, Origin: OriginDiscovered}); err != nil {
+			slog.Warn("cannot monitor discovered mount", "mountpoint", path, "error", err.Error())
+			continue
+		}
+		d.discovered[path] = time.Time{}
+		d.changesTotal.WithLabelValues("added").Inc()
+		slog.Info("discovered NFS mount, monitoring", "mountpoint", path, "source", table.entries[path].Source)
+	}
+
+	for path, since := range d.discovered {
+		if slices.Contains(found, path) {
+			continue
+		}
+		if origin, ok := d.watchdog.mountPointOrigin(path); !ok || origin != OriginDiscovered {
+			// Removed in the meantime by the admin API, or configured since.
+			delete(d.discovered, path)
+			continue
+		}
+		if since.IsZero() {
+			since = now
+			d.discovered[path] = since
+			slog.Info("discovered mount gone, removing after the grace period", "mountpoint", path, "grace", d.grace.String())
+		}
+		if now.Sub(since) < d.grace {
+			continue
+		}
+		if _, err := d.watchdog.RemoveMountPoint(path); err != nil && !errors.Is(err, ErrMountPointNotFound) {
+			slog.Warn("cannot remove discovered mount", "mountpoint", path, "error", err.Error())
+			continue
+		}
+		delete(d.discovered, path)
+		d.changesTotal.WithLabelValues("removed").Inc()
+		slog.Info("discovered mount gone for the grace period, no longer monitored", "mountpoint", path)
+	}
+	d.discoveredMounts.Set(float64(len(d.discovered)))
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDiscovererScan(t *testing.T) {
+	root := t.TempDir()
+	explicit, found, excluded, local := filepath.Join(root, "explicit"), filepath.Join(root, "found"), filepath.Join(root, "found-tmp"), filepath.Join(root, "local")
+	for _, dir := range []string{explicit, found, excluded, local} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mounted := "nfs1:/explicit " + explicit + " nfs4 rw 0 0\n" +
+		"nfs1:/found " + found + " nfs4 rw 0 0\n" +
+		"nfs1:/tmp " + excluded + " nfs4 rw 0 0\n" +
+		"tmpfs " + local + " tmpfs rw 0 0\n"
+	mountsFile := writeMountsFixture(t, mounted)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(explicit), WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	d := NewDiscoverer("test_ns", w, regexp.MustCompile("^"+regexp.QuoteMeta(root)+"/"), regexp.MustCompile(`-tmp$`), time.Minute, 5*time.Minute)
+	paths := func() []string { return mountPointPaths(w.MountPoints()) }
+
+	now := time.Now()
+	d.Scan(now)
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Fatalf("expected the explicit and the discovered mount point, got %v", got)
+	}
+	if got := testutil.ToFloat64(d.discoveredMounts); got != 1 {
+		t.Errorf("expected 1 discovered mount point, got %v", got)
+	}
+
+	// Gone from the mount table: kept for the grace period, back in time.
+	if err := os.WriteFile(mountsFile, []byte("tmpfs "+local+" tmpfs rw 0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Scan(now.Add(time.Minute))
+	if err := os.WriteFile(mountsFile, []byte(mounted), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Scan(now.Add(10 * time.Minute))
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Fatalf("expected a mount back within the grace period to be kept, got %v", got)
+	}
+
+	// Gone for the grace period: removed, the explicit one stays.
+	if err := os.WriteFile(mountsFile, []byte("tmpfs "+local+" tmpfs rw 0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Scan(now.Add(11 * time.Minute))
+	d.Scan(now.Add(15 * time.Minute))
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Fatalf("expected the mount to be kept within the grace period, got %v", got)
+	}
+	d.Scan(now.Add(16 * time.Minute))
+	if got := paths(); !slices.Equal(got, []string{explicit}) {
+		t.Fatalf("expected the discovered mount point to be removed after the grace period, got %v", got)
+	}
+	if got := testutil.ToFloat64(d.changesTotal.WithLabelValues("removed")); got != 1 {
+		t.Errorf("expected 1 removal, got %v", got)
+	}
+
+	// An unreadable mount table changes nothing.
+	if err := os.WriteFile(mountsFile, []byte(mounted), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Scan(now.Add(17 * time.Minute))
+	w.mounts = MountsFile(filepath.Join(root, "missing"))
+	d.Scan(now.Add(30 * time.Minute))
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Errorf("expected an unreadable mount table to keep the mount points, got %v", got)
+	}
+	if got := testutil.ToFloat64(d.changesTotal.WithLabelValues("added")); got != 2 {
+		t.Errorf("expected 2 additions, got %v", got)
+	}
+
+	// Kept by a reload, until the config takes it over: then never removed.
+	if _, err := w.ReloadMountPoints(testMountPoints(explicit)); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Fatalf("expected the discovered mount point to survive a reload, got %v", got)
+	}
+	if _, err := w.ReloadMountPoints(testMountPoints(explicit, found)); err != nil {
+		t.Fatal(err)
+	}
+	w.mounts = MountsFile(mountsFile)
+	if err := os.WriteFile(mountsFile, []byte("tmpfs "+local+" tmpfs rw 0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.Scan(now.Add(31 * time.Minute))
+	d.Scan(now.Add(time.Hour))
+	if got := paths(); !slices.Equal(got, []string{explicit, found}) {
+		t.Errorf("expected a configured mount point never to be removed by discovery, got %v", got)
+	}
+	if got := testutil.ToFloat64(d.discoveredMounts); got != 0 {
+		t.Errorf("expected no discovered mount point left, got %v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Classes of failed checks, the reason label of checks_total and
+// mount_last_error_info.
+const (
+	reasonTimeout              = "timeout"
+	reasonStale                = "estale"
+	reasonPermissionDenied     = "permission_denied"
+	reasonMountTableUnreadable = "mounttable_unreadable"
+	reasonNotInMountTable      = "not_in_proc_mounts"
+	reasonNotNFS               = "not_nfs"
+	reasonWrongFSType          = "wrong_fstype"
+	reasonNotDirectory         = "not_directory"
+	reasonStatFailed           = "stat_failed"
+	reasonSubpathMissing       = "subpath_missing"
+	reasonMissingOption        = "missing_option"
+	reasonServerNotAllowed     = "server_not_allowed"
+	reasonReadOnlyForced       = "read_only_forced"
+	reasonPresent              = "present"
+	reasonWriteVerify          = "write_verify"
+	reasonProbeCollision       = "probe_collision"
+	reasonWriteFailed          = "write_failed"
+	reasonLockFailed           = "lock_failed"
+	reasonOther                = "other"
+)
+
+// errWriteTest marks a failed write test.
+var errWriteTest = errors.New("write test failed")
+
+// classifiedError attaches a class to an error without changing its message.
+type classifiedError struct {
+	reason string
+	err    error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+func classified(reason string, err error) error {
+	return &classifiedError{reason: reason, err: err}
+}
+
+// errorReason classifies the error of a failed check, "" for a passed one.
+// The cause wins over the step that failed: a stale file handle or a denied
+// access is reported as such whether it hit stat or the write test.
+func errorReason(err error) string {
+	var ce *classifiedError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, errCheckTimeout):
+		return reasonTimeout
+	case errors.Is(err, syscall.ESTALE):
+		return reasonStale
+	case errors.Is(err, os.ErrPermission):
+		return reasonPermissionDenied
+	case errors.Is(err, errMountTableUnreadable):
+		return reasonMountTableUnreadable
+	case errors.Is(err, errMountNotFound):
+		return reasonNotInMountTable
+	case errors.As(err, &ce):
+		return ce.reason
+	case errors.Is(err, errWriteTest):
+		return reasonWriteFailed
+	case errors.Is(err, errLockTest):
+		return reasonLockFailed
+	default:
+		return reasonOther
+	}
+}
+
+// helperError is a failure reported by the probe helper process as text. It
+// restores the cause the text names, so the failure classifies as if the
+// probe had run in the agent.
+type helperError struct {
+	message string
+	cause   error
+}
+
+func (e *helperError) Error() string { return e.message }
+func (e *helperError) Unwrap() error { return e.cause }
+
+func newHelperError(message string) error {
+	e := &helperError{message: message}
+	for _, errno := range []syscall.Errno{syscall.ESTALE, syscall.EACCES, syscall.EPERM} {
+		if strings.HasSuffix(message, errno.Error()) {
+			e.cause = errno
+			return e
+		}
+	}
+	for _, reason := range []string{reasonWriteVerify, reasonProbeCollision} {
+		if strings.HasPrefix(message, reason+": ") {
+			e.cause = classified(reason, errors.New(message))
+		}
+	}
+	return e
+}
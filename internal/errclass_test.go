@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorReason(t *testing.T) {
+	staleStat := classified(reasonStatFailed, fmt.Errorf("stat(/mnt/a) failed: %w", &fs.PathError{Op: "stat", Path: "/mnt/a", Err: syscall.ESTALE}))
+	for want, err := range map[string]error{
+		"":                         nil,
+		reasonTimeout:              fmt.Errorf("%w: /mnt/a did not respond within 10s", errCheckTimeout),
+		reasonStale:                staleStat,
+		reasonPermissionDenied:     fmt.Errorf("%w on /mnt/a: %w", errWriteTest, &fs.PathError{Op: "open", Path: "/mnt/a/x", Err: syscall.EACCES}),
+		reasonMountTableUnreadable: fmt.Errorf("%w: %w", errMountTableUnreadable, os.ErrNotExist),
+		reasonNotInMountTable:      fmt.Errorf("checking /proc/mounts failed: %w in /proc/mounts", errMountNotFound),
+		reasonStatFailed:           classified(reasonStatFailed, fmt.Errorf("stat(/mnt/a) failed: %w", os.ErrNotExist)),
+		reasonWriteVerify:          fmt.Errorf("%w on /mnt/a: %w", errWriteTest, classified(reasonWriteVerify, errors.New("write_verify: read back"))),
+		reasonWriteFailed:          fmt.Errorf("%w on /mnt/a: %w", errWriteTest, syscall.EROFS),
+		reasonLockFailed:           fmt.Errorf("%w on /mnt/a: %w", errLockTest, syscall.ENOLCK),
+		reasonOther:                errors.New("something else"),
+	} {
+		if got := errorReason(err); got != want {
+			t.Errorf("errorReason(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestHelperErrorRestoresCause(t *testing.T) {
+	for message, want := range map[string]string{
+		"open /mnt/a/.nfs_mounter_test_1_2: " + syscall.ESTALE.Error(): reasonStale,
+		"open /mnt/a/.nfs_mounter_test_1_2: " + syscall.EACCES.Error(): reasonPermissionDenied,
+		"write_verify: read back \"x\", wrote \"y\"":                   reasonWriteVerify,
+		"open /mnt/a/.nfs_mounter_test_1_2: " + syscall.EROFS.Error():  reasonWriteFailed,
+	} {
+		err := fmt.Errorf("%w on /mnt/a: %w", errWriteTest, newHelperError(message))
+		if got := errorReason(err); got != want {
+			t.Errorf("%q: got reason %q, want %q", message, got, want)
+		}
+		if err.Error() != "write test failed on /mnt/a: "+message {
+			t.Errorf("expected the helper message unchanged, got %q", err)
+		}
+	}
+}
+
+func TestCheckReportsErrorReason(t *testing.T) {
+	mp := MountPoint{Path: t.TempDir()}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{MountsFile: writeMountsFixture(t, "")})
+
+	w.CheckMountPoint(mp)
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues(mp.Path, mp.Path, "", "", "error", reasonNotInMountTable)); got != 1 {
+		t.Errorf("expected 1 not_in_proc_mounts check, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsLastErrorInfo.WithLabelValues(mp.Path, mp.Path, reasonNotInMountTable)); got != 1 {
+		t.Errorf("expected the last error reason to be set, got %v", got)
+	}
+
+	w.mounts = MountsFile(writeMountsFixture(t, "tmpfs "+mp.Path+" tmpfs rw 0 0\n"))
+	w.CheckMountPoint(mp)
+	if got := testutil.CollectAndCount(w.nfsLastErrorInfo); got != 1 {
+		t.Fatalf("expected a single last error series, got %d", got)
+	}
+	if got := testutil.ToFloat64(w.nfsLastErrorInfo.WithLabelValues(mp.Path, mp.Path, reasonNotNFS)); got != 1 {
+		t.Errorf("expected the last error reason to be replaced by not_nfs, got %v", got)
+	}
+}
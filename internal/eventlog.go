@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types of the event log.
+const (
+	EventTransition = "transition"
+	EventCheckError = "check_error"
+	EventRemount    = "remount"
+)
+
+// Event is an entry of the event log: a health transition, a check error or
+// a remount attempt of a mount point.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	MountPoint string    `json:"mountpoint"`
+	// Healthy is the new state of a transition.
+	Healthy *bool `json:"healthy,omitempty"`
+	// Action and Result are the action of a remount attempt and its result,
+	// success or failed.
+	Action string `json:"action,omitempty"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventLog keeps the most recent events in memory, so an incident can be
+// reconstructed after the logs rotated. A nil EventLog records nothing.
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+func NewEventLog(size int) *EventLog {
+	if size < 1 {
+		return nil
+	}
+	return &EventLog{events: make([]Event, size)}
+}
+
+// add records an event, replacing the oldest one when the log is full.
+func (l *EventLog) add(event Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	l.full = l.full || l.next == 0
+}
+
+// Events returns the recorded events, oldest first.
+func (l *EventLog) Events() []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]Event(nil), l.events[:l.next]...)
+	}
+	return append(append([]Event(nil), l.events[l.next:]...), l.events[:l.next]...)
+}
+
+// ServeHTTP lists the recorded events as JSON, oldest first, optionally
+// filtered by ?mountpoint= and ?type= and limited to the newest ?limit=.
+func (l *EventLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", value), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	mountPoint, eventType := query.Get("mountpoint"), query.Get("type")
+	switch eventType {
+	case "", EventTransition, EventCheckError, EventRemount:
+	default:
+		http.Error(w, fmt.Sprintf("unknown event type %q (%s, %s, %s)", eventType, EventTransition, EventCheckError, EventRemount), http.StatusBadRequest)
+		return
+	}
+
+	events := []Event{}
+	for _, e := range l.Events() {
+		if (mountPoint == "" || e.MountPoint == mountPoint) && (eventType == "" || e.Type == eventType) {
+			events = append(events, e)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": events})
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventLogRingBuffer(t *testing.T) {
+	l := NewEventLog(3)
+	for _, path := range []string{"/mnt/a", "/mnt/b", "/mnt/c", "/mnt/d"} {
+		l.add(Event{Type: EventCheckError, MountPoint: path})
+	}
+	events := l.Events()
+	if len(events) != 3 || events[0].MountPoint != "/mnt/b" || events[2].MountPoint != "/mnt/d" {
+		t.Errorf("expected the oldest event to be replaced, got %+v", events)
+	}
+
+	var disabled *EventLog
+	disabled.add(Event{Type: EventRemount})
+	if NewEventLog(0) != nil || disabled.Events() != nil {
+		t.Error("expected a disabled event log to record nothing")
+	}
+}
+
+func TestWatchdogEventLog(t *testing.T) {
+	dir := t.TempDir()
+	mp := MountPoint{Path: dir}
+	mounted := writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    mounted,
+		EventLogSize:  10,
+	})
+	w.CheckMountPoint(mp)
+	w.mounts = MountsFile(writeMountsFixture(t, ""))
+	w.CheckMountPoint(mp)
+	w.CheckMountPoint(mp)
+	w.mounts = MountsFile(mounted)
+	w.CheckMountPoint(mp)
+
+	events := w.EventLog().Events()
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	want := []string{EventCheckError, EventTransition, EventTransition}
+	if len(events) != len(want) || types[0] != want[0] || types[1] != want[1] || types[2] != want[2] {
+		t.Fatalf("expected one check error for the repeated failure and two transitions, got %v", types)
+	}
+	if events[1].Healthy == nil || *events[1].Healthy || events[1].Error == "" {
+		t.Errorf("expected the transition to unhealthy with its error, got %+v", events[1])
+	}
+
+	rec := httptest.NewRecorder()
+	w.EventLog().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events?type=transition&limit=1", nil))
+	var body struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+	}
+	if len(body.Events) != 1 || body.Events[0].Healthy == nil || !*body.Events[0].Healthy {
+		t.Errorf("expected the newest transition only, got %+v", body.Events)
+	}
+
+	rec = httptest.NewRecorder()
+	w.EventLog().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events?type=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown type, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const eventsKeepAliveInterval = 30 * time.Second
+
+// EventBroadcaster fans state changes out to Server-Sent Events subscribers.
+// Each subscriber has its own buffer; a subscriber that falls behind is
+// disconnected instead of blocking the check loop.
+type EventBroadcaster struct {
+	bufferSize  int
+	mu          sync.Mutex
+	subscribers map[chan StateChange]struct{}
+}
+
+func NewEventBroadcaster(bufferSize int) *EventBroadcaster {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &EventBroadcaster{
+		bufferSize:  bufferSize,
+		subscribers: make(map[chan StateChange]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving state changes and a function to cancel
+// the subscription. The channel is closed when the subscriber is dropped.
+func (b *EventBroadcaster) Subscribe() (<-chan StateChange, func()) {
+	ch := make(chan StateChange, b.bufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish delivers a state change to all subscribers without blocking.
+func (b *EventBroadcaster) Publish(change StateChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- change:
+		default:
+			slog.Warn("events subscriber too slow, disconnecting")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// ServeHTTP streams state changes as Server-Sent Events until the client disconnects.
+func (b *EventBroadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case change, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				continue
+			}
+			if _, err := w.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBroadcasterStreamsStateChanges(t *testing.T) {
+	b := NewEventBroadcaster(4)
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected content type text/event-stream, got %q", ct)
+	}
+
+	// Wait until the handler has subscribed.
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.mu.Lock()
+		n := len(b.subscribers)
+		b.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handler did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Publish(StateChange{MountPoint: "/mnt/a", Healthy: false, PreviousHealthy: true})
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading event failed: %v", err)
+	}
+	payload, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+	if !ok {
+		t.Fatalf("expected data line, got %q", line)
+	}
+	var change StateChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		t.Fatalf("cannot decode event %q: %v", payload, err)
+	}
+	if change.MountPoint != "/mnt/a" || change.Healthy {
+		t.Errorf("unexpected event %+v", change)
+	}
+}
+
+func TestEventBroadcasterDropsSlowSubscriber(t *testing.T) {
+	b := NewEventBroadcaster(1)
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	b.Publish(StateChange{MountPoint: "/mnt/a"})
+	b.Publish(StateChange{MountPoint: "/mnt/b"})
+
+	if change := <-events; change.MountPoint != "/mnt/a" {
+		t.Errorf("expected buffered event for /mnt/a, got %q", change.MountPoint)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("expected slow subscriber channel to be closed")
+	}
+}
+
+func TestEventBroadcasterCancelUnsubscribes(t *testing.T) {
+	b := NewEventBroadcaster(1)
+	_, cancel := b.Subscribe()
+	cancel()
+	cancel()
+
+	if len(b.subscribers) != 0 {
+		t.Errorf("expected no subscribers after cancel, got %d", len(b.subscribers))
+	}
+	b.Publish(StateChange{MountPoint: "/mnt/a"})
+}
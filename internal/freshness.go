@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Mount states exported as the state label of mount_state.
+const (
+	MountStateUnknown  = "unknown"
+	MountStateOK       = "ok"
+	MountStateDegraded = "degraded"
+	MountStateFailed   = "failed"
+	MountStatePending  = "pending"
+	MountStatePaused   = "paused"
+)
+
+var mountStates = []string{MountStateUnknown, MountStateOK, MountStateDegraded, MountStateFailed, MountStatePending, MountStatePaused}
+
+// freshnessDescs describes the metrics the watchdog computes at scrape time
+// rather than in the check loop, so they are current whenever scraped.
+type freshnessDescs struct {
+	sinceSuccess *prometheus.Desc
+	state        *prometheus.Desc
+	silenced     *prometheus.Desc
+}
+
+func newFreshnessDescs(namespace string, labels mountLabeler) freshnessDescs {
+	return freshnessDescs{
+		sinceSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "mount_seconds_since_last_success"),
+			"Seconds since the last passed check of the mount point finished, computed at scrape time",
+			labels.names(), nil,
+		),
+		state: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "mount_state"),
+			"1 for the current state of the mount point (unknown, ok, degraded, failed, pending, paused), 0 for the others",
+			labels.names("state"), nil,
+		),
+		silenced: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "mount_silenced"),
+			"1 while the mount point is in a maintenance window and left out of the global health, 0 otherwise",
+			labels.names(), nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector for the scrape-time metrics.
+func (m *Watchdog) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.freshness.sinceSuccess
+	ch <- m.freshness.state
+	ch <- m.freshness.silenced
+}
+
+// Collect implements prometheus.Collector for the scrape-time metrics. Mount
+// points that never passed a check have no seconds since the last success.
+func (m *Watchdog) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, mp := range m.sortedMountPoints() {
+		if at, ok := m.lastSuccesses[mp.Path]; ok {
+			ch <- prometheus.MustNewConstMetric(m.freshness.sinceSuccess, prometheus.GaugeValue, now.Sub(at).Seconds(), m.labels.values(mp)...)
+		}
+		current := m.mountState(mp)
+		for _, state := range mountStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(m.freshness.state, prometheus.GaugeValue, value, m.labels.values(mp, state)...)
+		}
+		silenced := 0.0
+		if m.mountSilenced(mp, now) {
+			silenced = 1
+		}
+		ch <- prometheus.MustNewConstMetric(m.freshness.silenced, prometheus.GaugeValue, silenced, m.labels.values(mp)...)
+	}
+}
+
+// mountState returns the state of mp. m.mu must be held.
+func (m *Watchdog) mountState(mp MountPoint) string {
+	switch {
+	case m.paused[mp.Path]:
+		return MountStatePaused
+	case m.pending[mp.Path]:
+		return MountStatePending
+	case !m.checked[mp.Path]:
+		return MountStateUnknown
+	case !m.lastHealthy[mp.Path]:
+		return MountStateFailed
+	case m.spaceLow[mp.Path] || (m.driftDegrades && len(m.optionDrift[mp.Path]) > 0):
+		return MountStateDegraded
+	}
+	return MountStateOK
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherFreshness returns the current state and the seconds since the last
+// success of each mount point, by path.
+func gatherFreshness(t *testing.T, reg *prometheus.Registry) (states map[string]string, sinceSuccess map[string]float64) {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("cannot gather: %v", err)
+	}
+	states, sinceSuccess = map[string]string{}, map[string]float64{}
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			switch mf.GetName() {
+			case "test_ns_mount_state":
+				if metric.GetGauge().GetValue() == 1 {
+					states[labels["mountpoint"]] += labels["state"]
+				}
+			case "test_ns_mount_seconds_since_last_success":
+				sinceSuccess[labels["mountpoint"]] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return states, sinceSuccess
+}
+
+func TestFreshnessMetrics(t *testing.T) {
+	dir := t.TempDir()
+	reg := prometheus.NewRegistry()
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(dir, "/mnt/missing"), WatchdogOptions{
+		MountsFile: writeMountsFixture(t, "server:/export "+dir+" nfs4 rw,hard 0 0\n"),
+		Registerer: reg,
+	})
+
+	states, sinceSuccess := gatherFreshness(t, reg)
+	if states[dir] != MountStateUnknown || states["/mnt/missing"] != MountStateUnknown {
+		t.Errorf("expected both mount points unknown before the first check, got %v", states)
+	}
+	if len(sinceSuccess) != 0 {
+		t.Errorf("expected no seconds since the last success before the first check, got %v", sinceSuccess)
+	}
+
+	w.CheckAll()
+	time.Sleep(10 * time.Millisecond)
+
+	states, sinceSuccess = gatherFreshness(t, reg)
+	if states[dir] != MountStateOK || states["/mnt/missing"] != MountStateFailed {
+		t.Errorf("expected %s ok and /mnt/missing failed, got %v", dir, states)
+	}
+	if got, ok := sinceSuccess[dir]; !ok || got < 0.01 || got > 5 {
+		t.Errorf("expected the age of the last success at scrape time, got %v", sinceSuccess)
+	}
+	if _, ok := sinceSuccess["/mnt/missing"]; ok {
+		t.Error("expected no seconds since the last success for a mount point that never passed")
+	}
+
+	w.spaceLow[dir] = true
+	if got := w.mountState(MountPoint{Path: dir}); got != MountStateDegraded {
+		t.Errorf("expected a healthy mount point low on space to be degraded, got %s", got)
+	}
+}
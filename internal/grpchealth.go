@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Serving statuses of grpc.health.v1.HealthCheckResponse.
+const (
+	grpcServing        = 1
+	grpcNotServing     = 2
+	grpcServiceUnknown = 3
+)
+
+// gRPC status codes used by the health service.
+const (
+	grpcCodeOK            = 0
+	grpcCodeInvalidArg    = 3
+	grpcCodeNotFound      = 5
+	grpcCodeUnimplemented = 12
+)
+
+// grpcWatchInterval is how often a Watch stream re-evaluates the health it
+// reports; a change is sent at most this late.
+const grpcWatchInterval = time.Second
+
+// maxGRPCMessage bounds a health check request; the service name is its only
+// field.
+const maxGRPCMessage = 4096
+
+// GRPCHealthServer implements the standard gRPC health checking protocol
+// (grpc.health.v1.Health Check and Watch) over HTTP/2, for service mesh
+// probes. The empty service name is the global health, any other names a
+// mount point by path or alias, with the same semantics as the HTTP health
+// endpoints. It is an http.Handler, served without TLS by an HTTP/2 server.
+type GRPCHealthServer struct {
+	health *HealthHandlers
+}
+
+func NewGRPCHealthServer(health *HealthHandlers) *GRPCHealthServer {
+	return &GRPCHealthServer{health: health}
+}
+
+func (s *GRPCHealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	service, err := readHealthCheckRequest(r.Body)
+	if err != nil {
+		writeGRPCStatus(w, grpcCodeInvalidArg, err.Error())
+		return
+	}
+
+	switch r.URL.Path {
+	case "/grpc.health.v1.Health/Check":
+		status := s.status(service)
+		if status == grpcServiceUnknown {
+			writeGRPCStatus(w, grpcCodeNotFound, "unknown service "+strconv.QuoteToASCII(service))
+			return
+		}
+		_, _ = w.Write(healthCheckResponse(status))
+		writeGRPCStatus(w, grpcCodeOK, "")
+	case "/grpc.health.v1.Health/Watch":
+		s.watch(w, r, service)
+	default:
+		writeGRPCStatus(w, grpcCodeUnimplemented, "unknown method "+r.URL.Path)
+	}
+}
+
+// watch streams the status of service, first at once and then on every
+// change, until the client goes away or the agent stops.
+func (s *GRPCHealthServer) watch(w http.ResponseWriter, r *http.Request, service string) {
+	ticker := time.NewTicker(grpcWatchInterval)
+	defer ticker.Stop()
+
+	sent := -1
+	for {
+		if status := s.status(service); status != sent {
+			if _, err := w.Write(healthCheckResponse(status)); err != nil {
+				return
+			}
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				return
+			}
+			sent = status
+		}
+		select {
+		case <-r.Context().Done():
+			writeGRPCStatus(w, grpcCodeOK, "")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *GRPCHealthServer) status(service string) int {
+	var healthy, ok bool
+	if service == "" {
+		healthy, ok = s.health.Healthy(), true
+	} else {
+		healthy, ok = s.health.MountHealthy(service)
+	}
+	switch {
+	case !ok:
+		return grpcServiceUnknown
+	case healthy:
+		return grpcServing
+	}
+	return grpcNotServing
+}
+
+// readHealthCheckRequest reads a length-prefixed HealthCheckRequest and
+// returns its service name.
+func readHealthCheckRequest(body io.Reader) (string, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return "", fmt.Errorf("cannot read the request: %w", err)
+	}
+	if prefix[0] != 0 {
+		return "", errors.New("compressed requests are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxGRPCMessage {
+		return "", fmt.Errorf("request of %d bytes exceeds %d", size, maxGRPCMessage)
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(body, message); err != nil {
+		return "", fmt.Errorf("cannot read the request: %w", err)
+	}
+
+	var service string
+	for len(message) > 0 {
+		number, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		message = message[n:]
+		if number == 1 && typ == protowire.BytesType {
+			value, n := protowire.ConsumeString(message)
+			if n < 0 {
+				return "", protowire.ParseError(n)
+			}
+			service, message = value, message[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(number, typ, message)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		message = message[n:]
+	}
+	return service, nil
+}
+
+// healthCheckResponse returns a length-prefixed HealthCheckResponse.
+func healthCheckResponse(status int) []byte {
+	message := protowire.AppendTag(nil, 1, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(status))
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+}
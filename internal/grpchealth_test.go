@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func newGRPCHealthTestServer(t *testing.T, w *Watchdog) (*httptest.Server, *http.Client) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(NewGRPCHealthServer(NewHealthHandler(w, "/health", "mount-points")))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return srv, &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+func healthCheckRequest(service string) io.Reader {
+	message := protowire.AppendTag(nil, 1, protowire.BytesType)
+	message = protowire.AppendString(message, service)
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return bytes.NewReader(append(frame, message...))
+}
+
+// grpcHealthCheck calls Check and returns the serving status, -1 without a
+// response message, and the gRPC status code.
+func grpcHealthCheck(t *testing.T, srv *httptest.Server, client *http.Client, service string) (status int, code string) {
+	t.Helper()
+	res, err := client.Post(srv.URL+"/grpc.health.v1.Health/Check", "application/grpc", healthCheckRequest(service))
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	code = res.Trailer.Get("Grpc-Status")
+	if code == "" {
+		code = res.Header.Get("Grpc-Status")
+	}
+	if len(body) == 0 {
+		return -1, code
+	}
+	if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		t.Fatalf("invalid response frame %x", body)
+	}
+	_, _, n := protowire.ConsumeTag(body[5:])
+	value, _ := protowire.ConsumeVarint(body[5+n:])
+	return int(value), code
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a", "/mnt/b"}, map[string]bool{"/mnt/a": true, "/mnt/b": false})
+	srv, client := newGRPCHealthTestServer(t, w)
+
+	tests := []struct {
+		service    string
+		wantStatus int
+		wantCode   string
+	}{
+		{"", grpcNotServing, "0"},
+		{"/mnt/a", grpcServing, "0"},
+		{"mnt/a", grpcServing, "0"},
+		{"/mnt/b", grpcNotServing, "0"},
+		{"/mnt/unknown", -1, "5"},
+	}
+	for _, tt := range tests {
+		status, code := grpcHealthCheck(t, srv, client, tt.service)
+		if status != tt.wantStatus || code != tt.wantCode {
+			t.Errorf("service %q: expected status %d, code %s, got %d, code %s", tt.service, tt.wantStatus, tt.wantCode, status, code)
+		}
+	}
+
+	w.lastHealthy["/mnt/b"] = true
+	if status, _ := grpcHealthCheck(t, srv, client, ""); status != grpcServing {
+		t.Errorf("expected the global health serving once all mount points are healthy, got %d", status)
+	}
+}
+
+func TestGRPCHealthWatch(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a"}, map[string]bool{"/mnt/a": true})
+	srv, client := newGRPCHealthTestServer(t, w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/grpc.health.v1.Health/Watch", healthCheckRequest("/mnt/a"))
+	req.Header.Set("Content-Type", "application/grpc")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	frame := make([]byte, 7)
+	if _, err := io.ReadFull(res.Body, frame); err != nil || frame[6] != grpcServing {
+		t.Fatalf("expected a serving status first, got %x, error %v", frame, err)
+	}
+	w.mu.Lock()
+	w.lastHealthy["/mnt/a"] = false
+	w.mu.Unlock()
+	if _, err := io.ReadFull(res.Body, frame); err != nil || frame[6] != grpcNotServing {
+		t.Fatalf("expected the change to not serving, got %x, error %v", frame, err)
+	}
+}
@@ -1,18 +1,76 @@
 package internal
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
+)
+
+// Health states answered by the health endpoints.
+const (
+	HealthOK        = "ok"
+	HealthUnhealthy = "unhealthy"
+	HealthUnknown   = "unknown" // not checked yet
 )
 
 type HealthHandlers struct {
 	watchdog           *Watchdog
 	healthPath         string
 	mountPointsSubpath string
+	minHealthyCount    int
+	unknownStatus      int
+	graceUntil         time.Time
 }
 
 func NewHealthHandler(watchdog *Watchdog, healthPath, mountPointsSubpath string) *HealthHandlers {
-	return &HealthHandlers{watchdog, healthPath, mountPointsSubpath}
+	return &HealthHandlers{watchdog: watchdog, healthPath: healthPath, mountPointsSubpath: mountPointsSubpath,
+		unknownStatus: http.StatusServiceUnavailable}
+}
+
+// SetMinHealthyCount switches the global endpoint to quorum mode: healthy as
+// long as at least n mount points are, regardless of which. 0 restores the
+// default, where all mount points must be healthy.
+func (s *HealthHandlers) SetMinHealthyCount(n int) {
+	s.minHealthyCount = n
+}
+
+// SetUnknownStatus sets the HTTP status answered while the health is unknown,
+// before the first check of the mount points, e.g. 200 so that deploys do not
+// flap. With a grace period, it applies for that long from now only; an
+// unknown health then counts as unhealthy.
+func (s *HealthHandlers) SetUnknownStatus(status int, grace time.Duration) {
+	s.unknownStatus = status
+	if grace > 0 {
+		s.graceUntil = time.Now().Add(grace)
+	}
+}
+
+// stateStatus returns the HTTP status answered for a health state.
+func (s *HealthHandlers) stateStatus(state string) int {
+	switch {
+	case state == HealthOK:
+		return http.StatusOK
+	case state == HealthUnknown && (s.graceUntil.IsZero() || time.Now().Before(s.graceUntil)):
+		return s.unknownStatus
+	}
+	return http.StatusServiceUnavailable
+}
+
+// state returns the global health state, ignoring draining. In quorum mode, it
+// is unknown while the mount points not checked yet could still make it.
+func (s *HealthHandlers) state() (state string, healthy, total int) {
+	if s.minHealthyCount == 0 {
+		return s.watchdog.HealthState(), 0, 0
+	}
+	healthy, unknown, total := s.watchdog.HealthCounts()
+	switch {
+	case healthy >= s.minHealthyCount:
+		return HealthOK, healthy, total
+	case healthy+unknown >= s.minHealthyCount:
+		return HealthUnknown, healthy, total
+	}
+	return HealthUnhealthy, healthy, total
 }
 
 func (s *HealthHandlers) HandleMountPoints(w http.ResponseWriter, r *http.Request) {
@@ -28,31 +86,76 @@ func (s *HealthHandlers) HandleMountPoints(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Ensure leading slash: "var/vcap/store/dir" -> "/var/vcap/store/dir"
-	mp := "/" + strings.TrimPrefix(raw, "/")
-
-	healthy, ok := s.watchdog.IsMountHealthy(mp)
+	mp, state, ok := s.lookup(raw)
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
 
-	if healthy {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok\n"))
-	} else {
+	if s.watchdog.IsMountPaused(mp) {
 		w.WriteHeader(http.StatusServiceUnavailable)
-		_, _ = w.Write([]byte("unhealthy\n"))
+		_, _ = w.Write([]byte("paused\n"))
+		return
 	}
+	if s.watchdog.IsMountPending(mp) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("pending\n"))
+		return
+	}
+	w.WriteHeader(s.stateStatus(state))
+	_, _ = w.Write([]byte(state + "\n"))
+}
+
+// lookup resolves a mount point given by path, with or without its leading
+// slash, or by alias, and returns its path and health state.
+func (s *HealthHandlers) lookup(raw string) (mp, state string, ok bool) {
+	// Ensure leading slash: "var/vcap/store/dir" -> "/var/vcap/store/dir"
+	mp = "/" + strings.TrimPrefix(raw, "/")
+
+	state, ok = s.watchdog.MountHealthState(mp)
+	if !ok {
+		// Fall back to an alias: /health/mount-points/appdata
+		if path, found := s.watchdog.LookupAlias(strings.Trim(raw, "/")); found {
+			mp = path
+			state, ok = s.watchdog.MountHealthState(mp)
+		}
+	}
+	return mp, state, ok
+}
+
+// Healthy reports the global health as served by HandleMain: not draining,
+// and all mount points healthy or, in quorum mode, enough of them. An unknown
+// health counts as healthy if answered with a success status.
+func (s *HealthHandlers) Healthy() bool {
+	if s.watchdog.IsDraining() {
+		return false
+	}
+	state, _, _ := s.state()
+	return s.stateStatus(state) < http.StatusBadRequest
+}
+
+// MountHealthy reports the health of a mount point given by path or alias as
+// served by HandleMountPoints: paused and pending mount points are unhealthy.
+// ok is false for an unknown mount point.
+func (s *HealthHandlers) MountHealthy(name string) (healthy, ok bool) {
+	mp, state, ok := s.lookup(name)
+	if !ok {
+		return false, false
+	}
+	return s.stateStatus(state) < http.StatusBadRequest && !s.watchdog.IsMountPaused(mp) && !s.watchdog.IsMountPending(mp), true
 }
 
 func (s *HealthHandlers) HandleMain(w http.ResponseWriter, _ *http.Request) {
-	if s.watchdog.IsHealthy() {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok\n"))
-	} else {
+	if s.watchdog.IsDraining() {
 		w.WriteHeader(http.StatusServiceUnavailable)
-		_, _ = w.Write([]byte("unhealthy\n"))
+		_, _ = w.Write([]byte("draining\n"))
+		return
 	}
-
+	state, healthy, total := s.state()
+	w.WriteHeader(s.stateStatus(state))
+	if s.minHealthyCount > 0 {
+		_, _ = fmt.Fprintf(w, "%s: %d of %d mount points healthy, at least %d required\n", state, healthy, total, s.minHealthyCount)
+		return
+	}
+	_, _ = w.Write([]byte(state + "\n"))
 }
@@ -6,13 +6,20 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
-// helper to build a minimal watchdog without touching Prometheus
+// helper to build a minimal watchdog without touching Prometheus, the mount
+// points in healthyMap checked
 func newTestWatchdog(mountPoints []string, healthyMap map[string]bool) *Watchdog {
+	checked := make(map[string]bool, len(healthyMap))
+	for mp := range healthyMap {
+		checked[mp] = true
+	}
 	return &Watchdog{
-		mountPoints: mountPoints,
+		mountPoints: testMountPoints(mountPoints...),
 		lastHealthy: healthyMap,
+		checked:     checked,
 	}
 }
 
@@ -196,3 +203,157 @@ func TestHandleMountPoints_UnhealthyMount(t *testing.T) {
 		t.Fatalf("expected body %q, got %q", "unhealthy\n", string(body))
 	}
 }
+
+func TestHandleMountPoints_Alias(t *testing.T) {
+	mp := "/var/vcap/store/tenant-12345/data"
+
+	watchdog := newTestWatchdog(
+		[]string{mp},
+		map[string]bool{
+			mp: true,
+		},
+	)
+	watchdog.aliases = map[string]string{"appdata": mp}
+	h := NewHealthHandler(watchdog, "/health", "mount-points/")
+
+	for _, path := range []string{"/health/mount-points/appdata", "/health/mount-points/var/vcap/store/tenant-12345/data"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.HandleMountPoints(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestHandleMain_Draining(t *testing.T) {
+	watchdog := newTestWatchdog(
+		[]string{"/mnt/a"},
+		map[string]bool{
+			"/mnt/a": true,
+		},
+	)
+	watchdog.draining = true
+
+	h := NewHealthHandler(watchdog, "/health", "mount-points")
+
+	rec := httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if rec.Body.String() != "draining\n" {
+		t.Fatalf("expected body %q, got %q", "draining\n", rec.Body.String())
+	}
+}
+
+func TestHandleMountPoints_PendingMount(t *testing.T) {
+	mp := "/var/vcap/store/job"
+
+	watchdog := newTestWatchdog([]string{mp}, map[string]bool{mp: false})
+	watchdog.pending = map[string]bool{mp: true}
+
+	h := NewHealthHandler(watchdog, "/health", "mount-points")
+
+	rec := httptest.NewRecorder()
+	h.HandleMountPoints(rec, httptest.NewRequest(http.MethodGet, "/health/mount-points"+mp, nil))
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "pending\n" {
+		t.Errorf("expected 503 pending, got %d %q", rec.Code, rec.Body.String())
+	}
+	if !watchdog.IsHealthy() {
+		t.Errorf("expected a pending mount point not to affect global health")
+	}
+}
+
+func TestHandleMain_MinHealthyCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		healthy    map[string]bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"quorum", map[string]bool{"/mnt/a": true, "/mnt/b": true, "/mnt/c": false}, http.StatusOK,
+			"ok: 2 of 3 mount points healthy, at least 2 required\n"},
+		{"below quorum", map[string]bool{"/mnt/a": true, "/mnt/b": false, "/mnt/c": false}, http.StatusServiceUnavailable,
+			"unhealthy: 1 of 3 mount points healthy, at least 2 required\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchdog := newTestWatchdog([]string{"/mnt/a", "/mnt/b", "/mnt/c"}, tt.healthy)
+			h := NewHealthHandler(watchdog, "/health", "mount-points")
+			h.SetMinHealthyCount(2)
+
+			rec := httptest.NewRecorder()
+			h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleMain_Unknown(t *testing.T) {
+	watchdog := newTestWatchdog([]string{"/mnt/a", "/mnt/b"}, map[string]bool{"/mnt/a": true})
+	watchdog.lastHealthy["/mnt/b"] = false // not checked yet
+
+	h := NewHealthHandler(watchdog, "/health", "mount-points")
+	rec := httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "unknown\n" {
+		t.Errorf("expected 503 unknown by default, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	h.SetUnknownStatus(http.StatusOK, time.Hour)
+	rec = httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "unknown\n" {
+		t.Errorf("expected 200 unknown within the grace period, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.HandleMountPoints(rec, httptest.NewRequest(http.MethodGet, "/health/mount-points/mnt/b", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "unknown\n" {
+		t.Errorf("expected 200 unknown for the unchecked mount point, got %d %q", rec.Code, rec.Body.String())
+	}
+	if !h.Healthy() {
+		t.Error("expected an unknown health answered with 200 to count as healthy")
+	}
+
+	h.graceUntil = time.Now().Add(-time.Second)
+	rec = httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected an unknown health to count as unhealthy after the grace period, got %d", rec.Code)
+	}
+
+	// A failed mount point makes the health unhealthy, whatever is still unknown.
+	watchdog.checked["/mnt/a"], watchdog.lastHealthy["/mnt/a"] = true, false
+	h.SetUnknownStatus(http.StatusOK, 0)
+	rec = httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "unhealthy\n" {
+		t.Errorf("expected 503 unhealthy, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleMain_MinHealthyCountUnknown(t *testing.T) {
+	watchdog := newTestWatchdog([]string{"/mnt/a", "/mnt/b", "/mnt/c"}, map[string]bool{"/mnt/a": true, "/mnt/b": false})
+	watchdog.lastHealthy["/mnt/c"] = false // not checked yet
+
+	h := NewHealthHandler(watchdog, "/health", "mount-points")
+	h.SetMinHealthyCount(2)
+	h.SetUnknownStatus(http.StatusOK, 0)
+
+	rec := httptest.NewRecorder()
+	h.HandleMain(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if want := "unknown: 1 of 3 mount points healthy, at least 2 required\n"; rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("expected 200 %q while the quorum can still be met, got %d %q", want, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxServerHold bounds the duration of a server hold, so a forgotten hold
+// cannot silence a server indefinitely.
+const MaxServerHold = 24 * time.Hour
+
+// ServerHold freezes the reported health of the mount points of an NFS server,
+// e.g. during a planned reboot. Checks keep running and record the real state.
+type ServerHold struct {
+	Server string    `json:"server"`
+	Until  time.Time `json:"until"`
+}
+
+// HoldServer freezes the reported health of all mount points served by server
+// at their last value until now+duration, replacing an existing hold.
+func (m *Watchdog) HoldServer(server string, duration time.Duration, now time.Time) ServerHold {
+	hold := ServerHold{Server: server, Until: now.Add(duration)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holds[server] = hold.Until
+	m.nfsServerHoldUntil.WithLabelValues(server).Set(float64(hold.Until.Unix()))
+	return hold
+}
+
+// ReleaseServer ends the hold of server and reports whether there was one.
+// The next check reports the real state again.
+func (m *Watchdog) ReleaseServer(server string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.holds[server]
+	m.releaseServer(server)
+	return ok
+}
+
+// ServerHolds returns the active holds sorted by server.
+func (m *Watchdog) ServerHolds(now time.Time) []ServerHold {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	holds := make([]ServerHold, 0, len(m.holds))
+	for server, until := range m.holds {
+		if now.Before(until) {
+			holds = append(holds, ServerHold{Server: server, Until: until})
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].Server < holds[j].Server })
+	return holds
+}
+
+// serverHeld reports whether server has an active hold, releasing it once
+// expired. The caller must hold m.mu for writing.
+func (m *Watchdog) serverHeld(server string, now time.Time) bool {
+	until, ok := m.holds[server]
+	if !ok {
+		return false
+	}
+	if !now.Before(until) {
+		m.releaseServer(server)
+		return false
+	}
+	return true
+}
+
+// releaseServer removes the hold of server. The caller must hold m.mu for writing.
+func (m *Watchdog) releaseServer(server string) {
+	delete(m.holds, server)
+	m.nfsServerHoldUntil.DeleteLabelValues(server)
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServerHoldFreezesReportedHealth(t *testing.T) {
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw,hard 0 0\n")
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	w.CheckAll()
+	if !w.IsHealthy() {
+		t.Fatalf("expected the mount point to be healthy")
+	}
+
+	w.HoldServer("nfs1", time.Minute, time.Now())
+	if err := os.WriteFile(mountsFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.CheckAll()
+	if !w.IsHealthy() {
+		t.Errorf("expected the reported health to be held")
+	}
+	if got := testutil.ToFloat64(w.nfsMountActual.WithLabelValues(dir, dir)); got != 0 {
+		t.Errorf("expected mount_healthy_actual 0 while held, got %v", got)
+	}
+	if status := w.Status()[0]; !status.Held || status.Error == "" {
+		t.Errorf("expected a held status with the real error, got %+v", status)
+	}
+	if holds := w.ServerHolds(time.Now()); len(holds) != 1 || holds[0].Server != "nfs1" {
+		t.Errorf("unexpected holds %+v", holds)
+	}
+
+	if !w.ReleaseServer("nfs1") || w.ReleaseServer("nfs1") {
+		t.Errorf("expected exactly one hold to be released")
+	}
+	w.CheckAll()
+	if w.IsHealthy() {
+		t.Errorf("expected the real state to be reported after the release")
+	}
+}
+
+func TestServerHoldExpires(t *testing.T) {
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw,hard 0 0\n")
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	w.CheckAll()
+
+	w.HoldServer("nfs1", time.Minute, time.Now().Add(-2*time.Minute))
+	if err := os.WriteFile(mountsFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.CheckAll()
+	if w.IsHealthy() {
+		t.Errorf("expected an expired hold not to freeze the reported health")
+	}
+	if n := testutil.CollectAndCount(w.nfsServerHoldUntil); n != 0 {
+		t.Errorf("expected the expired hold series to be deleted, got %d", n)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// hookWaitDelay bounds the wait for the output of a hook after it ended or
+// was killed on timeout.
+const hookWaitDelay = time.Second
+
+// HookRunner runs the on-unhealthy and on-healthy commands of a mount point
+// when it changes state, e.g. to stop dependent services or trigger custom
+// remediation. Hooks run on their own goroutine, bounded by a timeout; the
+// hooks of one mount point never overlap.
+type HookRunner struct {
+	watchdog   *Watchdog
+	timeout    time.Duration
+	mu         sync.Mutex
+	running    map[string]*sync.Mutex
+	hooksTotal *prometheus.CounterVec
+}
+
+func NewHookRunner(namespace string, watchdog *Watchdog, timeout time.Duration) *HookRunner {
+	return &HookRunner{
+		watchdog: watchdog,
+		timeout:  timeout,
+		running:  make(map[string]*sync.Mutex),
+		hooksTotal: promauto.With(watchdog.Registerer()).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "hooks_total",
+				Help:      "Number of state change hook commands run by result (success, failed, timeout, error)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+// Run starts the hook of the new state of a mount point, if it has one,
+// without waiting for it. It is registered as a state change listener.
+func (h *HookRunner) Run(change StateChange) {
+	mp := h.watchdog.mountPoint(change.MountPoint)
+	command := mp.OnHealthy
+	if !change.Healthy {
+		command = mp.OnUnhealthy
+	}
+	if command == "" {
+		return
+	}
+
+	h.mu.Lock()
+	lock, ok := h.running[mp.Path]
+	if !ok {
+		lock = &sync.Mutex{}
+		h.running[mp.Path] = lock
+	}
+	h.mu.Unlock()
+
+	go func() {
+		lock.Lock()
+		defer lock.Unlock()
+		h.run(mp, change, command)
+	}()
+}
+
+// run executes command with sh, the mount point as its first argument and the
+// state change in the environment.
+func (h *HookRunner) run(mp MountPoint, change StateChange, command string) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command, "nfsma-hook", mp.Path)
+	cmd.Env = append(os.Environ(),
+		"NFSMA_MOUNTPOINT="+mp.Path,
+		"NFSMA_NAME="+mp.Name(),
+		"NFSMA_HEALTHY="+strconv.FormatBool(change.Healthy),
+		"NFSMA_ERROR="+change.Error,
+	)
+	// Children of the shell may keep the output open after it was killed.
+	cmd.WaitDelay = hookWaitDelay
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	attrs := []any{"mountpoint", mp.Path, "healthy", change.Healthy, "duration", time.Since(start).Seconds(), "output", string(out)}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		h.hooksTotal.WithLabelValues("success").Inc()
+		slog.Info("hook succeeded", attrs...)
+	case ctx.Err() != nil:
+		h.hooksTotal.WithLabelValues("timeout").Inc()
+		slog.Error("hook timed out", append(attrs, "timeout", h.timeout.String())...)
+	case errors.As(err, &exitErr):
+		h.hooksTotal.WithLabelValues("failed").Inc()
+		slog.Error("hook failed", append(attrs, "exit_code", exitErr.ExitCode())...)
+	default:
+		h.hooksTotal.WithLabelValues("error").Inc()
+		slog.Error("cannot run hook", append(attrs, "error", err.Error())...)
+	}
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHookRunner(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	mp := MountPoint{
+		Path:        "/mnt/a",
+		Alias:       "a",
+		OnUnhealthy: `echo "$1 $NFSMA_NAME $NFSMA_HEALTHY $NFSMA_ERROR" > ` + out,
+		OnHealthy:   "exit 3",
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second})
+	h := NewHookRunner("test_ns", w, time.Second)
+
+	h.Run(StateChange{MountPoint: mp.Path, Healthy: false, PreviousHealthy: true, Error: "stale"})
+	waitForCounter(t, func() float64 { return testutil.ToFloat64(h.hooksTotal.WithLabelValues("success")) }, 1)
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(content); got != "/mnt/a a false stale\n" {
+		t.Errorf("expected the mount point as argument and the change in the environment, got %q", got)
+	}
+
+	h.Run(StateChange{MountPoint: mp.Path, Healthy: true})
+	waitForCounter(t, func() float64 { return testutil.ToFloat64(h.hooksTotal.WithLabelValues("failed")) }, 1)
+
+	// Mount points without hooks run nothing.
+	h.Run(StateChange{MountPoint: "/mnt/other", Healthy: false})
+}
+
+func TestHookRunnerTimeout(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a", OnUnhealthy: "sleep 5"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second})
+	h := NewHookRunner("test_ns", w, 50*time.Millisecond)
+
+	h.run(mp, StateChange{MountPoint: mp.Path}, mp.OnUnhealthy)
+	if got := testutil.ToFloat64(h.hooksTotal.WithLabelValues("timeout")); got != 1 {
+		t.Errorf("expected a timed out hook, got %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package internal
+
+// checkStreak counts the consecutive passed or failed checks of a mount point.
+type checkStreak struct {
+	passed int
+	failed int
+}
+
+// dampHealth records the result of a check and returns the health to report:
+// a healthy mount point turns unhealthy after failureThreshold consecutive
+// failed checks, an unhealthy one healthy after successThreshold consecutive
+// passed checks. The first check of a mount point is reported as is. flapped
+// reports a change between passing and failing checks, also one absorbed by
+// the thresholds.
+func (m *Watchdog) dampHealth(mountPoint string, err error) (healthy bool, flapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	streak := m.streaks[mountPoint]
+	if err == nil {
+		flapped = streak.failed > 0
+		streak = checkStreak{passed: streak.passed + 1}
+	} else {
+		flapped = streak.passed > 0
+		streak = checkStreak{failed: streak.failed + 1}
+	}
+	m.streaks[mountPoint] = streak
+
+	switch previous := m.lastHealthy[mountPoint]; {
+	case !m.checked[mountPoint]:
+		healthy = err == nil
+	case previous:
+		healthy = streak.failed < m.failureThreshold
+	default:
+		healthy = streak.passed >= m.successThreshold
+	}
+	return healthy, flapped
+}
+
+// HealthThresholds returns the consecutive failed and passed checks needed to
+// change the reported health.
+func (m *Watchdog) HealthThresholds() (failure, success int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.failureThreshold, m.successThreshold
+}
+
+// SetHealthThresholds changes the thresholds of dampHealth, applied from the
+// next check on. Streaks counted so far are kept.
+func (m *Watchdog) SetHealthThresholds(failure, success int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failureThreshold = max(failure, 1)
+	m.successThreshold = max(success, 1)
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestThresholdsDampHealthChanges(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{FailureThreshold: 3, SuccessThreshold: 2})
+	var checkErr error
+	w.check = func(MountPoint, *mountTable) error { return checkErr }
+	var changes []StateChange
+	w.OnStateChange(func(c StateChange) { changes = append(changes, c) })
+
+	steps := []struct {
+		err     error
+		healthy bool
+	}{
+		{nil, true}, // the first check is reported as is
+		{errors.New("stat failed"), true},
+		{errors.New("stat failed"), true},
+		{nil, true}, // restarts the failure count
+		{errors.New("stat failed"), true},
+		{errors.New("stat failed"), true},
+		{errors.New("stat failed"), false},
+		{nil, false},
+		{errors.New("stat failed"), false},
+		{nil, false},
+		{nil, true},
+	}
+	for i, step := range steps {
+		checkErr = step.err
+		w.CheckMountPoint(mp)
+		if healthy, _ := w.IsMountHealthy(mp.Path); healthy != step.healthy {
+			t.Fatalf("check %d: expected healthy=%t", i, step.healthy)
+		}
+	}
+
+	if len(changes) != 2 {
+		t.Errorf("expected 2 state changes, got %+v", changes)
+	}
+	if got := testutil.ToFloat64(w.nfsFlapsTotal.WithLabelValues(mp.Path, mp.Path)); got != 6 {
+		t.Errorf("expected 6 flaps, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsMountActual.WithLabelValues(mp.Path, mp.Path)); got != 1 {
+		t.Errorf("expected the actual result to follow every check, got %v", got)
+	}
+}
+
+func TestDefaultThresholdsReportEveryResult(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{})
+	var checkErr error
+	w.check = func(MountPoint, *mountTable) error { return checkErr }
+
+	for i, err := range []error{nil, errors.New("stat failed"), nil} {
+		checkErr = err
+		w.CheckMountPoint(mp)
+		if healthy, _ := w.IsMountHealthy(mp.Path); healthy != (err == nil) {
+			t.Fatalf("check %d: expected healthy=%t", i, err == nil)
+		}
+	}
+}
+
+func TestSetHealthThresholds(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{})
+	checkErr := error(nil)
+	w.check = func(MountPoint, *mountTable) error { return checkErr }
+	w.CheckMountPoint(mp)
+
+	w.SetHealthThresholds(2, 0)
+	if failure, success := w.HealthThresholds(); failure != 2 || success != 1 {
+		t.Fatalf("expected thresholds 2 and 1, got %d and %d", failure, success)
+	}
+	checkErr = errors.New("stat failed")
+	w.CheckMountPoint(mp)
+	if healthy, _ := w.IsMountHealthy(mp.Path); !healthy {
+		t.Fatal("expected the new failure threshold to absorb one failed check")
+	}
+	w.CheckMountPoint(mp)
+	if healthy, _ := w.IsMountHealthy(mp.Path); healthy {
+		t.Fatal("expected unhealthy after 2 failed checks")
+	}
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// InfluxPusher periodically POSTs the watchdog state in InfluxDB line protocol,
+// for environments ingesting Influx/Telegraf instead of scraping Prometheus.
+// It reads the snapshot of the last checks and never waits on the check loop.
+type InfluxPusher struct {
+	url         string
+	interval    time.Duration
+	measurement string
+	client      *http.Client
+	watchdog    *Watchdog
+	pushesTotal *prometheus.CounterVec
+}
+
+// NewInfluxPusher pushes to url every interval. The namespace prefixes the
+// measurements: <namespace>_mount per mount point and <namespace>_agent.
+func NewInfluxPusher(namespace, url string, interval time.Duration, watchdog *Watchdog) *InfluxPusher {
+	return &InfluxPusher{
+		url:         url,
+		interval:    interval,
+		measurement: namespace,
+		// A push never outlives its interval, so pushes cannot pile up.
+		client:   &http.Client{Timeout: interval},
+		watchdog: watchdog,
+
+		pushesTotal: promauto.With(watchdog.Registerer()).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "influx_pushes_total",
+				Help:      "Number of InfluxDB line protocol pushes by result (success, failed)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+// Run pushes the current state every interval until ctx is cancelled.
+func (p *InfluxPusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.push(ctx, time.Now()); err != nil {
+				p.pushesTotal.WithLabelValues("failed").Inc()
+				slog.Warn("influx push failed", "error", err.Error())
+			} else {
+				p.pushesTotal.WithLabelValues("success").Inc()
+			}
+		}
+	}
+}
+
+func (p *InfluxPusher) push(ctx context.Context, now time.Time) error {
+	var body bytes.Buffer
+	p.writeLines(&body, now)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("influx endpoint returned %s", res.Status)
+	}
+	return nil
+}
+
+// writeLines serializes the watchdog state as line protocol: one agent line
+// at now, and one line per checked mount point at the time of its last check.
+//
+//	nfsma_agent healthy=1i,draining=0i 1700000000000000000
+//	nfsma_mount,mountpoint=/data,name=data healthy=1i,optional=false,check_duration_seconds=0.002 1700000000000000000
+func (p *InfluxPusher) writeLines(buf *bytes.Buffer, now time.Time) {
+	fmt.Fprintf(buf, "%s healthy=%s,draining=%s %d\n", escapeMeasurement(p.measurement+"_agent"),
+		influxInt(p.watchdog.IsHealthy()), influxInt(p.watchdog.IsDraining()), now.UnixNano())
+
+	for _, s := range p.watchdog.Status() {
+		if s.LastCheck == nil {
+			continue
+		}
+
+		buf.WriteString(escapeMeasurement(p.measurement + "_mount"))
+		fmt.Fprintf(buf, ",mountpoint=%s,name=%s", escapeTag(s.MountPoint), escapeTag(s.Name))
+		keys := make([]string, 0, len(s.Tags))
+		for key := range s.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Empty tag values are not allowed in line protocol.
+			if s.Tags[key] != "" {
+				fmt.Fprintf(buf, ",%s=%s", escapeTag(key), escapeTag(s.Tags[key]))
+			}
+		}
+
+		fmt.Fprintf(buf, " healthy=%s,optional=%t,check_duration_seconds=%s",
+			influxInt(s.Healthy), s.Optional, strconv.FormatFloat(s.CheckDuration, 'g', -1, 64))
+		if s.Error != "" {
+			fmt.Fprintf(buf, ",error=%s", quoteField(s.Error))
+		}
+		fmt.Fprintf(buf, " %d\n", s.LastCheck.UnixNano())
+	}
+}
+
+func influxInt(b bool) string {
+	if b {
+		return "1i"
+	}
+	return "0i"
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	fieldEscaper       = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func escapeMeasurement(s string) string { return measurementEscaper.Replace(s) }
+func escapeTag(s string) string         { return tagEscaper.Replace(s) }
+func quoteField(s string) string        { return `"` + fieldEscaper.Replace(s) + `"` }
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInfluxWriteLines(t *testing.T) {
+	checked := time.Unix(1700000000, 0)
+	w := newTestWatchdog(nil, map[string]bool{"/mnt/a": true, "/mnt/my share": false, "/mnt/new": false})
+	w.mountPoints = []MountPoint{
+		{Path: "/mnt/a", Tags: map[string]string{"team": "payments", "tier": ""}},
+		{Path: "/mnt/my share", Alias: "share", Optional: true},
+		{Path: "/mnt/new"},
+	}
+	w.lastChecks = map[string]checkResult{
+		"/mnt/a":        {at: checked, duration: 2 * time.Millisecond},
+		"/mnt/my share": {at: checked, duration: time.Second, err: `stat failed: "x"`},
+	}
+
+	var buf bytes.Buffer
+	NewInfluxPusher("nfsma", "http://127.0.0.1:0", time.Second, w).writeLines(&buf, time.Unix(1700000001, 0))
+
+	want := "" +
+		"nfsma_agent healthy=0i,draining=0i 1700000001000000000\n" +
+		"nfsma_mount,mountpoint=/mnt/a,name=/mnt/a,team=payments healthy=1i,optional=false,check_duration_seconds=0.002 1700000000000000000\n" +
+		`nfsma_mount,mountpoint=/mnt/my\ share,name=share healthy=0i,optional=true,check_duration_seconds=1,error="stat failed: \"x\"" 1700000000000000000` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected line protocol:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInfluxPusherRun(t *testing.T) {
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case received <- string(body):
+		default:
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p := NewInfluxPusher("test_ns", srv.URL, 10*time.Millisecond, newTestWatchdog([]string{"/mnt/a"}, map[string]bool{"/mnt/a": true}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	select {
+	case body := <-received:
+		if !strings.HasPrefix(body, "test_ns_agent healthy=1i") {
+			t.Errorf("unexpected body %q", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("nothing was pushed")
+	}
+	// Later pushes may already have succeeded, so wait for at least one.
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(p.pushesTotal.WithLabelValues("success")) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a successful push to be counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
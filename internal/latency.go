@@ -0,0 +1,33 @@
+package internal
+
+import "time"
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencyWindow keeps check durations observed within a sliding time window.
+type latencyWindow struct {
+	window  time.Duration
+	samples []latencySample
+}
+
+// add records a duration observed at now, prunes samples older than the window
+// and returns the maximum duration still in the window.
+func (w *latencyWindow) add(now time.Time, d time.Duration) time.Duration {
+	w.samples = append(w.samples, latencySample{at: now, duration: d})
+
+	cutoff := now.Add(-w.window)
+	keep := 0
+	for keep < len(w.samples) && !w.samples[keep].at.After(cutoff) {
+		keep++
+	}
+	w.samples = w.samples[keep:]
+
+	var slowest time.Duration
+	for _, s := range w.samples {
+		slowest = max(slowest, s.duration)
+	}
+	return slowest
+}
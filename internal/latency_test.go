@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindowKeepsMaximumWithinWindow(t *testing.T) {
+	w := &latencyWindow{window: 5 * time.Minute}
+	start := time.Unix(1000, 0)
+
+	if got := w.add(start, 2*time.Second); got != 2*time.Second {
+		t.Errorf("expected 2s, got %s", got)
+	}
+	if got := w.add(start.Add(time.Minute), 100*time.Millisecond); got != 2*time.Second {
+		t.Errorf("expected spike to be kept within window, got %s", got)
+	}
+	if got := w.add(start.Add(5*time.Minute), 300*time.Millisecond); got != 300*time.Millisecond {
+		t.Errorf("expected spike to expire after window, got %s", got)
+	}
+	if len(w.samples) != 2 {
+		t.Errorf("expected expired samples to be pruned, got %d samples", len(w.samples))
+	}
+}
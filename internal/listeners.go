@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Endpoint groups selectable per listener, besides the AuthGroups.
+const (
+	ListenGroupAdmin = "admin" // admin API
+	ListenGroupPprof = "pprof" // Go profiling and debug endpoints under /debug/, see HandleDebug
+)
+
+// ListenGroups lists the endpoint groups a listener can serve.
+var ListenGroups = []string{AuthGroupMetrics, AuthGroupHealth, AuthGroupStatus, ListenGroupAdmin, ListenGroupPprof}
+
+// ListenSpec is an HTTP listener and the endpoint groups it serves. Network
+// is "tcp", or "unix" for a Unix domain socket at the Address path.
+type ListenSpec struct {
+	Network string
+	Address string
+	Groups  []string
+}
+
+// ParseListenSpec parses address=group,group, e.g. 127.0.0.1:9100=metrics,admin.
+// Without groups, the listener serves all of them except pprof, as the single
+// listener of --listen-address does.
+func ParseListenSpec(value string) (ListenSpec, error) {
+	return parseListenSpec("tcp", value)
+}
+
+// ParseSocketSpec parses path=group,group of a Unix domain socket listener,
+// e.g. /run/nfsma.sock=health,status, with the same groups as ParseListenSpec.
+func ParseSocketSpec(value string) (ListenSpec, error) {
+	spec, err := parseListenSpec("unix", value)
+	if err == nil && !filepath.IsAbs(spec.Address) {
+		return ListenSpec{}, fmt.Errorf("socket path must be absolute: %q", spec.Address)
+	}
+	return spec, err
+}
+
+func parseListenSpec(network, value string) (ListenSpec, error) {
+	address, groups, found := strings.Cut(value, "=")
+	if address == "" {
+		return ListenSpec{}, fmt.Errorf("listen address required in %q", value)
+	}
+	spec := ListenSpec{Network: network, Address: address}
+	if !found {
+		spec.Groups = DefaultListenGroups()
+		return spec, nil
+	}
+	for _, group := range splitList(groups) {
+		if !slices.Contains(ListenGroups, group) {
+			return ListenSpec{}, fmt.Errorf("unknown endpoint group %q, expected %s", group, strings.Join(ListenGroups, ", "))
+		}
+		if !slices.Contains(spec.Groups, group) {
+			spec.Groups = append(spec.Groups, group)
+		}
+	}
+	if len(spec.Groups) == 0 {
+		return ListenSpec{}, fmt.Errorf("no endpoint group in %q", value)
+	}
+	return spec, nil
+}
+
+// DefaultListenGroups returns the groups of a listener without explicit
+// groups: all but pprof.
+func DefaultListenGroups() []string {
+	return slices.DeleteFunc(slices.Clone(ListenGroups), func(group string) bool { return group == ListenGroupPprof })
+}
+
+// Listen opens the listener of spec. A socket file left by an agent that did
+// not shut down cleanly is replaced, one in use by a running agent is not. The
+// socket gets mode and is removed when the listener is closed.
+func Listen(spec ListenSpec, mode os.FileMode) (net.Listener, error) {
+	if spec.Network != "unix" {
+		return net.Listen("tcp", spec.Address)
+	}
+	if info, err := os.Lstat(spec.Address); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", spec.Address)
+		}
+		if conn, err := net.DialTimeout("unix", spec.Address, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", spec.Address)
+		}
+		if err := os.Remove(spec.Address); err != nil {
+			return nil, fmt.Errorf("cannot remove the stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", spec.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(spec.Address, mode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Router collects the HTTP handlers by endpoint group and builds the mux of
+// each listener from the groups it serves.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	group   string
+	pattern string
+	handler http.Handler
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for pattern in the endpoint group.
+func (r *Router) Handle(group, pattern string, handler http.Handler) {
+	r.routes = append(r.routes, route{group: group, pattern: pattern, handler: handler})
+}
+
+// Mux returns a mux serving the routes of groups.
+func (r *Router) Mux(groups []string) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, route := range r.routes {
+		if slices.Contains(groups, route.group) {
+			mux.Handle(route.pattern, route.handler)
+		}
+	}
+	return mux
+}
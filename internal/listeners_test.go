@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseListenSpec(t *testing.T) {
+	spec, err := ParseListenSpec("127.0.0.1:9100=metrics, admin,metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (ListenSpec{Network: "tcp", Address: "127.0.0.1:9100", Groups: []string{"metrics", "admin"}}); !reflect.DeepEqual(spec, want) {
+		t.Errorf("expected %+v, got %+v", want, spec)
+	}
+
+	spec, err = ParseListenSpec("0.0.0.0:9090")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"metrics", "health", "status", "admin"}; !reflect.DeepEqual(spec.Groups, want) {
+		t.Errorf("expected all groups but pprof without explicit groups, got %v", spec.Groups)
+	}
+
+	for _, invalid := range []string{"", "=health", "0.0.0.0:9090=", "0.0.0.0:9090=debug"} {
+		if _, err := ParseListenSpec(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestParseSocketSpec(t *testing.T) {
+	spec, err := ParseSocketSpec("/run/nfsma.sock=health,status")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (ListenSpec{Network: "unix", Address: "/run/nfsma.sock", Groups: []string{"health", "status"}}); !reflect.DeepEqual(spec, want) {
+		t.Errorf("expected %+v, got %+v", want, spec)
+	}
+	if _, err := ParseSocketSpec("nfsma.sock"); err == nil {
+		t.Error("expected a relative socket path to be rejected")
+	}
+}
+
+func TestListenSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nfsma.sock")
+	spec := ListenSpec{Network: "unix", Address: path}
+
+	// A socket left behind by an agent that did not shut down cleanly.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("cannot create the stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := Listen(spec, 0o600)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("cannot stat the socket: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if _, err := Listen(spec, 0o600); err == nil {
+		t.Error("expected a socket in use to be rejected")
+	}
+
+	_ = ln.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed on close, got %v", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(spec, 0o600); err == nil {
+		t.Error("expected a regular file at the socket path to be rejected")
+	}
+}
+
+func TestRouterMux(t *testing.T) {
+	router := NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	router.Handle(AuthGroupHealth, "/health", ok)
+	router.Handle(AuthGroupMetrics, "/metrics", ok)
+
+	mux := router.Mux([]string{AuthGroupHealth})
+	for path, want := range map[string]int{"/health": http.StatusOK, "/metrics": http.StatusNotFound, "/debug/pprof/": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.Mux([]string{ListenGroupPprof}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected no pprof index unless enabled, got %d", rec.Code)
+	}
+}
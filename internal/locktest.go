@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lockFilePrefix starts the name of every lock test file, followed by the pid
+// of the agent and a nanosecond timestamp.
+const lockFilePrefix = ".nfs_mounter_lock_"
+
+// errLockTest marks a failed lock test.
+var errLockTest = errors.New("lock test failed")
+
+// lockTest takes and releases a POSIX lock in the check directory of mp,
+// timing it when the lock test is enabled. A lock file that cannot be removed
+// only fails the test with strict cleanup, like a write test probe file.
+func (m *Watchdog) lockTest(mp MountPoint) error {
+	if m.nfsLockTestDuration != nil {
+		timer := prometheus.NewTimer(m.nfsLockTestDuration.WithLabelValues(m.labels.values(mp)...))
+		defer timer.ObserveDuration()
+	}
+
+	err := probeLock(mp.CheckDir())
+	if errors.Is(err, errProbeCleanup) && !m.strictCleanup {
+		slog.Warn("lock test cleanup failed", "mountpoint", mp.Path, "error", err.Error())
+		return nil
+	}
+	return err
+}
+
+// probeLock creates a file in dir, takes an exclusive POSIX lock on it,
+// releases the lock and removes the file. On NFS the lock goes to the server
+// (the lock manager for NFSv3, the server itself for NFSv4), so a broken lock
+// service fails or hangs here while plain writes still pass.
+func probeLock(dir string) error {
+	name := fmt.Sprintf("%s%d_%d", lockFilePrefix, os.Getpid(), time.Now().UnixNano())
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	lockErr := lockUnlock(f)
+	if err := f.Close(); err != nil && lockErr == nil {
+		lockErr = err
+	}
+	if err := removeProbe(path); err != nil && lockErr == nil {
+		return fmt.Errorf("%w: %w", errProbeCleanup, err)
+	}
+	return lockErr
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockUnlock takes a non-blocking exclusive fcntl lock on the whole of f and
+// releases it again.
+func lockUnlock(f *os.File) error {
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock); err != nil {
+		return fmt.Errorf("fcntl(F_SETLK, F_WRLCK) on %s failed: %w", f.Name(), err)
+	}
+	lock.Type = unix.F_UNLCK
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock); err != nil {
+		return fmt.Errorf("fcntl(F_SETLK, F_UNLCK) on %s failed: %w", f.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package internal
+
+import (
+	"errors"
+	"os"
+)
+
+func lockUnlock(*os.File) error {
+	return errors.New("the lock test is only supported on linux")
+}
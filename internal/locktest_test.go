@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProbeLock(t *testing.T) {
+	dir := t.TempDir()
+	if err := probeLock(dir); err != nil {
+		t.Fatalf("expected the lock test to pass in a local directory, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the lock file to be removed, found %d entries", len(entries))
+	}
+	if err := probeLock("/nonexistent"); err == nil {
+		t.Error("expected the lock test to fail in a missing directory")
+	}
+}
+
+func TestCheckRunsLockTest(t *testing.T) {
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw 0 0\n")
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:  time.Second,
+		EnableLockTest: true,
+		MountsFile:     mountsFile,
+	})
+	if err := w.checkMounted(mp, readMountTable(w.mounts)); err != nil {
+		t.Fatalf("expected the check with a lock test to pass, got %v", err)
+	}
+	if got := testutil.CollectAndCount(w.nfsLockTestDuration); got != 1 {
+		t.Errorf("expected one lock test duration series, got %d", got)
+	}
+
+	// A lock file left behind is only logged, unless cleanup is strict.
+	removeProbe = func(string) error { return errors.New("permission denied") }
+	t.Cleanup(func() { removeProbe = os.Remove })
+	if err := w.checkMounted(mp, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected a cleanup failure to pass without strict cleanup, got %v", err)
+	}
+	w.strictCleanup = true
+	err := w.checkMounted(mp, readMountTable(w.mounts))
+	if err == nil || !strings.HasPrefix(err.Error(), "lock test failed on "+dir) || errorReason(err) != reasonLockFailed {
+		t.Errorf("expected a lock_failed error with strict cleanup, got %v", err)
+	}
+
+	w.deleteSeries(mp.Path)
+	if got := testutil.CollectAndCount(w.nfsLockTestDuration); got != 0 {
+		t.Errorf("expected the lock test series to be deleted, got %d", got)
+	}
+}
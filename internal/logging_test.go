@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("mount point unhealthy", "mountpoint", "/mnt/a")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["level"] != "WARN" || record["msg"] != "mount point unhealthy" || record["mountpoint"] != "/mnt/a" {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	buf.Reset()
+	if logger, err = NewLogger(&buf, "text", "DEBUG"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Debug("check passed", "mountpoint", "/mnt/a")
+	if !strings.Contains(buf.String(), "level=DEBUG") || !strings.Contains(buf.String(), "mountpoint=/mnt/a") {
+		t.Errorf("unexpected text record %q", buf.String())
+	}
+
+	for _, tt := range [][2]string{{"xml", "info"}, {"json", "verbose"}} {
+		if _, err := NewLogger(&buf, tt[0], tt[1]); err == nil {
+			t.Errorf("expected an error for format %q and level %q", tt[0], tt[1])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+// WithTimeout answers 503 when h does not finish within timeout, releasing the
+// connection even if the handler is stuck on a hung mount. A non-positive
+// timeout returns h unchanged.
+func WithTimeout(h http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return h
+	}
+	return http.TimeoutHandler(h, timeout, "handler timeout\n")
+}
+
+// RequireBearerToken rejects requests without an "Authorization: Bearer <token>" header
+// matching token.
+func RequireBearerToken(h http.Handler, token string) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
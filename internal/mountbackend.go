@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Mount backends selectable with NewMountBackend.
+const (
+	MountBackendSyscall = "syscall" // mount(2) and umount2(2), see syscallMountBackend
+	MountBackendExec    = "exec"    // the mount and umount commands and their /sbin/mount.nfs helper
+)
+
+// MountBackends lists the mount backends.
+var MountBackends = []string{MountBackendSyscall, MountBackendExec}
+
+// MountBackend mounts and unmounts the remount sources of the mount points,
+// for MountOnStartup, UnmountAll and the remount attempts.
+type MountBackend interface {
+	// Mount mounts the remount source of mp on its path with its remount
+	// options.
+	Mount(ctx context.Context, mp MountPoint) error
+	// Remount applies the remount options of mp to its mount in place.
+	Remount(ctx context.Context, mp MountPoint) error
+	// Unmount unmounts path. Force aborts the requests pending on an
+	// unresponsive server, lazy detaches the mount at once even when busy.
+	Unmount(ctx context.Context, path string, force, lazy bool) error
+}
+
+// NewMountBackend returns the mount backend called name. The syscall backend
+// falls back to the exec one for options only the mount.nfs helper handles,
+// see mountHelperReason, and on platforms other than linux.
+func NewMountBackend(name string) (MountBackend, error) {
+	switch name {
+	case MountBackendSyscall:
+		return syscallMountBackend{fallback: execMountBackend{}}, nil
+	case MountBackendExec:
+		return execMountBackend{}, nil
+	}
+	return nil, fmt.Errorf("unknown mount backend %q, expected %s", name, strings.Join(MountBackends, ", "))
+}
+
+// execMountBackend runs the mount and umount commands, which hand NFS mounts
+// to /sbin/mount.nfs: version negotiation, Kerberos credentials and the rest
+// of nfs-utils apply.
+type execMountBackend struct{}
+
+func (execMountBackend) Mount(ctx context.Context, mp MountPoint) error {
+	args := []string{"-t", "nfs"}
+	if len(mp.RemountOptions) > 0 {
+		args = append(args, "-o", strings.Join(mp.RemountOptions, ","))
+	}
+	args = append(args, mp.RemountSource, mp.Path)
+	return runMountCommand(ctx, "mount", args...)
+}
+
+func (execMountBackend) Remount(ctx context.Context, mp MountPoint) error {
+	return runMountCommand(ctx, "mount", "-o", strings.Join(append([]string{"remount"}, mp.RemountOptions...), ","), mp.Path)
+}
+
+func (execMountBackend) Unmount(ctx context.Context, path string, force, lazy bool) error {
+	var args []string
+	if force {
+		args = append(args, "-f")
+	}
+	if lazy {
+		args = append(args, "-l")
+	}
+	return runMountCommand(ctx, "umount", append(args, path)...)
+}
+
+// mountHelperReason returns why mounting with options needs the mount.nfs
+// helper rather than mount(2), or "" when the kernel handles them alone:
+// Kerberos needs the credentials gssd sets up, and without vers= only the
+// helper negotiates the NFS version the server supports.
+func mountHelperReason(options []string) string {
+	versioned := false
+	for _, opt := range options {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "sec":
+			if strings.HasPrefix(value, "krb5") {
+				return "Kerberos security " + value
+			}
+		case "vers", "nfsvers":
+			versioned = true
+		}
+	}
+	if !versioned {
+		return "no vers= option"
+	}
+	return ""
+}
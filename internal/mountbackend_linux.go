@@ -0,0 +1,137 @@
+//go:build linux
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// syscallMountBackend mounts NFSv3 and NFSv4 sources with mount(2), passing
+// the options to the kernel NFS client as the mount.nfs helper does, without
+// needing nfs-utils in the agent's environment. Options needing the helper
+// go to fallback.
+type syscallMountBackend struct {
+	fallback MountBackend
+}
+
+func (b syscallMountBackend) Mount(ctx context.Context, mp MountPoint) error {
+	if reason := mountHelperReason(mp.RemountOptions); reason != "" {
+		slog.Debug("mounting with the mount command", "mountpoint", mp.Path, "reason", reason)
+		return b.fallback.Mount(ctx, mp)
+	}
+	// The kernel does not resolve host names, the server address goes in addr=.
+	addrs, err := MountEntry{Source: mp.RemountSource, Options: mp.RemountOptions}.serverAddrs()
+	if err != nil {
+		return fmt.Errorf("cannot resolve the NFS server of %s: %w", mp.RemountSource, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address for the NFS server of %s", mp.RemountSource)
+	}
+	flags, data := nfsMountData(mp.RemountOptions)
+	if !slices.ContainsFunc(data, func(opt string) bool { return strings.HasPrefix(opt, "addr=") }) {
+		data = append(data, "addr="+addrs[0].String())
+	}
+	return runSyscall(ctx, "mount "+mp.RemountSource+" "+mp.Path, func() error {
+		return syscall.Mount(mp.RemountSource, mp.Path, "nfs", flags, strings.Join(data, ","))
+	})
+}
+
+func (b syscallMountBackend) Remount(ctx context.Context, mp MountPoint) error {
+	if reason := mountHelperReason(mp.RemountOptions); strings.HasPrefix(reason, "Kerberos") {
+		slog.Debug("remounting with the mount command", "mountpoint", mp.Path, "reason", reason)
+		return b.fallback.Remount(ctx, mp)
+	}
+	flags, data := nfsMountData(mp.RemountOptions)
+	return runSyscall(ctx, "remount "+mp.Path, func() error {
+		return syscall.Mount(mp.RemountSource, mp.Path, "nfs", flags|syscall.MS_REMOUNT, strings.Join(data, ","))
+	})
+}
+
+func (b syscallMountBackend) Unmount(ctx context.Context, path string, force, lazy bool) error {
+	var flags int
+	if force {
+		flags |= syscall.MNT_FORCE
+	}
+	if lazy {
+		flags |= syscall.MNT_DETACH
+	}
+	return runSyscall(ctx, "umount "+path, func() error {
+		return syscall.Unmount(path, flags)
+	})
+}
+
+// runSyscall runs a mount or unmount system call, giving up when ctx is done.
+// A call blocked on an unresponsive server keeps its goroutine until the
+// kernel gives up too.
+func runSyscall(ctx context.Context, op string, call func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- call() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+// mountFlags are the generic mount options mount(2) takes as flags rather
+// than as data, by option: the flag and whether the option sets it.
+var mountFlags = map[string]struct {
+	flag uintptr
+	set  bool
+}{
+	"ro":          {syscall.MS_RDONLY, true},
+	"rw":          {syscall.MS_RDONLY, false},
+	"nosuid":      {syscall.MS_NOSUID, true},
+	"suid":        {syscall.MS_NOSUID, false},
+	"nodev":       {syscall.MS_NODEV, true},
+	"dev":         {syscall.MS_NODEV, false},
+	"noexec":      {syscall.MS_NOEXEC, true},
+	"exec":        {syscall.MS_NOEXEC, false},
+	"sync":        {syscall.MS_SYNCHRONOUS, true},
+	"async":       {syscall.MS_SYNCHRONOUS, false},
+	"dirsync":     {syscall.MS_DIRSYNC, true},
+	"noatime":     {syscall.MS_NOATIME, true},
+	"atime":       {syscall.MS_NOATIME, false},
+	"nodiratime":  {syscall.MS_NODIRATIME, true},
+	"diratime":    {syscall.MS_NODIRATIME, false},
+	"relatime":    {syscall.MS_RELATIME, true},
+	"norelatime":  {syscall.MS_RELATIME, false},
+	"strictatime": {syscall.MS_STRICTATIME, true},
+}
+
+// userspaceOptions are handled by mount, fstab tooling or mount.nfs and
+// rejected by the kernel NFS client.
+var userspaceOptions = map[string]bool{
+	"defaults": true, "auto": true, "noauto": true, "user": true, "nouser": true, "users": true,
+	"owner": true, "group": true, "_netdev": true, "nofail": true, "bg": true, "fg": true, "retry": true,
+}
+
+// nfsMountData splits mount options into the mount(2) flags and the option
+// data of the kernel NFS client, dropping the options of userspace tools.
+func nfsMountData(options []string) (flags uintptr, data []string) {
+	for _, opt := range options {
+		key, _, _ := strings.Cut(opt, "=")
+		if f, ok := mountFlags[opt]; ok {
+			if f.set {
+				flags |= f.flag
+			} else {
+				flags &^= f.flag
+			}
+			continue
+		}
+		if userspaceOptions[key] || strings.HasPrefix(key, "x-") || key == "comment" {
+			continue
+		}
+		data = append(data, opt)
+	}
+	return flags, data
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package internal
+
+import (
+	"context"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func TestNFSMountData(t *testing.T) {
+	flags, data := nfsMountData([]string{"ro", "nosuid", "noatime", "_netdev", "nofail", "x-systemd.automount", "vers=4.2", "hard", "timeo=600", "defaults"})
+	if want := uintptr(syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NOATIME); flags != want {
+		t.Errorf("expected flags %#x, got %#x", want, flags)
+	}
+	if want := []string{"vers=4.2", "hard", "timeo=600"}; !slices.Equal(data, want) {
+		t.Errorf("expected data %q, got %q", want, data)
+	}
+
+	if flags, _ := nfsMountData([]string{"ro", "rw"}); flags != 0 {
+		t.Errorf("expected a later rw to clear ro, got %#x", flags)
+	}
+}
+
+func TestSyscallMountBackendFallsBack(t *testing.T) {
+	commands := fakeMountCommands(t, nil)
+	backend := syscallMountBackend{fallback: execMountBackend{}}
+	mp := MountPoint{Path: "/mnt/a", RemountSource: "nfs1:/export", RemountOptions: []string{"vers=4.1", "sec=krb5"}}
+
+	if err := backend.Mount(context.Background(), mp); err != nil {
+		t.Fatalf("expected the mount command to run: %v", err)
+	}
+	if want := []string{"mount -t nfs -o vers=4.1,sec=krb5 nfs1:/export /mnt/a"}; !slices.Equal(*commands, want) {
+		t.Errorf("expected %q, got %q", want, *commands)
+	}
+}
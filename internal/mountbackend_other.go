@@ -0,0 +1,23 @@
+//go:build !linux
+
+package internal
+
+import "context"
+
+// syscallMountBackend uses fallback on platforms other than linux, whose
+// mount(2) does not take NFS options as text.
+type syscallMountBackend struct {
+	fallback MountBackend
+}
+
+func (b syscallMountBackend) Mount(ctx context.Context, mp MountPoint) error {
+	return b.fallback.Mount(ctx, mp)
+}
+
+func (b syscallMountBackend) Remount(ctx context.Context, mp MountPoint) error {
+	return b.fallback.Remount(ctx, mp)
+}
+
+func (b syscallMountBackend) Unmount(ctx context.Context, path string, force, lazy bool) error {
+	return b.fallback.Unmount(ctx, path, force, lazy)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestNewMountBackend(t *testing.T) {
+	for _, name := range MountBackends {
+		if _, err := NewMountBackend(name); err != nil {
+			t.Errorf("expected backend %q: %v", name, err)
+		}
+	}
+	if _, err := NewMountBackend("fuse"); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
+
+func TestExecMountBackendCommands(t *testing.T) {
+	commands := fakeMountCommands(t, nil)
+	backend := execMountBackend{}
+	mp := MountPoint{Path: "/mnt/a", RemountSource: "nfs1:/export", RemountOptions: []string{"hard", "vers=4.1"}}
+	ctx := context.Background()
+
+	_ = backend.Mount(ctx, mp)
+	_ = backend.Remount(ctx, mp)
+	_ = backend.Unmount(ctx, mp.Path, false, false)
+	_ = backend.Unmount(ctx, mp.Path, false, true)
+	_ = backend.Unmount(ctx, mp.Path, true, false)
+	want := []string{
+		"mount -t nfs -o hard,vers=4.1 nfs1:/export /mnt/a",
+		"mount -o remount,hard,vers=4.1 /mnt/a",
+		"umount /mnt/a",
+		"umount -l /mnt/a",
+		"umount -f /mnt/a",
+	}
+	if !slices.Equal(*commands, want) {
+		t.Errorf("expected %q, got %q", want, *commands)
+	}
+}
+
+func TestMountHelperReason(t *testing.T) {
+	for _, tc := range []struct {
+		options []string
+		helper  bool
+	}{
+		{[]string{"hard", "vers=3"}, false},
+		{[]string{"nfsvers=4.2", "sec=sys"}, false},
+		{[]string{"hard"}, true},
+		{[]string{"vers=4.1", "sec=krb5p"}, true},
+	} {
+		if got := mountHelperReason(tc.options); (got != "") != tc.helper {
+			t.Errorf("options %q: expected the helper %v, got %q", tc.options, tc.helper, got)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Delays between rounds of initial mount attempts, doubling after every
+// round. Variables so tests do not wait.
+var (
+	mountRetryDelay    = time.Second
+	maxMountRetryDelay = time.Minute
+)
+
+// MountAll mounts the remount source of every mount point that is not mounted
+// yet, retrying failed mounts with a doubling delay until all are mounted or
+// ctx is cancelled. Mount points without a remount source and absent mount
+// points are left alone. It reports whether all mounts succeeded.
+func (m *Watchdog) MountAll(ctx context.Context) bool {
+	var pending []MountPoint
+	table := readMountTable(m.mounts)
+	for _, mp := range m.MountPoints() {
+		if mp.RemountSource == "" || mp.Absent {
+			continue
+		}
+		entry, err := table.find(mp.Path)
+		switch {
+		case err == nil && entry.Source == mp.RemountSource:
+			slog.Info("already mounted", "mountpoint", mp.Path, "source", mp.RemountSource)
+			continue
+		case err == nil:
+			// Something else is mounted, the check reports it.
+			slog.Warn("not mounting over another mount", "mountpoint", mp.Path, "source", mp.RemountSource, "mounted", entry.describe())
+			continue
+		case !errors.Is(err, errMountNotFound):
+			slog.Warn("mounting although the mount table is unusable", "mountpoint", mp.Path, "source", mp.RemountSource, "error", err.Error())
+		}
+		pending = append(pending, mp)
+	}
+
+	delay := mountRetryDelay
+	for len(pending) > 0 {
+		var failed []MountPoint
+		for _, mp := range pending {
+			if err := m.mountOnce(ctx, mp); err != nil {
+				slog.Warn("mount failed", "mountpoint", mp.Path, "source", mp.RemountSource, "error", err.Error())
+				failed = append(failed, mp)
+				continue
+			}
+			slog.Info("mounted", "mountpoint", mp.Path, "source", mp.RemountSource)
+		}
+		if pending = failed; len(pending) == 0 {
+			break
+		}
+		slog.Info("retrying mounts", "count", len(pending), "delay", delay.String())
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxMountRetryDelay)
+	}
+	return true
+}
+
+// mountOnce creates the mount point directory and mounts its source, bounded
+// by remountTimeout.
+func (m *Watchdog) mountOnce(ctx context.Context, mp MountPoint) error {
+	if err := os.MkdirAll(mp.Path, 0o755); err != nil {
+		return fmt.Errorf("cannot create the mount point: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, remountTimeout)
+	defer cancel()
+	return m.mountBackend.Mount(ctx, mp)
+}
+
+// UnmountAll unmounts the mount points whose remount source is mounted, the
+// mounts the agent manages, e.g. when the agent stops. A mount that is busy or
+// whose server does not answer is detached lazily instead.
+func (m *Watchdog) UnmountAll() {
+	table := readMountTable(m.mounts)
+	for _, mp := range m.MountPoints() {
+		if mp.RemountSource == "" || mp.Absent {
+			continue
+		}
+		if entry, err := table.find(mp.Path); err != nil || entry.Source != mp.RemountSource {
+			continue
+		}
+		if err := m.unmount(mp); err != nil {
+			slog.Warn("unmount failed", "mountpoint", mp.Path, "error", err.Error())
+			continue
+		}
+		slog.Info("unmounted", "mountpoint", mp.Path, "source", mp.RemountSource)
+	}
+}
+
+// unmount unmounts mp, falling back to a forced lazy unmount, each bounded by
+// remountTimeout.
+func (m *Watchdog) unmount(mp MountPoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remountTimeout)
+	err := m.mountBackend.Unmount(ctx, mp.Path, false, false)
+	cancel()
+	if err == nil {
+		return nil
+	}
+	slog.Warn("unmount failed, detaching lazily", "mountpoint", mp.Path, "error", err.Error())
+	ctx, cancel = context.WithTimeout(context.Background(), remountTimeout)
+	defer cancel()
+	return m.mountBackend.Unmount(ctx, mp.Path, true, true)
+}
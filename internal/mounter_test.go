@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fastMountRetries(t *testing.T) {
+	t.Helper()
+	delay, maxDelay := mountRetryDelay, maxMountRetryDelay
+	mountRetryDelay, maxMountRetryDelay = time.Millisecond, 2*time.Millisecond
+	t.Cleanup(func() { mountRetryDelay, maxMountRetryDelay = delay, maxDelay })
+}
+
+func TestMountAllMountsMissingSources(t *testing.T) {
+	commands := fakeMountCommands(t, nil)
+
+	dir := t.TempDir()
+	missing := MountPoint{Path: filepath.Join(dir, "missing"), RemountSource: "nfs1:/export/a", RemountOptions: []string{"hard"}}
+	mounted := MountPoint{Path: "/mnt/mounted", RemountSource: "nfs1:/export/b"}
+	other := MountPoint{Path: "/mnt/other", RemountSource: "nfs1:/export/c"}
+	points := []MountPoint{missing, mounted, other, {Path: "/mnt/plain"}, {Path: "/mnt/absent", RemountSource: "nfs1:/export/d", Absent: true}}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{
+		MountsFile: writeMountsFixture(t, "nfs1:/export/b /mnt/mounted nfs4 rw 0 0\nnfs2:/export /mnt/other nfs4 rw 0 0\n"),
+	})
+
+	if !w.MountAll(context.Background()) {
+		t.Fatalf("expected all mounts to succeed")
+	}
+	want := "mount -t nfs -o hard nfs1:/export/a " + missing.Path
+	if len(*commands) != 1 || (*commands)[0] != want {
+		t.Errorf("expected only %q, got %q", want, *commands)
+	}
+}
+
+func TestMountAllRetriesUntilMounted(t *testing.T) {
+	fastMountRetries(t)
+	attempts := 0
+	original := runMountCommand
+	runMountCommand = func(_ context.Context, name string, args ...string) error {
+		if attempts++; attempts < 3 {
+			return errors.New("mount.nfs: Connection timed out")
+		}
+		return nil
+	}
+	t.Cleanup(func() { runMountCommand = original })
+
+	mp := MountPoint{Path: filepath.Join(t.TempDir(), "a"), RemountSource: "nfs1:/export/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{MountsFile: writeMountsFixture(t, "")})
+
+	if !w.MountAll(context.Background()) {
+		t.Fatalf("expected the mount to succeed eventually")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestMountAllStopsOnCancellation(t *testing.T) {
+	fastMountRetries(t)
+	commands := fakeMountCommands(t, errors.New("mount.nfs: access denied"))
+
+	mp := MountPoint{Path: filepath.Join(t.TempDir(), "a"), RemountSource: "nfs1:/export/a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{MountsFile: writeMountsFixture(t, "")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if w.MountAll(ctx) {
+		t.Fatalf("expected MountAll to give up on cancellation")
+	}
+	if len(*commands) < 2 || !strings.HasPrefix((*commands)[0], "mount -t nfs nfs1:/export/a ") {
+		t.Errorf("expected repeated mount attempts, got %q", *commands)
+	}
+}
+
+func TestUnmountAllUnmountsManagedMounts(t *testing.T) {
+	commands := fakeMountCommands(t, nil)
+
+	points := []MountPoint{
+		{Path: "/mnt/managed", RemountSource: "nfs1:/export/a"},
+		{Path: "/mnt/foreign", RemountSource: "nfs1:/export/b"},
+		{Path: "/mnt/missing", RemountSource: "nfs1:/export/c"},
+		{Path: "/mnt/plain"},
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{
+		MountsFile: writeMountsFixture(t, ""+
+			"nfs1:/export/a /mnt/managed nfs4 rw 0 0\n"+
+			"nfs2:/export /mnt/foreign nfs4 rw 0 0\n"+
+			"nfs1:/export/p /mnt/plain nfs4 rw 0 0\n"),
+	})
+
+	w.UnmountAll()
+	if len(*commands) != 1 || (*commands)[0] != "umount /mnt/managed" {
+		t.Errorf("expected only the managed mount to be unmounted, got %q", *commands)
+	}
+}
+
+func TestUnmountFallsBackToLazyUnmount(t *testing.T) {
+	var commands []string
+	original := runMountCommand
+	runMountCommand = func(_ context.Context, name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if len(args) == 1 {
+			return errors.New("umount: target is busy")
+		}
+		return nil
+	}
+	t.Cleanup(func() { runMountCommand = original })
+
+	w := &Watchdog{mountBackend: execMountBackend{}}
+	if err := w.unmount(MountPoint{Path: "/mnt/a"}); err != nil {
+		t.Fatalf("expected the lazy unmount to succeed: %v", err)
+	}
+	if len(commands) != 2 || commands[1] != "umount -f -l /mnt/a" {
+		t.Errorf("expected a lazy unmount after a failure, got %q", commands)
+	}
+}
@@ -0,0 +1,460 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MountPoint describes a monitored mount point together with its per-mount settings.
+type MountPoint struct {
+	Path string
+	// Alias is an optional short name used in metric labels and health URLs.
+	Alias string
+	// Optional mount points are checked and exported but do not affect global
+	// health. The critical setting is its inverse.
+	Optional bool
+	// Silenced mount points are in maintenance: checked and exported, but left
+	// out of global health and readiness, see SilenceMount.
+	Silenced bool
+	// CheckInterval overrides the global check interval for this mount point,
+	// e.g. 5s for a latency-sensitive mount or 5m for an archive; 0 uses the
+	// global one.
+	CheckInterval time.Duration
+	// Absent inverts the check: the mount point is healthy when it is not
+	// mounted, e.g. to confirm an old mount is gone before a deploy proceeds.
+	Absent bool
+	// DependsOn is a path, e.g. a VPN marker file or a parent mount, that must
+	// exist before the mount point is checked. Until then it is pending.
+	DependsOn string
+	// Tags are emitted as additional labels on all per-mount metric series.
+	Tags map[string]string
+	// WriteTest overrides the global write test setting for this mount point
+	// when set.
+	WriteTest *bool
+	// WriteTestDir is a directory relative to the check directory the write
+	// test writes its probe files to, created if missing, so they do not
+	// clutter a user-visible directory of the export. WriteTestSize is the
+	// size of the probe payload in bytes, 0 for a few bytes, and WriteFsync
+	// overrides the global fsync setting of the write test when set.
+	WriteTestDir  string
+	WriteTestSize int
+	WriteFsync    *bool
+	// EnclosingMount accepts Path below the mount point, e.g. application data
+	// bound into a subdirectory of an NFS mount: the nearest enclosing mount
+	// in the mount table is checked instead of one mounted on Path itself.
+	EnclosingMount bool
+	// CheckSubpath is a directory relative to Path that the stat, statfs and
+	// write checks target instead of the mount root, for apps that only use
+	// a subdirectory of a mount whose root may be read-only by design.
+	CheckSubpath string
+	// AllowedServerCIDRs pins the NFS server to known storage networks: when
+	// set, a mount served from an address outside all prefixes is unhealthy.
+	AllowedServerCIDRs []netip.Prefix
+	// RequireOptions lists mount options that must be present in /proc/mounts,
+	// either as a bare name ("hard") or as an exact key=value pair ("timeo=600").
+	RequireOptions []string
+	// ExpectOptions lists mount options the mount is expected to keep, in the
+	// same form as RequireOptions. A mismatch is exported and logged as drift,
+	// e.g. a silent fallback to ro or another NFS version, without failing
+	// the check.
+	ExpectOptions []string
+	// RemountSource is the NFS export ("server:/export") mounted again with
+	// RemountOptions when remounting is enabled and the checks keep failing.
+	RemountSource  string
+	RemountOptions []string
+	// OnUnhealthy and OnHealthy are shell commands run when the mount point
+	// turns unhealthy or healthy again, see HookRunner.
+	OnUnhealthy string
+	OnHealthy   string
+	// FSTypes lists the filesystem types accepted in the mount table, e.g.
+	// cifs, glusterfs or ceph; empty accepts NFS (nfs, nfs4).
+	FSTypes []string
+	// Origin tells where the mount point comes from, one of the Origin
+	// constants; empty counts as configured.
+	Origin string
+}
+
+// Origins of mount points. A reload replaces the mount points of the flags
+// and the config file only, see Watchdog.ReloadMountPoints.
+const (
+	OriginFlag       = "flag"       // --mount-point
+	OriginConfig     = "config"     // the config file
+	OriginRuntime    = "runtime"    // added with the admin API
+	OriginDiscovered = "discovered" // found by the Discoverer
+)
+
+// Name returns the alias, or the path when no alias is set.
+func (mp MountPoint) Name() string {
+	if mp.Alias != "" {
+		return mp.Alias
+	}
+	return mp.Path
+}
+
+// acceptsFSType reports whether a mount of the filesystem type passes the
+// check of mp.
+func (mp MountPoint) acceptsFSType(entry MountEntry) bool {
+	if len(mp.FSTypes) == 0 {
+		return entry.isNFS()
+	}
+	return slices.Contains(mp.FSTypes, entry.FSType)
+}
+
+// CheckDir returns the directory targeted by the checks: Path joined with CheckSubpath.
+func (mp MountPoint) CheckDir() string {
+	return filepath.Join(mp.Path, mp.CheckSubpath)
+}
+
+// WriteTestPath returns the directory of the write test probe files: CheckDir
+// joined with WriteTestDir.
+func (mp MountPoint) WriteTestPath() string {
+	return filepath.Join(mp.CheckDir(), mp.WriteTestDir)
+}
+
+// reservedLabels cannot be used as tag keys, as per-mount metrics already use them.
+var reservedLabels = map[string]bool{"mountpoint": true, "name": true, "result": true, "reason": true, "operation": true, "fstype": true, "server": true, "export": true, "window": true, "state": true, "action": true}
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// mountLabeler builds the labels identifying a mount point: mountpoint, name and
+// one label per tag key. The tag keys are fixed when the metrics are created, so
+// every series carries the same label set; mount points without a tag get "".
+type mountLabeler struct {
+	tagKeys []string
+}
+
+func newMountLabeler(points []MountPoint) mountLabeler {
+	return mountLabeler{tagKeys: TagKeys(points)}
+}
+
+// names returns the label names, followed by extra.
+func (l mountLabeler) names(extra ...string) []string {
+	names := append([]string{"mountpoint", "name"}, l.tagKeys...)
+	return append(names, extra...)
+}
+
+// values returns the label values of mp matching names, followed by extra.
+func (l mountLabeler) values(mp MountPoint, extra ...string) []string {
+	values := []string{mp.Path, mp.Name()}
+	for _, key := range l.tagKeys {
+		values = append(values, mp.Tags[key])
+	}
+	return append(values, extra...)
+}
+
+// TagKeys returns the sorted union of the tag keys of all mount points.
+func TagKeys(points []MountPoint) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, mp := range points {
+		for key := range mp.Tags {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseMountPoint parses a --mount-point value of the form PATH[=ALIAS][?key=value&...],
+// e.g. "/var/vcap/store/job=job?require-options=hard,timeo=600".
+// The settings start at the first "?" and the alias at the last "=" before
+// them, so a path can contain "=" when it has an alias. Otherwise "=", "?"
+// and "%" are written %3D, %3F and %25 in the path and the alias.
+func ParseMountPoint(value string) (MountPoint, error) {
+	spec, query, _ := strings.Cut(value, "?")
+	path, alias, hasAlias := spec, "", false
+	if i := strings.LastIndex(spec, "="); i >= 0 {
+		path, alias, hasAlias = spec[:i], spec[i+1:], true
+	}
+	if hasAlias && alias == "" {
+		return MountPoint{}, fmt.Errorf("mount point alias must not be empty: %q", value)
+	}
+	path, alias = mountPointUnescaper.Replace(path), mountPointUnescaper.Replace(alias)
+	mp := MountPoint{Path: path, Alias: alias}
+
+	settings, err := url.ParseQuery(query)
+	if err != nil {
+		return MountPoint{}, fmt.Errorf("invalid settings for mount point %q: %w", path, err)
+	}
+	for key, values := range settings {
+		switch key {
+		case "require-options":
+			for _, v := range values {
+				mp.RequireOptions = append(mp.RequireOptions, splitList(v)...)
+			}
+		case "expect-options":
+			for _, v := range values {
+				mp.ExpectOptions = append(mp.ExpectOptions, splitList(v)...)
+			}
+		case "allowed-server-cidr":
+			for _, v := range values {
+				prefixes, err := ParseCIDRs(splitList(v))
+				if err != nil {
+					return MountPoint{}, fmt.Errorf("invalid allowed-server-cidr for mount point %q: %w", path, err)
+				}
+				mp.AllowedServerCIDRs = append(mp.AllowedServerCIDRs, prefixes...)
+			}
+		case "write-test":
+			writeTest, err := parseFlagSetting(values)
+			if err != nil {
+				return MountPoint{}, fmt.Errorf("invalid write-test setting for mount point %q: %w", path, err)
+			}
+			mp.WriteTest = &writeTest
+		case "write-test-dir":
+			mp.WriteTestDir = values[len(values)-1]
+		case "write-test-size":
+			if mp.WriteTestSize, err = ParseByteSize(values[len(values)-1]); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid write-test-size for mount point %q: %w", path, err)
+			}
+		case "write-fsync":
+			writeFsync, err := parseFlagSetting(values)
+			if err != nil {
+				return MountPoint{}, fmt.Errorf("invalid write-fsync setting for mount point %q: %w", path, err)
+			}
+			mp.WriteFsync = &writeFsync
+		case "fstype":
+			for _, v := range values {
+				mp.FSTypes = append(mp.FSTypes, splitList(v)...)
+			}
+		case "on-unhealthy":
+			mp.OnUnhealthy = values[len(values)-1]
+		case "on-healthy":
+			mp.OnHealthy = values[len(values)-1]
+		case "remount-source":
+			mp.RemountSource = values[len(values)-1]
+		case "remount-options":
+			for _, v := range values {
+				mp.RemountOptions = append(mp.RemountOptions, splitList(v)...)
+			}
+		case "check-interval":
+			if mp.CheckInterval, err = time.ParseDuration(values[len(values)-1]); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid check-interval for mount point %q: %w", path, err)
+			}
+		case "depends-on":
+			mp.DependsOn = values[len(values)-1]
+		case "check-subpath":
+			mp.CheckSubpath = values[len(values)-1]
+		case "enclosing-mount":
+			if mp.EnclosingMount, err = parseFlagSetting(values); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid enclosing-mount setting for mount point %q: %w", path, err)
+			}
+		case "absent":
+			if mp.Absent, err = parseFlagSetting(values); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid absent setting for mount point %q: %w", path, err)
+			}
+		case "optional":
+			if mp.Optional, err = parseFlagSetting(values); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid optional setting for mount point %q: %w", path, err)
+			}
+		case "silenced":
+			if mp.Silenced, err = parseFlagSetting(values); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid silenced setting for mount point %q: %w", path, err)
+			}
+		case "critical":
+			// Resolved against optional below, settings come in no particular order.
+			if _, err = parseFlagSetting(values); err != nil {
+				return MountPoint{}, fmt.Errorf("invalid critical setting for mount point %q: %w", path, err)
+			}
+		default:
+			return MountPoint{}, fmt.Errorf("unknown setting %q for mount point %q", key, path)
+		}
+	}
+	if values, ok := settings["critical"]; ok {
+		critical, _ := parseFlagSetting(values)
+		if _, ok := settings["optional"]; ok && mp.Optional == critical {
+			return MountPoint{}, fmt.Errorf("optional and critical settings of mount point %q contradict each other", path)
+		}
+		mp.Optional = !critical
+	}
+	if err := mp.Validate(); err != nil {
+		return MountPoint{}, err
+	}
+	return mp, nil
+}
+
+// mountPointUnescaper decodes the characters ParseMountPoint takes as
+// separators. Other "%" sequences are kept literally.
+var mountPointUnescaper = strings.NewReplacer("%3D", "=", "%3d", "=", "%3F", "?", "%3f", "?", "%25", "%")
+
+// Validate checks the path and alias of a single mount point.
+func (mp MountPoint) Validate() error {
+	// Control characters, e.g. a newline injected by a templating bug, would
+	// never match the mount table and corrupt log lines and metric labels.
+	for _, field := range [][2]string{{"path", mp.Path}, {"alias", mp.Alias}, {"depends-on", mp.DependsOn}, {"check-subpath", mp.CheckSubpath}, {"write-test-dir", mp.WriteTestDir}, {"remount-source", mp.RemountSource}} {
+		if strings.IndexFunc(field[1], unicode.IsControl) >= 0 {
+			return fmt.Errorf("mount point %s must not contain control characters: %q", field[0], field[1])
+		}
+	}
+	if !filepath.IsAbs(mp.Path) {
+		return fmt.Errorf("mount point must be an absolute path: %q", mp.Path)
+	}
+	if strings.Contains(mp.Alias, "/") {
+		return fmt.Errorf("mount point alias must be a name without slashes: %q", mp.Alias)
+	}
+	if mp.CheckInterval < 0 {
+		return fmt.Errorf("check interval of mount point %q must be positive: %s", mp.Path, mp.CheckInterval)
+	}
+	if mp.DependsOn != "" && !filepath.IsAbs(mp.DependsOn) {
+		return fmt.Errorf("dependency of mount point %q must be an absolute path: %q", mp.Path, mp.DependsOn)
+	}
+	if mp.CheckSubpath != "" && (filepath.IsAbs(mp.CheckSubpath) || !filepath.IsLocal(mp.CheckSubpath)) {
+		return fmt.Errorf("check subpath of mount point %q must be a relative path inside the mount: %q", mp.Path, mp.CheckSubpath)
+	}
+	if mp.WriteTestDir != "" && (filepath.IsAbs(mp.WriteTestDir) || !filepath.IsLocal(mp.WriteTestDir)) {
+		return fmt.Errorf("write test directory of mount point %q must be a relative path inside the mount: %q", mp.Path, mp.WriteTestDir)
+	}
+	if mp.WriteTestSize < 0 || mp.WriteTestSize > MaxWriteTestSize {
+		return fmt.Errorf("write test size of mount point %q must be between 0 and %d bytes: %d", mp.Path, MaxWriteTestSize, mp.WriteTestSize)
+	}
+	if mp.EnclosingMount && mp.Absent {
+		return fmt.Errorf("mount point %q cannot be both absent and checked by its enclosing mount", mp.Path)
+	}
+	if mp.EnclosingMount && mp.RemountSource != "" {
+		return fmt.Errorf("mount point %q checked by its enclosing mount cannot be remounted", mp.Path)
+	}
+	if mp.RemountSource != "" {
+		if _, err := (MountEntry{Source: mp.RemountSource}).serverHost(); err != nil {
+			return fmt.Errorf("remount source of mount point %q must be an NFS export server:/export: %q", mp.Path, mp.RemountSource)
+		}
+	} else if len(mp.RemountOptions) > 0 {
+		return fmt.Errorf("remount options of mount point %q require a remount source", mp.Path)
+	}
+	for key := range mp.Tags {
+		if !labelNameRE.MatchString(key) || strings.HasPrefix(key, "__") || reservedLabels[key] {
+			return fmt.Errorf("invalid tag key %q for mount point %q", key, mp.Path)
+		}
+	}
+	return nil
+}
+
+// ValidateMountPoints checks that paths and aliases are unique.
+func ValidateMountPoints(points []MountPoint) error {
+	seen := make(map[string]string, 2*len(points))
+	for _, mp := range points {
+		for _, key := range []string{mp.Path, mp.Alias} {
+			if key == "" {
+				continue
+			}
+			if other, ok := seen[key]; ok {
+				return fmt.Errorf("mount point %q conflicts with %q", key, other)
+			}
+			seen[key] = mp.Path
+		}
+	}
+	return nil
+}
+
+// ParseCIDRs parses IPv4 and IPv6 network prefixes such as "10.0.0.0/8".
+func ParseCIDRs(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// disallowedAddr returns the first address not contained in any of the prefixes.
+func disallowedAddr(addrs []netip.Addr, prefixes []netip.Prefix) (netip.Addr, bool) {
+	for _, addr := range addrs {
+		allowed := false
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+func mountPointPaths(points []MountPoint) []string {
+	paths := make([]string, len(points))
+	for i, mp := range points {
+		paths[i] = mp.Path
+	}
+	return paths
+}
+
+// parseFlagSetting parses a boolean setting, where a bare key ("?optional") means true.
+func parseFlagSetting(values []string) (bool, error) {
+	value := values[len(values)-1]
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// MaxWriteTestSize bounds the write test payload, written on every check.
+const MaxWriteTestSize = 64 << 20
+
+// byteUnits are the unit suffixes of ParseByteSize, longest first.
+var byteUnits = []struct {
+	suffix string
+	size   int
+}{{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"K", 1 << 10}, {"M", 1 << 20}, {"k", 1 << 10}}
+
+// ParseByteSize parses a number of bytes with an optional binary unit, e.g.
+// 4096, 64KiB or 1M.
+func ParseByteSize(value string) (int, error) {
+	number, unit := strings.TrimSpace(value), 1
+	for _, u := range byteUnits {
+		if trimmed, ok := strings.CutSuffix(number, u.suffix); ok {
+			number, unit = strings.TrimSpace(trimmed), u.size
+			break
+		}
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 || n > math.MaxInt/unit {
+		return 0, fmt.Errorf("invalid size %q, expected bytes with an optional KiB or MiB unit", value)
+	}
+	return n * unit, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// missingOptions returns the required options not satisfied by the actual mount options.
+// A bare required option ("timeo") matches any value of that option ("timeo=600").
+func missingOptions(actual, required []string) []string {
+	var missing []string
+	for _, req := range required {
+		found := false
+		for _, opt := range actual {
+			if opt == req || (!strings.Contains(req, "=") && strings.HasPrefix(opt, req+"=")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, req)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,353 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMountPointPlainPath(t *testing.T) {
+	mp, err := ParseMountPoint("/var/vcap/store/job")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.Path != "/var/vcap/store/job" || len(mp.RequireOptions) != 0 {
+		t.Errorf("unexpected mount point %+v", mp)
+	}
+}
+
+func TestParseMountPointRequireOptions(t *testing.T) {
+	mp, err := ParseMountPoint("/data?require-options=hard,timeo=600")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.Path != "/data" {
+		t.Errorf("expected path /data, got %q", mp.Path)
+	}
+	if want := []string{"hard", "timeo=600"}; !reflect.DeepEqual(mp.RequireOptions, want) {
+		t.Errorf("expected require options %v, got %v", want, mp.RequireOptions)
+	}
+}
+
+func TestParseMountPointErrors(t *testing.T) {
+	for _, value := range []string{
+		"relative/path",
+		"/data?unknown=1",
+		"/data?require-options=%zz",
+		"/data?depends-on=relative",
+	} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointControlCharacters(t *testing.T) {
+	for _, value := range []string{
+		"/data\n",
+		"/da\x00ta",
+		"/data=job\t",
+		"/data?depends-on=/run/vpn%0A",
+		"/data?check-subpath=app%1B",
+	} {
+		_, err := ParseMountPoint(value)
+		if err == nil || !strings.Contains(err.Error(), "control characters") {
+			t.Errorf("expected control characters to be rejected in %q, got %v", value, err)
+		}
+	}
+}
+
+func TestMissingOptions(t *testing.T) {
+	actual := []string{"rw", "relatime", "vers=4.1", "hard", "timeo=600", "retrans=2"}
+
+	if missing := missingOptions(actual, []string{"hard", "timeo", "vers=4.1"}); len(missing) != 0 {
+		t.Errorf("expected no missing options, got %v", missing)
+	}
+
+	missing := missingOptions(actual, []string{"intr", "timeo=300", "retrans"})
+	if want := []string{"intr", "timeo=300"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("expected missing %v, got %v", want, missing)
+	}
+}
+
+func TestParseMountPointAlias(t *testing.T) {
+	mp, err := ParseMountPoint("/var/vcap/store/tenant-12345/data=appdata?require-options=hard")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.Path != "/var/vcap/store/tenant-12345/data" || mp.Alias != "appdata" {
+		t.Errorf("unexpected mount point %+v", mp)
+	}
+	if mp.Name() != "appdata" {
+		t.Errorf("expected name appdata, got %q", mp.Name())
+	}
+	if got := (MountPoint{Path: "/data"}).Name(); got != "/data" {
+		t.Errorf("expected name to default to the path, got %q", got)
+	}
+
+	for _, value := range []string{"/data=", "/data=a/b"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointSeparatorsInPath(t *testing.T) {
+	for _, tc := range []struct {
+		value, path, alias string
+		options            []string
+	}{
+		{"/data/key=value=appdata?require-options=hard", "/data/key=value", "appdata", []string{"hard"}},
+		{"/data/key%3Dvalue?require-options=hard", "/data/key=value", "", []string{"hard"}},
+		{"/data/what%3f=appdata?require-options=hard", "/data/what?", "appdata", []string{"hard"}},
+		{"/data/100%25%3F", "/data/100%?", "", nil},
+		{"/data/50%off=sale", "/data/50%off", "sale", nil},
+		{"/data=app%3Dv2?require-options=timeo=600?", "/data", "app=v2", []string{"timeo=600?"}},
+	} {
+		mp, err := ParseMountPoint(tc.value)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.value, err)
+			continue
+		}
+		if mp.Path != tc.path || mp.Alias != tc.alias {
+			t.Errorf("%q: expected path %q and alias %q, got %q and %q", tc.value, tc.path, tc.alias, mp.Path, mp.Alias)
+		}
+		if !reflect.DeepEqual(mp.RequireOptions, tc.options) {
+			t.Errorf("%q: expected the settings after the first ? to apply", tc.value)
+		}
+	}
+}
+
+func TestValidateMountPoints(t *testing.T) {
+	valid := []MountPoint{{Path: "/a", Alias: "a"}, {Path: "/b"}}
+	if err := ValidateMountPoints(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, points := range [][]MountPoint{
+		{{Path: "/a"}, {Path: "/a"}},
+		{{Path: "/a", Alias: "x"}, {Path: "/b", Alias: "x"}},
+	} {
+		if err := ValidateMountPoints(points); err == nil {
+			t.Errorf("expected conflict error for %+v", points)
+		}
+	}
+}
+
+func TestParseMountPointOptional(t *testing.T) {
+	for value, want := range map[string]bool{
+		"/archive":                               false,
+		"/archive?optional":                      true,
+		"/archive?optional=true":                 true,
+		"/archive=arch?optional=0":               false,
+		"/archive?optional&require-options=hard": true,
+		"/archive?critical=false":                true,
+		"/archive?critical":                      false,
+		"/archive?critical=true&optional=false":  false,
+	} {
+		mp, err := ParseMountPoint(value)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", value, err)
+		}
+		if mp.Optional != want {
+			t.Errorf("%q: expected optional=%t, got %t", value, want, mp.Optional)
+		}
+	}
+
+	if _, err := ParseMountPoint("/archive?optional=maybe"); err == nil {
+		t.Errorf("expected error for invalid optional value")
+	}
+	if _, err := ParseMountPoint("/archive?optional&critical"); err == nil {
+		t.Errorf("expected error for a mount point both optional and critical")
+	}
+}
+
+func TestMountLabelerTags(t *testing.T) {
+	points := []MountPoint{
+		{Path: "/a", Alias: "a", Tags: map[string]string{"team": "payments", "tier": "critical"}},
+		{Path: "/b", Tags: map[string]string{"team": "search"}},
+		{Path: "/c"},
+	}
+	l := newMountLabeler(points)
+
+	if want := []string{"mountpoint", "name", "team", "tier", "result"}; !reflect.DeepEqual(l.names("result"), want) {
+		t.Errorf("expected names %v, got %v", want, l.names("result"))
+	}
+	if want := []string{"/a", "a", "payments", "critical"}; !reflect.DeepEqual(l.values(points[0]), want) {
+		t.Errorf("expected values %v, got %v", want, l.values(points[0]))
+	}
+	if want := []string{"/c", "/c", "", "", "ok"}; !reflect.DeepEqual(l.values(points[2], "ok"), want) {
+		t.Errorf("expected values %v, got %v", want, l.values(points[2], "ok"))
+	}
+}
+
+func TestMountPointValidateTags(t *testing.T) {
+	if err := (MountPoint{Path: "/a", Tags: map[string]string{"team": "x", "_az": "1"}}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"mountpoint", "name", "result", "__meta", "1st", "with-dash"} {
+		if err := (MountPoint{Path: "/a", Tags: map[string]string{key: "x"}}).Validate(); err == nil {
+			t.Errorf("expected error for tag key %q", key)
+		}
+	}
+}
+
+func TestParseMountPointAbsent(t *testing.T) {
+	mp, err := ParseMountPoint("/var/vcap/store/old?absent")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mp.Absent {
+		t.Errorf("expected absent assertion")
+	}
+}
+
+func TestParseMountPointCheckSubpath(t *testing.T) {
+	mp, err := ParseMountPoint("/var/vcap/store/x?check-subpath=uploads/incoming")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mp.CheckDir(); got != "/var/vcap/store/x/uploads/incoming" {
+		t.Errorf("expected check dir below the mount, got %q", got)
+	}
+	if got := (MountPoint{Path: "/var/vcap/store/x"}).CheckDir(); got != "/var/vcap/store/x" {
+		t.Errorf("expected mount root without subpath, got %q", got)
+	}
+
+	for _, value := range []string{"/data?check-subpath=/abs", "/data?check-subpath=../other"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointWriteTestSettings(t *testing.T) {
+	mp, err := ParseMountPoint("/data?check-subpath=app&write-test-dir=.nfsma&write-test-size=64KiB&write-fsync=false")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mp.WriteTestPath(); got != "/data/app/.nfsma" {
+		t.Errorf("expected the write test dir below the check dir, got %q", got)
+	}
+	if mp.WriteTestSize != 64<<10 || mp.WriteFsync == nil || *mp.WriteFsync {
+		t.Errorf("expected a 64KiB payload without fsync, got %d, %v", mp.WriteTestSize, mp.WriteFsync)
+	}
+
+	for _, value := range []string{"/data?write-test-dir=../other", "/data?write-test-size=1GiB", "/data?write-test-size=128M", "/data?write-test-size=-1", "/data?write-fsync=maybe"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for value, want := range map[string]int{"4096": 4096, "4k": 4096, "64KiB": 64 << 10, "1M": 1 << 20, "2 MiB": 2 << 20, "0": 0} {
+		if got, err := ParseByteSize(value); err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, expected %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "KiB", "1.5M", "1G", "-4"} {
+		if _, err := ParseByteSize(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointWriteTest(t *testing.T) {
+	mp, err := ParseMountPoint("/data?write-test=false")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.WriteTest == nil || *mp.WriteTest {
+		t.Errorf("expected write test disabled, got %v", mp.WriteTest)
+	}
+	if mp, _ := ParseMountPoint("/data"); mp.WriteTest != nil {
+		t.Errorf("expected no write test override by default")
+	}
+}
+
+func TestParseMountPointAllowedServerCIDR(t *testing.T) {
+	mp, err := ParseMountPoint("/data?allowed-server-cidr=10.20.0.0/16,2001:db8::/32")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mp.AllowedServerCIDRs) != 2 {
+		t.Fatalf("expected 2 prefixes, got %v", mp.AllowedServerCIDRs)
+	}
+	if _, err := ParseMountPoint("/data?allowed-server-cidr=10.20.0.0"); err == nil {
+		t.Errorf("expected error for an address without prefix length")
+	}
+}
+
+func TestParseMountPointRemount(t *testing.T) {
+	mp, err := ParseMountPoint("/data?remount-source=nfs1:/export&remount-options=hard,vers=4.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.RemountSource != "nfs1:/export" || !reflect.DeepEqual(mp.RemountOptions, []string{"hard", "vers=4.1"}) {
+		t.Errorf("unexpected remount settings %+v", mp)
+	}
+	for _, value := range []string{"/data?remount-source=/dev/sda1", "/data?remount-options=hard"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointCheckInterval(t *testing.T) {
+	mp, err := ParseMountPoint("/archive?check-interval=5m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mp.CheckInterval != 5*time.Minute {
+		t.Errorf("expected check interval 5m, got %s", mp.CheckInterval)
+	}
+	for _, value := range []string{"/archive?check-interval=often", "/archive?check-interval=-5m"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointFSType(t *testing.T) {
+	mp, err := ParseMountPoint("/share?fstype=cifs,smb3&fstype=ceph")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(mp.FSTypes, []string{"cifs", "smb3", "ceph"}) {
+		t.Errorf("unexpected filesystem types %v", mp.FSTypes)
+	}
+	if !mp.acceptsFSType(MountEntry{FSType: "smb3"}) || mp.acceptsFSType(MountEntry{FSType: "nfs4"}) {
+		t.Errorf("expected only the listed filesystem types to be accepted")
+	}
+	if !(MountPoint{}).acceptsFSType(MountEntry{FSType: "nfs4"}) {
+		t.Errorf("expected NFS to be accepted by default")
+	}
+}
+
+func TestParseMountPointEnclosingMount(t *testing.T) {
+	mp, err := ParseMountPoint("/var/vcap/store/app/data?enclosing-mount")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mp.EnclosingMount {
+		t.Errorf("expected enclosing mount mode")
+	}
+
+	for _, value := range []string{"/data?enclosing-mount&absent", "/data?enclosing-mount&remount-source=nfs1:/export"} {
+		if _, err := ParseMountPoint(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMountPointExpectOptions(t *testing.T) {
+	mp, err := ParseMountPoint("/data?expect-options=vers=4.1,rw,hard")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(mp.ExpectOptions, []string{"vers=4.1", "rw", "hard"}) {
+		t.Errorf("unexpected expected options %v", mp.ExpectOptions)
+	}
+}
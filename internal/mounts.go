@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultMountsFile = "/proc/mounts"
+
+// serverLookupTimeout bounds the resolution of an NFS server host name.
+const serverLookupTimeout = 5 * time.Second
+
+var errMountNotFound = errors.New("mount-point not found")
+
+// errMountTableUnreadable marks a mount table that could not be read, where
+// the state of the mounts is unknown rather than known to be missing.
+var errMountTableUnreadable = errors.New("mounttable_unreadable")
+
+// mountTableReadAttempts bounds the reads of a mount table before a read
+// error is reported, to ride out e.g. a mounts file replaced by a bind mount.
+const mountTableReadAttempts = 3
+
+// mountTableRetryDelay is the pause between two reads, replaceable in tests.
+var mountTableRetryDelay = 50 * time.Millisecond
+
+// MountEntry is a parsed /proc/mounts or /proc/self/mountinfo line.
+type MountEntry struct {
+	Source     string   `json:"source"`
+	MountPoint string   `json:"mount_point"`
+	FSType     string   `json:"fstype"`
+	Options    []string `json:"options"`
+	// Root is the directory of the filesystem mounted on MountPoint, "/"
+	// unless it is a bind mount of a subtree. Only known from mountinfo.
+	Root string `json:"root,omitempty"`
+	// Line is the raw line of the mount table.
+	Line string `json:"line"`
+}
+
+func (e MountEntry) isNFS() bool {
+	return e.FSType == "nfs" || strings.HasPrefix(e.FSType, "nfs4")
+}
+
+// isBind reports whether the entry mounts a subtree of its filesystem, as a
+// bind mount does. A bind keeps the filesystem type of its source, so a bind
+// of an NFS subtree is NFS while a bind of a local directory is not.
+func (e MountEntry) isBind() bool {
+	return e.Root != "" && e.Root != "/"
+}
+
+// describe returns the filesystem type and source for error messages.
+func (e MountEntry) describe() string {
+	if e.isBind() {
+		return fmt.Sprintf("bind mount of %s on %s from %s", e.Root, e.FSType, e.Source)
+	}
+	return fmt.Sprintf("%s from %s", e.FSType, e.Source)
+}
+
+// serverHost returns the server part of an NFS source "server:/export",
+// without the brackets of an IPv6 address ("[2001:db8::1]:/export").
+func (e MountEntry) serverHost() (string, error) {
+	host, _, found := strings.Cut(e.Source, ":/")
+	if !found || host == "" {
+		return "", fmt.Errorf("cannot parse NFS server from source %q", e.Source)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), nil
+}
+
+// exportPath returns the exported directory of an NFS source server:/export.
+func (e MountEntry) exportPath() (string, error) {
+	_, export, found := strings.Cut(e.Source, ":/")
+	if !found {
+		return "", fmt.Errorf("cannot parse NFS export from source %q", e.Source)
+	}
+	return "/" + export, nil
+}
+
+// serverAddrs returns the IP addresses of the NFS server. The addr= mount
+// option holds the address the kernel actually connected to; without it, the
+// source is parsed and a host name resolved.
+func (e MountEntry) serverAddrs() ([]netip.Addr, error) {
+	for _, opt := range e.Options {
+		if value, ok := strings.CutPrefix(opt, "addr="); ok {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid addr option %q: %w", value, err)
+			}
+			return []netip.Addr{addr.Unmap()}, nil
+		}
+	}
+
+	host, err := e.serverHost()
+	if err != nil {
+		return nil, err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), serverLookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+	return addrs, nil
+}
+
+// MountTable is the source of the mounts the watchdog checks, read once per
+// check cycle. MountsFile reads the kernel mount table; tests and other
+// platforms can supply their own.
+type MountTable interface {
+	ListMounts() ([]MountEntry, error)
+}
+
+// MountsFile is a mount table file in /proc/mounts or /proc/self/mountinfo
+// format, the latter adding bind mount roots and superblock options.
+type MountsFile string
+
+func (f MountsFile) ListMounts() ([]MountEntry, error) {
+	return readMounts(string(f))
+}
+
+func (f MountsFile) String() string {
+	return string(f)
+}
+
+// mountTableName names a mount table in messages: the path of a file, the
+// type of other tables unless they implement fmt.Stringer.
+func mountTableName(source MountTable) string {
+	if s, ok := source.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", source)
+}
+
+// mountTable is one read of the mount table indexed by mount point, shared
+// by the checks of a cycle instead of re-reading the file per mount point.
+type mountTable struct {
+	file    string
+	entries map[string]MountEntry
+	// shadowed holds earlier entries of a mount point, hidden by the last one.
+	shadowed map[string][]MountEntry
+	err      error
+}
+
+// readMountTable reads and indexes a mount table, retrying transient read
+// errors briefly. A persistent read error is kept and returned by every lookup.
+func readMountTable(source MountTable) *mountTable {
+	entries, err := source.ListMounts()
+	for attempt := 1; err != nil && attempt < mountTableReadAttempts; attempt++ {
+		time.Sleep(mountTableRetryDelay)
+		entries, err = source.ListMounts()
+	}
+	t := &mountTable{file: mountTableName(source), entries: make(map[string]MountEntry, len(entries)), shadowed: make(map[string][]MountEntry), err: err}
+	// The last entry of a mount point wins, as it shadows earlier mounts on
+	// the same path.
+	for _, e := range entries {
+		if previous, ok := t.entries[e.MountPoint]; ok {
+			t.shadowed[e.MountPoint] = append(t.shadowed[e.MountPoint], previous)
+		}
+		t.entries[e.MountPoint] = e
+	}
+	return t
+}
+
+// find returns the entry mounted on mountPoint.
+func (t *mountTable) find(mountPoint string) (MountEntry, error) {
+	if t.err != nil {
+		return MountEntry{}, fmt.Errorf("%w: %w", errMountTableUnreadable, t.err)
+	}
+	e, ok := t.entries[mountPoint]
+	if !ok {
+		return MountEntry{}, fmt.Errorf("%w in %s", errMountNotFound, t.file)
+	}
+	return e, nil
+}
+
+// enclosing returns the entry of the mount containing path: the one mounted
+// on path itself or on its nearest parent directory.
+func (t *mountTable) enclosing(path string) (MountEntry, error) {
+	if t.err != nil {
+		return MountEntry{}, fmt.Errorf("%w: %w", errMountTableUnreadable, t.err)
+	}
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if e, ok := t.entries[dir]; ok {
+			return e, nil
+		}
+		if dir == "/" || dir == "." {
+			return MountEntry{}, fmt.Errorf("%w for %s in %s", errMountNotFound, path, t.file)
+		}
+	}
+}
+
+// findFor returns the entry checked for mp: the one mounted on its path, or
+// the enclosing one when mp accepts a path below the mount point.
+func (t *mountTable) findFor(mp MountPoint) (MountEntry, error) {
+	if mp.EnclosingMount {
+		return t.enclosing(mp.Path)
+	}
+	return t.find(mp.Path)
+}
+
+// MountLookup is the mount table entry a check found for a mount point, as
+// served by /admin/mounts to explain why a mount matched or not.
+type MountLookup struct {
+	MountPoint string    `json:"mount_point"`
+	MountsFile string    `json:"mounts_file"`
+	CheckedAt  time.Time `json:"checked_at"`
+	// Matched reports whether the mount table has an entry for the mount point.
+	Matched bool `json:"matched"`
+	// NFS reports whether the matched entry has an NFS filesystem type.
+	NFS   bool        `json:"nfs"`
+	Entry *MountEntry `json:"entry,omitempty"`
+	// Shadowed lists earlier entries on the same mount point, hidden by Entry.
+	Shadowed []MountEntry `json:"shadowed,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	// WriteProbe is the path pattern of the write test probe files, when
+	// the write test is enabled for the mount point.
+	WriteProbe string `json:"write_probe,omitempty"`
+}
+
+// lookup describes the result of findFor for mp.
+func (t *mountTable) lookup(mp MountPoint) MountLookup {
+	l := MountLookup{MountPoint: mp.Path, MountsFile: t.file}
+	entry, err := t.findFor(mp)
+	if err != nil {
+		l.Shadowed = t.shadowed[mp.Path]
+		l.Error = err.Error()
+		return l
+	}
+	l.Shadowed = t.shadowed[entry.MountPoint]
+	l.Matched = true
+	l.NFS = entry.isNFS()
+	l.Entry = &entry
+	return l
+}
+
+// parseMountInfo parses the fields of a /proc/self/mountinfo line:
+//
+//	36 35 0:52 /exports/app /var/vcap/store/app rw,relatime shared:1 - nfs4 server:/exports rw,vers=4.1,hard
+//
+// The optional fields before "-" vary in number. The options combine the
+// per-mount options and the superblock options, where NFS reports hard, timeo
+// and addr.
+func parseMountInfo(fields []string) (MountEntry, bool) {
+	sep := slices.Index(fields, "-")
+	if sep < 6 || len(fields) < sep+4 {
+		return MountEntry{}, false
+	}
+	return MountEntry{
+		Source:     unescapeMountField(fields[sep+2]),
+		MountPoint: unescapeMountField(fields[4]),
+		FSType:     unescapeMountField(fields[sep+1]),
+		Options:    append(splitMountOptions(fields[5]), splitMountOptions(fields[sep+3])...),
+		Root:       unescapeMountField(fields[3]),
+	}, true
+}
+
+// unescapeMountField decodes the octal escapes the kernel writes into the
+// fields of the mount tables for characters that would break the format,
+// e.g. "/data/my\040share" for "/data/my share". Anything else is kept.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctalEscape(s[i+1:i+4]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// isOctalEscape reports whether digits are three octal digits of a byte value.
+func isOctalEscape(digits string) bool {
+	return digits[0] >= '0' && digits[0] <= '3' &&
+		digits[1] >= '0' && digits[1] <= '7' &&
+		digits[2] >= '0' && digits[2] <= '7'
+}
+
+// splitMountOptions splits a comma separated option field and decodes every
+// option.
+func splitMountOptions(field string) []string {
+	options := strings.Split(field, ",")
+	for i, opt := range options {
+		options[i] = unescapeMountField(opt)
+	}
+	return options
+}
+
+// isMountInfoLine tells a mountinfo line (ID, parent ID, major:minor, ...)
+// from a /proc/mounts line.
+func isMountInfoLine(fields []string) bool {
+	return len(fields) >= 10 && strings.Contains(fields[2], ":") && slices.Contains(fields, "-")
+}
+
+// readMounts parses a mount table file in /proc/mounts or
+// /proc/self/mountinfo format.
+func readMounts(path string) ([]MountEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return parseMounts(f)
+}
+
+// parseMounts parses a mount table in /proc/mounts or /proc/self/mountinfo
+// format, decoding the escaped fields.
+func parseMounts(r io.Reader) ([]MountEntry, error) {
+	// A bufio.Reader instead of a Scanner: lines are not limited in length, so
+	// pathological mount options or device strings cannot break detection.
+	var entries []MountEntry
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if fields := strings.Fields(line); isMountInfoLine(fields) {
+			if entry, ok := parseMountInfo(fields); ok {
+				entry.Line = strings.TrimRight(line, "\n")
+				entries = append(entries, entry)
+			}
+		} else if len(fields) >= 4 {
+			entries = append(entries, MountEntry{
+				Source:     unescapeMountField(fields[0]),
+				MountPoint: unescapeMountField(fields[1]),
+				FSType:     unescapeMountField(fields[2]),
+				Options:    splitMountOptions(fields[3]),
+				Line:       strings.TrimRight(line, "\n"),
+			})
+		}
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
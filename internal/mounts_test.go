@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadMountsLongLine(t *testing.T) {
+	longOptions := "rw,hard," + strings.Repeat("x", 256*1024)
+	path := writeMountsFixture(t, "server:/a /mnt/a nfs4 "+longOptions+" 0 0\n"+
+		"server:/b /mnt/b nfs4 rw,hard 0 0")
+
+	entries, err := readMounts(path)
+	if err != nil {
+		t.Fatalf("readMounts failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].MountPoint != "/mnt/a" || len(entries[0].Options) != 3 {
+		t.Errorf("unexpected entry for the long line: %s %d options", entries[0].MountPoint, len(entries[0].Options))
+	}
+	// The last line has no trailing newline.
+	if entries[1].MountPoint != "/mnt/b" {
+		t.Errorf("unexpected last entry %+v", entries[1])
+	}
+}
+
+func TestReadMountsSkipsMalformedLines(t *testing.T) {
+	path := writeMountsFixture(t, "\nbroken line\nserver:/a /mnt/a nfs rw 0 0\n")
+
+	entries, err := readMounts(path)
+	if err != nil {
+		t.Fatalf("readMounts failed: %v", err)
+	}
+	if len(entries) != 1 || !entries[0].isNFS() {
+		t.Errorf("expected a single NFS entry, got %+v", entries)
+	}
+}
+
+func TestMountEntryServerAddrs(t *testing.T) {
+	tests := []struct {
+		entry MountEntry
+		want  string
+	}{
+		{MountEntry{Source: "10.20.1.5:/export"}, "10.20.1.5"},
+		{MountEntry{Source: "[2001:db8::5]:/export"}, "2001:db8::5"},
+		{MountEntry{Source: "nfs.example.com:/export", Options: []string{"rw", "addr=10.20.1.6"}}, "10.20.1.6"},
+		{MountEntry{Source: "nfs.example.com:/export", Options: []string{"addr=::ffff:10.20.1.7"}}, "10.20.1.7"},
+	}
+	for _, tt := range tests {
+		addrs, err := tt.entry.serverAddrs()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.entry.Source, err)
+			continue
+		}
+		if len(addrs) != 1 || addrs[0].String() != tt.want {
+			t.Errorf("%s: expected %s, got %v", tt.entry.Source, tt.want, addrs)
+		}
+	}
+
+	if _, err := (MountEntry{Source: "tmpfs"}).serverAddrs(); err == nil {
+		t.Errorf("expected error for a source without server")
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	for escaped, want := range map[string]string{
+		"/mnt/a":                     "/mnt/a",
+		`/mnt/my\040share`:           "/mnt/my share",
+		`/mnt/tab\011and\012newline`: "/mnt/tab\tand\nnewline",
+		`/mnt/back\134slash`:         `/mnt/back\slash`,
+		`/mnt/caf\303\251`:           "/mnt/café",
+		`/mnt/short\04`:              `/mnt/short\04`,
+		`/mnt/not\999octal`:          `/mnt/not\999octal`,
+		`/mnt/trailing\`:             `/mnt/trailing\`,
+	} {
+		if got := unescapeMountField(escaped); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", escaped, got, want)
+		}
+	}
+}
+
+func TestParseMountsEscaped(t *testing.T) {
+	entries, err := parseMounts(strings.NewReader("" +
+		`server:/my\040export /mnt/my\040share nfs4 rw,hard 0 0` + "\n" +
+		`36 25 0:52 /app\040root /mnt/caf\303\251 rw,relatime - nfs4 server:/e rw,vers=4.1` + "\n"))
+	if err != nil {
+		t.Fatalf("parseMounts failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.MountPoint != "/mnt/my share" || e.Source != "server:/my export" || !e.isNFS() {
+		t.Errorf("unexpected /proc/mounts entry %+v", e)
+	}
+	if e := entries[1]; e.MountPoint != "/mnt/café" || e.Root != "/app root" || !e.isBind() {
+		t.Errorf("unexpected mountinfo entry %+v", e)
+	}
+}
+
+func TestCheckMountPointWithSpace(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my share")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	escaped := strings.ReplaceAll(dir, " ", `\040`)
+	mountsFile := writeMountsFixture(t, "server:/export "+escaped+" nfs4 rw 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(dir), WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy(dir); !healthy {
+		t.Errorf("expected a mount point with a space to be found in the mount table, got %+v", w.Status())
+	}
+}
+
+// fakeMountTable is a mount table held in memory.
+type fakeMountTable struct {
+	entries []MountEntry
+	err     error
+}
+
+func (f *fakeMountTable) ListMounts() ([]MountEntry, error) {
+	return f.entries, f.err
+}
+
+func TestWatchdogMountTable(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeMountTable{entries: []MountEntry{{Source: "server:/export", MountPoint: dir, FSType: "nfs4", Options: []string{"rw"}}}}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(dir), WatchdogOptions{CheckInterval: time.Second, MountTable: fake})
+
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy(dir); !healthy {
+		t.Fatalf("expected the mount point of the fake table to be healthy, got %+v", w.Status())
+	}
+
+	fake.entries[0].FSType = "tmpfs"
+	w.CheckAll()
+	if got := w.Status()[0].Error; !strings.Contains(got, "is not an NFS mount (tmpfs from server:/export)") {
+		t.Errorf("expected a not NFS error, got %q", got)
+	}
+
+	fake.err = errors.New("permission denied")
+	w.CheckAll()
+	if got := w.Status()[0].Error; !strings.Contains(got, "permission denied") {
+		t.Errorf("expected the error of the table, got %q", got)
+	}
+	if results := w.SelfTest(); results[0].Name != "*internal.fakeMountTable is readable" || results[0].Err == nil {
+		t.Errorf("expected the self-test to name the table by type, got %+v", results[0])
+	}
+}
+
+func TestMountTableFind(t *testing.T) {
+	path := writeMountsFixture(t, "tmpfs /mnt/a tmpfs rw 0 0\nserver:/a /mnt/a nfs4 rw 0 0\n")
+	table := readMountTable(MountsFile(path))
+
+	entry, err := table.find("/mnt/a")
+	if err != nil || !entry.isNFS() {
+		t.Errorf("expected the last entry of a mount point to win, got %+v, %v", entry, err)
+	}
+	if _, err := table.find("/mnt/b"); !errors.Is(err, errMountNotFound) {
+		t.Errorf("expected errMountNotFound, got %v", err)
+	}
+
+	missing := readMountTable(MountsFile(filepath.Join(t.TempDir(), "missing")))
+	if _, err := missing.find("/mnt/a"); !errors.Is(err, errMountTableUnreadable) || errors.Is(err, errMountNotFound) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestMountTableEnclosing(t *testing.T) {
+	table := readMountTable(MountsFile(writeMountsFixture(t, "/dev/sda1 / ext4 rw 0 0\nserver:/a /mnt/a nfs4 rw 0 0\n")))
+	for path, want := range map[string]string{
+		"/mnt/a":         "/mnt/a",
+		"/mnt/a/b/c":     "/mnt/a",
+		"/mnt/a/../ab/c": "/",
+		"/srv":           "/",
+	} {
+		if entry, err := table.enclosing(path); err != nil || entry.MountPoint != want {
+			t.Errorf("enclosing(%s) = %+v, %v, want %s", path, entry, err, want)
+		}
+	}
+
+	rootless := readMountTable(MountsFile(writeMountsFixture(t, "server:/a /mnt/a nfs4 rw 0 0\n")))
+	if _, err := rootless.enclosing("/srv/x"); !errors.Is(err, errMountNotFound) {
+		t.Errorf("expected errMountNotFound without a root mount, got %v", err)
+	}
+}
+
+func TestReadMountTableRetries(t *testing.T) {
+	original := mountTableRetryDelay
+	mountTableRetryDelay = 200 * time.Millisecond
+	t.Cleanup(func() { mountTableRetryDelay = original })
+
+	path := filepath.Join(t.TempDir(), "mounts")
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		done <- os.WriteFile(path, []byte("server:/a /mnt/a nfs4 rw 0 0\n"), 0o644)
+	}()
+
+	table := readMountTable(MountsFile(path))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.find("/mnt/a"); err != nil {
+		t.Errorf("expected the mounts file to be read on retry, got %v", err)
+	}
+}
+
+// benchmarkMountsFixture writes a container host sized mount table with 800
+// entries and returns it with the 50 NFS mount points being monitored.
+func benchmarkMountsFixture(b *testing.B) (string, []string) {
+	var sb strings.Builder
+	var monitored []string
+	for i := 0; i < 800; i++ {
+		if i%16 == 0 {
+			mp := fmt.Sprintf("/var/vcap/store/nfs-%d", i)
+			monitored = append(monitored, mp)
+			fmt.Fprintf(&sb, "10.0.0.1:/export/%d %s nfs4 rw,relatime,vers=4.1,hard,timeo=600,retrans=2,addr=10.0.0.1 0 0\n", i, mp)
+			continue
+		}
+		fmt.Fprintf(&sb, "overlay /var/lib/containers/storage/overlay/%064d/merged overlay rw,relatime,lowerdir=/l/%d,upperdir=/u/%d,workdir=/w/%d 0 0\n", i, i, i, i)
+	}
+	path := filepath.Join(b.TempDir(), "mounts")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		b.Fatalf("cannot write mounts fixture: %v", err)
+	}
+	return path, monitored
+}
+
+// BenchmarkMountLookupPerMountPoint reads the mount table for every mount
+// point, as CheckMountPoint does for a single mount point.
+func BenchmarkMountLookupPerMountPoint(b *testing.B) {
+	path, monitored := benchmarkMountsFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, mp := range monitored {
+			if _, err := readMountTable(MountsFile(path)).find(mp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkMountLookupPerCycle reads the mount table once per cycle, as CheckAll does.
+func BenchmarkMountLookupPerCycle(b *testing.B) {
+	path, monitored := benchmarkMountsFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table := readMountTable(MountsFile(path))
+		for _, mp := range monitored {
+			if _, err := table.find(mp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestReadMountsMountInfo(t *testing.T) {
+	path := writeMountsFixture(t, ""+
+		"36 25 0:52 / /var/vcap/store/nfs rw,relatime shared:1 - nfs4 10.0.0.1:/exports rw,vers=4.1,hard,addr=10.0.0.1\n"+
+		"37 25 0:52 /app/uploads /var/vcap/store/uploads rw,relatime shared:1 master:2 - nfs4 10.0.0.1:/exports rw,vers=4.1,hard,addr=10.0.0.1\n"+
+		"38 25 8:1 /srv/data /var/vcap/store/local rw,relatime - ext4 /dev/sda1 rw\n")
+
+	entries, err := readMounts(path)
+	if err != nil {
+		t.Fatalf("readMounts failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	nfs, bind, local := entries[0], entries[1], entries[2]
+	if !nfs.isNFS() || nfs.isBind() || nfs.MountPoint != "/var/vcap/store/nfs" {
+		t.Errorf("unexpected NFS entry %+v", nfs)
+	}
+	if !bind.isNFS() || !bind.isBind() || bind.Root != "/app/uploads" || bind.Source != "10.0.0.1:/exports" {
+		t.Errorf("unexpected NFS bind entry %+v", bind)
+	}
+	if missing := missingOptions(bind.Options, []string{"hard", "relatime"}); len(missing) != 0 {
+		t.Errorf("expected mount and superblock options, missing %v", missing)
+	}
+	if local.isNFS() || !local.isBind() {
+		t.Errorf("unexpected local bind entry %+v", local)
+	}
+}
@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultMountStatsFile = "/proc/self/mountstats"
+
+// nfsMountStats are the NFS client statistics of a mount in
+// /proc/self/mountstats:
+//
+//	device 10.0.0.1:/export mounted on /mnt/a with fstype nfs4 statvers=1.1
+//		bytes:	normalread normalwrite directread directwrite serverread serverwrite ...
+//		per-op statistics
+//		        READ: ops trans timeouts bytes_sent bytes_recv queue rtt execute [errors]
+type nfsMountStats struct {
+	Device     string
+	MountPoint string
+	FSType     string
+	// ReadBytes and WriteBytes are the bytes read from and written to the
+	// server, including direct and page cache I/O.
+	ReadBytes  uint64
+	WriteBytes uint64
+	Operations []nfsOperationStats
+}
+
+// nfsOperationStats are the statistics of one NFS operation (READ, GETATTR, ...).
+type nfsOperationStats struct {
+	Operation string
+	Ops       uint64
+	// Transmissions includes retransmissions, so Transmissions - Ops are the
+	// retransmits of the operation.
+	Transmissions uint64
+	MajorTimeouts uint64
+	RTT           time.Duration
+	Execute       time.Duration
+}
+
+// retransmissions returns the RPC retransmits of the operation.
+func (o nfsOperationStats) retransmissions() uint64 {
+	if o.Transmissions < o.Ops {
+		return 0
+	}
+	return o.Transmissions - o.Ops
+}
+
+// readMountStats parses /proc/self/mountstats and returns the statistics of
+// NFS mounts by mount point. Devices without statistics (local filesystems)
+// are skipped; of stacked mounts on the same path, the top one wins.
+func readMountStats(path string) (map[string]nfsMountStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	stats := make(map[string]nfsMountStats)
+	var current *nfsMountStats
+	perOp := false
+	flush := func() {
+		if current != nil {
+			stats[current.MountPoint] = *current
+		}
+		current, perOp = nil, false
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "device":
+			flush()
+			// device SOURCE mounted on PATH with fstype TYPE [statvers=X]
+			if len(fields) >= 8 && fields[2] == "mounted" && fields[3] == "on" && fields[5] == "with" && fields[6] == "fstype" {
+				entry := MountEntry{FSType: fields[7]}
+				if entry.isNFS() {
+					current = &nfsMountStats{Device: unescapeMountField(fields[1]), MountPoint: unescapeMountField(fields[4]), FSType: fields[7]}
+				}
+			}
+		case current == nil:
+		case fields[0] == "bytes:":
+			values, parseErr := parseCounters(fields[1:], 6)
+			if parseErr != nil {
+				return nil, fmt.Errorf("unexpected format of %s: bytes of %s: %w", path, current.MountPoint, parseErr)
+			}
+			current.ReadBytes, current.WriteBytes = values[4], values[5]
+		case fields[0] == "per-op" && len(fields) == 2 && fields[1] == "statistics":
+			perOp = true
+		case perOp && strings.HasSuffix(fields[0], ":"):
+			values, parseErr := parseCounters(fields[1:], 8)
+			if parseErr != nil {
+				return nil, fmt.Errorf("unexpected format of %s: %s of %s: %w", path, fields[0], current.MountPoint, parseErr)
+			}
+			current.Operations = append(current.Operations, nfsOperationStats{
+				Operation:     strings.TrimSuffix(fields[0], ":"),
+				Ops:           values[0],
+				Transmissions: values[1],
+				MajorTimeouts: values[2],
+				RTT:           time.Duration(values[6]) * time.Millisecond,
+				Execute:       time.Duration(values[7]) * time.Millisecond,
+			})
+		}
+		if errors.Is(err, io.EOF) {
+			flush()
+			return stats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCounters parses at least n unsigned counters, newer kernels append
+// columns.
+func parseCounters(fields []string, n int) ([]uint64, error) {
+	if len(fields) < n {
+		return nil, fmt.Errorf("%d values, expected at least %d", len(fields), n)
+	}
+	values := make([]uint64, n)
+	for i := range values {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q: %w", fields[i], err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// MountStatsCollector exports the NFS client statistics of the monitored
+// mounts from /proc/self/mountstats at scrape time. The kernel keeps these
+// counters in memory, so reading them does not block on an unresponsive
+// server. Only operations that were used at least once are exported.
+type MountStatsCollector struct {
+	watchdog *Watchdog
+	file     string
+
+	readBytes       *prometheus.Desc
+	writeBytes      *prometheus.Desc
+	retransmissions *prometheus.Desc
+	operations      *prometheus.Desc
+	timeouts        *prometheus.Desc
+	rtt             *prometheus.Desc
+	execute         *prometheus.Desc
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+func NewMountStatsCollector(namespace string, watchdog *Watchdog) *MountStatsCollector {
+	labels := watchdog.labels.names()
+	opLabels := watchdog.labels.names("operation")
+	desc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "mountstats", name), help, labels, nil)
+	}
+	return &MountStatsCollector{
+		watchdog: watchdog,
+		file:     defaultMountStatsFile,
+
+		readBytes:       desc("read_bytes_total", "Bytes read from the NFS server", labels),
+		writeBytes:      desc("write_bytes_total", "Bytes written to the NFS server", labels),
+		retransmissions: desc("rpc_retransmissions_total", "RPC retransmissions of all NFS operations", labels),
+		operations:      desc("operations_total", "Number of NFS operations by operation", opLabels),
+		timeouts:        desc("operation_major_timeouts_total", "Number of major timeouts of NFS operations by operation", opLabels),
+		rtt:             desc("operation_rtt_seconds_total", "Total round trip time of NFS operations by operation", opLabels),
+		execute:         desc("operation_execute_seconds_total", "Total execution time of NFS operations by operation, including queueing", opLabels),
+	}
+}
+
+func (c *MountStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.readBytes, c.writeBytes, c.retransmissions, c.operations, c.timeouts, c.rtt, c.execute} {
+		ch <- d
+	}
+}
+
+func (c *MountStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := readMountStats(c.file)
+	c.logError(err)
+	if err != nil {
+		return
+	}
+
+	counter := func(desc *prometheus.Desc, value float64, labels []string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...)
+	}
+	for _, mp := range c.watchdog.MountPoints() {
+		s, ok := stats[mp.Path]
+		if !ok {
+			continue
+		}
+		labels := c.watchdog.labels.values(mp)
+		counter(c.readBytes, float64(s.ReadBytes), labels)
+		counter(c.writeBytes, float64(s.WriteBytes), labels)
+		var retransmissions uint64
+		for _, op := range s.Operations {
+			retransmissions += op.retransmissions()
+			if op.Ops == 0 {
+				continue
+			}
+			opLabels := c.watchdog.labels.values(mp, op.Operation)
+			counter(c.operations, float64(op.Ops), opLabels)
+			counter(c.timeouts, float64(op.MajorTimeouts), opLabels)
+			counter(c.rtt, op.RTT.Seconds(), opLabels)
+			counter(c.execute, op.Execute.Seconds(), opLabels)
+		}
+		counter(c.retransmissions, float64(retransmissions), labels)
+	}
+}
+
+// logError logs a read error of the mountstats file once rather than on every
+// scrape, and logs its recovery.
+func (c *MountStatsCollector) logError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil && err.Error() != c.lastErr:
+		c.lastErr = err.Error()
+		slog.Warn("cannot read NFS client statistics", "error", err.Error())
+	case err == nil && c.lastErr != "":
+		c.lastErr = ""
+		slog.Info("NFS client statistics readable again")
+	}
+}
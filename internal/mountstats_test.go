@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const mountStatsFixture = `device rootfs mounted on / with fstype rootfs
+device proc mounted on /proc with fstype proc
+device 10.0.0.1:/export/a mounted on /mnt/a with fstype nfs4 statvers=1.1
+	opts:	rw,vers=4.1,rsize=1048576,wsize=1048576,hard,proto=tcp,timeo=600,retrans=2
+	age:	3600
+	events:	1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27
+	bytes:	100 200 10 20 4096 8192 1 2
+	RPC iostats version: 1.1  p/v: 100003/4 (nfs)
+	xprt:	tcp 0 1 1 0 0 50 50 0 50 0 2 0 0
+	per-op statistics
+	        NULL: 0 0 0 0 0 0 0 0
+	        READ: 10 12 1 1200 40960 5 250 300 0
+	       WRITE: 4 4 0 8192 640 1 40 45
+device 10.0.0.2:/export/b mounted on /mnt/not\040monitored with fstype nfs statvers=1.1
+	bytes:	1 1 1 1 1 1 1 1
+	per-op statistics
+	        READ: 1 1 0 1 1 0 1 1
+`
+
+func writeMountStatsFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mountstats")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("cannot write mountstats fixture: %v", err)
+	}
+	return path
+}
+
+func TestReadMountStats(t *testing.T) {
+	stats, err := readMountStats(writeMountStatsFixture(t, mountStatsFixture))
+	if err != nil {
+		t.Fatalf("readMountStats failed: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected the 2 NFS mounts, got %d", len(stats))
+	}
+	if _, ok := stats["/mnt/not monitored"]; !ok {
+		t.Errorf("expected the escaped mount point to be decoded, got %v", stats)
+	}
+	a := stats["/mnt/a"]
+	if a.Device != "10.0.0.1:/export/a" || a.FSType != "nfs4" || a.ReadBytes != 4096 || a.WriteBytes != 8192 {
+		t.Errorf("unexpected stats %+v", a)
+	}
+	if len(a.Operations) != 3 {
+		t.Fatalf("expected 3 operations, got %+v", a.Operations)
+	}
+	read := a.Operations[1]
+	if read.Operation != "READ" || read.Ops != 10 || read.retransmissions() != 2 || read.MajorTimeouts != 1 ||
+		read.RTT != 250*time.Millisecond || read.Execute != 300*time.Millisecond {
+		t.Errorf("unexpected READ stats %+v", read)
+	}
+}
+
+func TestReadMountStatsUnexpectedFormat(t *testing.T) {
+	for name, content := range map[string]string{
+		"short bytes":  "device s:/e mounted on /mnt/a with fstype nfs\n\tbytes:\t1 2 3\n",
+		"invalid op":   "device s:/e mounted on /mnt/a with fstype nfs\n\tper-op statistics\n\tREAD: 1 x 0 0 0 0 0 0\n",
+		"short per-op": "device s:/e mounted on /mnt/a with fstype nfs\n\tper-op statistics\n\tREAD: 1 2\n",
+	} {
+		if _, err := readMountStats(writeMountStatsFixture(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := readMountStats(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestMountStatsCollector(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a", "/mnt/local"}, map[string]bool{})
+	c := NewMountStatsCollector("test_ns", w)
+	c.file = writeMountStatsFixture(t, mountStatsFixture)
+
+	expected := `
+# HELP test_ns_mountstats_operations_total Number of NFS operations by operation
+# TYPE test_ns_mountstats_operations_total counter
+test_ns_mountstats_operations_total{mountpoint="/mnt/a",name="/mnt/a",operation="READ"} 10
+test_ns_mountstats_operations_total{mountpoint="/mnt/a",name="/mnt/a",operation="WRITE"} 4
+# HELP test_ns_mountstats_read_bytes_total Bytes read from the NFS server
+# TYPE test_ns_mountstats_read_bytes_total counter
+test_ns_mountstats_read_bytes_total{mountpoint="/mnt/a",name="/mnt/a"} 4096
+# HELP test_ns_mountstats_rpc_retransmissions_total RPC retransmissions of all NFS operations
+# TYPE test_ns_mountstats_rpc_retransmissions_total counter
+test_ns_mountstats_rpc_retransmissions_total{mountpoint="/mnt/a",name="/mnt/a"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"test_ns_mountstats_operations_total", "test_ns_mountstats_read_bytes_total", "test_ns_mountstats_rpc_retransmissions_total"); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(c, "test_ns_mountstats_operation_rtt_seconds_total"); got != 2 {
+		t.Errorf("expected rtt series of the 2 used operations, got %d", got)
+	}
+
+	c.file = filepath.Join(t.TempDir(), "missing")
+	if got := testutil.CollectAndCount(c); got != 0 {
+		t.Errorf("expected no series when mountstats cannot be read, got %d", got)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountWatchPollTimeout bounds how long a poll blocks, so cancellation of the
+// watch is noticed without closing the file under a blocked poll.
+const mountWatchPollTimeout = 1000 // milliseconds
+
+// watchMountTable sends to events whenever the mount table at path changes,
+// until ctx is cancelled. The kernel reports changes of a procfs mount table
+// (/proc/mounts, /proc/self/mountinfo) as POLLPRI|POLLERR; other files never
+// do, so they are rejected instead of being watched in vain.
+func watchMountTable(ctx context.Context, path string, events chan<- struct{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fd := int(f.Fd())
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(fd, &st); err != nil {
+		return fmt.Errorf("statfs(%s) failed: %w", path, err)
+	}
+	if st.Type != unix.PROC_SUPER_MAGIC {
+		return fmt.Errorf("%s is not a procfs mount table", path)
+	}
+
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLPRI}}
+	for ctx.Err() == nil {
+		n, err := unix.Poll(fds, mountWatchPollTimeout)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("poll(%s) failed: %w", path, err)
+		}
+		if n == 0 || fds[0].Revents&(unix.POLLPRI|unix.POLLERR) == 0 {
+			continue
+		}
+		// Coalesce: a pending event already triggers a check of the new table.
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
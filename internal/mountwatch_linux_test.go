@@ -0,0 +1,32 @@
+//go:build linux
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchMountTableRejectsRegularFile(t *testing.T) {
+	path := writeMountsFixture(t, "server:/export /mnt/a nfs4 rw 0 0\n")
+	if err := watchMountTable(context.Background(), path, make(chan struct{}, 1)); err == nil {
+		t.Errorf("expected error for a mount table outside procfs")
+	}
+}
+
+func TestWatchMountTableStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watchMountTable(ctx, "/proc/self/mounts", make(chan struct{}, 1)) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("watch did not stop after cancellation")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+func watchMountTable(context.Context, string, chan<- struct{}) error {
+	return errors.New("mount table events are only supported on linux")
+}
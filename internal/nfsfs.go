@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultNFSFSServersFile = "/proc/fs/nfsfs/servers"
+
+// nfsfsServer is a line of /proc/fs/nfsfs/servers: an NFS client record the
+// kernel keeps for a server while it is in use.
+//
+//	NV SERVER   PORT USE HOSTNAME
+//	v4 0a000001  801   2 10.0.0.1
+type nfsfsServer struct {
+	Version  string
+	Addr     netip.Addr
+	Use      int
+	Hostname string
+}
+
+// readNFSFSServers parses /proc/fs/nfsfs/servers. The layout depends on the
+// kernel version, so the header is verified and unexpected lines are errors
+// rather than guesses.
+func readNFSFSServers(path string) ([]nfsfsServer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected format of %s: empty file", path)
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) < 5 || strings.Join(header[:5], " ") != "NV SERVER PORT USE HOSTNAME" {
+		return nil, fmt.Errorf("unexpected format of %s: header %q", path, scanner.Text())
+	}
+
+	var servers []nfsfsServer
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("unexpected format of %s: line %q", path, scanner.Text())
+		}
+		use, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected format of %s: use count %q", path, fields[3])
+		}
+		// HOSTNAME holds the server address for IPv4 and IPv6, SERVER only
+		// the hex form of IPv4 addresses.
+		addr, err := netip.ParseAddr(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected format of %s: address %q", path, fields[4])
+		}
+		servers = append(servers, nfsfsServer{Version: fields[0], Addr: addr.Unmap(), Use: use, Hostname: fields[4]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
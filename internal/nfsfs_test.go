@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeNFSFSFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "servers")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("cannot write nfsfs fixture: %v", err)
+	}
+	return path
+}
+
+func TestReadNFSFSServers(t *testing.T) {
+	path := writeNFSFSFixture(t, ""+
+		"NV SERVER   PORT USE HOSTNAME\n"+
+		"v4 0a000001  801   2 10.0.0.1\n"+
+		"v4 20010db8000000000000000000000005  801   0 2001:db8::5\n")
+
+	servers, err := readNFSFSServers(path)
+	if err != nil {
+		t.Fatalf("readNFSFSServers failed: %v", err)
+	}
+	if len(servers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(servers))
+	}
+	if servers[0].Addr.String() != "10.0.0.1" || servers[0].Use != 2 {
+		t.Errorf("unexpected IPv4 server %+v", servers[0])
+	}
+	if servers[1].Addr.String() != "2001:db8::5" || servers[1].Use != 0 {
+		t.Errorf("unexpected IPv6 server %+v", servers[1])
+	}
+}
+
+func TestReadNFSFSServersUnexpectedFormat(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty":  "",
+		"header": "VERSION SERVER\nv4 0a000001 801 2 10.0.0.1\n",
+		"use":    "NV SERVER   PORT USE HOSTNAME\nv4 0a000001  801   x 10.0.0.1\n",
+		"short":  "NV SERVER   PORT USE HOSTNAME\nv4 0a000001\n",
+	} {
+		if _, err := readNFSFSServers(writeNFSFSFixture(t, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const webhookInitialBackoff = 500 * time.Millisecond
+
+// WebhookNotifier POSTs state changes as JSON to one or more webhook URLs.
+// Notifications are queued and delivered by Run on a separate goroutine, so
+// the check loop never waits on the network.
+type WebhookNotifier struct {
+	urls                 []string
+	hostname             string
+	client               *http.Client
+	maxRetries           int
+	backoff              time.Duration
+	queue                chan StateChange
+	notificationsTotal   *prometheus.CounterVec
+	deliveryFailures     *prometheus.CounterVec
+	notificationQueueLen prometheus.Gauge
+}
+
+// webhookEvent is the JSON payload of a notification: the state change and
+// the host it was observed on.
+type webhookEvent struct {
+	StateChange
+	Hostname string `json:"hostname,omitempty"`
+}
+
+func NewWebhookNotifier(namespace string, urls []string, timeout time.Duration, maxRetries, queueSize int, reg prometheus.Registerer) *WebhookNotifier {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		slog.Warn("cannot determine the hostname, webhook notifications are sent without it", "error", err.Error())
+	}
+	return &WebhookNotifier{
+		urls:       urls,
+		hostname:   hostname,
+		client:     &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		backoff:    webhookInitialBackoff,
+		queue:      make(chan StateChange, queueSize),
+
+		notificationsTotal: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "webhook_notifications_total",
+				Help:      "Number of state change webhook notifications by result (success, failed, dropped), counted per webhook",
+			},
+			[]string{"result"},
+		),
+		deliveryFailures: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "webhook_delivery_failures_total",
+				Help:      "Number of state change notifications a webhook did not receive after all retries",
+			},
+			[]string{"webhook"},
+		),
+		notificationQueueLen: promauto.With(reg).NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "webhook_queue_depth",
+				Help:      "Number of state change notifications waiting for delivery",
+			},
+		),
+	}
+}
+
+// Notify enqueues a state change without blocking. When the queue is full the
+// oldest pending notification is dropped to make room.
+func (n *WebhookNotifier) Notify(change StateChange) {
+	for {
+		select {
+		case n.queue <- change:
+			n.notificationQueueLen.Set(float64(len(n.queue)))
+			return
+		default:
+		}
+
+		select {
+		case dropped := <-n.queue:
+			n.notificationsTotal.WithLabelValues("dropped").Inc()
+			slog.Warn("webhook queue full, dropping notification", "mountpoint", dropped.MountPoint, "healthy", dropped.Healthy)
+		default:
+		}
+	}
+}
+
+// Run delivers queued notifications until ctx is cancelled.
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-n.queue:
+			n.notificationQueueLen.Set(float64(len(n.queue)))
+			n.deliver(ctx, change)
+		}
+	}
+}
+
+// deliver sends a notification to all webhooks at once, so a failing webhook
+// retrying does not delay the others.
+func (n *WebhookNotifier) deliver(ctx context.Context, change StateChange) {
+	payload, err := json.Marshal(webhookEvent{StateChange: change, Hostname: n.hostname})
+	if err != nil {
+		n.notificationsTotal.WithLabelValues("failed").Inc()
+		slog.Error("cannot encode webhook notification", "mountpoint", change.MountPoint, "error", err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, target := range n.urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !n.deliverTo(ctx, target, payload) {
+				n.deliveryFailures.WithLabelValues(webhookLabel(target)).Inc()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deliverTo posts the payload to one webhook, retrying connection errors and
+// 5xx responses with a doubling backoff, and reports whether it arrived.
+func (n *WebhookNotifier) deliverTo(ctx context.Context, target string, payload []byte) bool {
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, target, payload)
+		if err == nil {
+			n.notificationsTotal.WithLabelValues("success").Inc()
+			return true
+		}
+		if !retry || attempt >= n.maxRetries {
+			n.notificationsTotal.WithLabelValues("failed").Inc()
+			slog.Error("webhook notification lost", "webhook", webhookLabel(target), "attempts", attempt+1, "error", err.Error(), "payload", string(payload))
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			n.notificationsTotal.WithLabelValues("failed").Inc()
+			slog.Warn("webhook notification lost on shutdown", "webhook", webhookLabel(target), "payload", string(payload))
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// webhookLabel identifies a webhook in metrics and logs by its scheme, host
+// and path, leaving out credentials and query parameters such as tokens.
+func webhookLabel(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "invalid"
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+// post sends a single request and reports whether a failure is worth retrying
+// (connection errors and 5xx responses).
+func (n *WebhookNotifier) post(ctx context.Context, target string, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", res.Status)
+	case res.StatusCode >= 300:
+		return false, fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return false, nil
+}
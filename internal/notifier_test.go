@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWebhookNotifierRetriesOnServerError(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan StateChange, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var change StateChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			t.Errorf("cannot decode payload: %v", err)
+		}
+		received <- change
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier("test_ns", []string{srv.URL}, time.Second, 3, 10, nil)
+	n.backoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify(StateChange{MountPoint: "/mnt/a", Healthy: false, PreviousHealthy: true})
+
+	select {
+	case change := <-received:
+		if change.MountPoint != "/mnt/a" || change.Healthy {
+			t.Errorf("unexpected payload %+v", change)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("notification was not delivered")
+	}
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+	waitForCounter(t, func() float64 { return testutil.ToFloat64(n.notificationsTotal.WithLabelValues("success")) }, 1)
+}
+
+func TestWebhookNotifierGivesUpOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier("test_ns", []string{srv.URL}, time.Second, 3, 10, nil)
+	n.backoff = time.Millisecond
+
+	n.deliver(context.Background(), StateChange{MountPoint: "/mnt/a"})
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt for a 4xx response, got %d", got)
+	}
+	if got := testutil.ToFloat64(n.notificationsTotal.WithLabelValues("failed")); got != 1 {
+		t.Errorf("expected failed counter 1, got %v", got)
+	}
+}
+
+func TestWebhookNotifierDeliversToAllWebhooks(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("cannot decode payload: %v", err)
+		}
+		received <- event
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	n := NewWebhookNotifier("test_ns", []string{ok.URL, failing.URL + "/hook?token=secret"}, time.Second, 1, 10, nil)
+	n.backoff = time.Millisecond
+	n.hostname = "agent-1"
+
+	n.deliver(context.Background(), StateChange{MountPoint: "/mnt/a", PreviousHealthy: true})
+
+	event := <-received
+	if event["mountpoint"] != "/mnt/a" || event["hostname"] != "agent-1" || event["previous_healthy"] != true {
+		t.Errorf("unexpected payload %v", event)
+	}
+	if got := testutil.ToFloat64(n.deliveryFailures.WithLabelValues(failing.URL + "/hook")); got != 1 {
+		t.Errorf("expected a delivery failure of the failing webhook without its query, got %v", got)
+	}
+	if got := testutil.ToFloat64(n.notificationsTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("expected success counter 1, got %v", got)
+	}
+}
+
+func TestWebhookNotifierDropsOldestWhenQueueFull(t *testing.T) {
+	// Run is never started, so the queue fills up.
+	n := NewWebhookNotifier("test_ns", []string{"http://127.0.0.1:0"}, time.Second, 0, 2, nil)
+
+	n.Notify(StateChange{MountPoint: "/mnt/a"})
+	n.Notify(StateChange{MountPoint: "/mnt/b"})
+	n.Notify(StateChange{MountPoint: "/mnt/c"})
+
+	if got := testutil.ToFloat64(n.notificationsTotal.WithLabelValues("dropped")); got != 1 {
+		t.Errorf("expected dropped counter 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(n.notificationQueueLen); got != 2 {
+		t.Errorf("expected queue depth 2, got %v", got)
+	}
+	if first := <-n.queue; first.MountPoint != "/mnt/b" {
+		t.Errorf("expected oldest entry to be dropped, queue head is %q", first.MountPoint)
+	}
+}
+
+func waitForCounter(t *testing.T, get func() float64, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if get() == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected counter value %v, got %v", want, get())
+}
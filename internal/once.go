@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Exit codes of a single check run, mirroring the readiness states.
+const (
+	ExitReady    = 0
+	ExitDegraded = 1
+	ExitNotReady = 2
+)
+
+// CheckOnce checks all mount points a single time, writes the results as a
+// text table followed by the readiness state to w, and returns the exit code
+// of the state: ExitReady, ExitDegraded (only optional mount points are
+// unhealthy) or ExitNotReady.
+func (m *Watchdog) CheckOnce(w io.Writer) int {
+	m.CheckAll()
+	writeStatusText(w, m.Status(), time.Now(), false)
+	r := m.Readiness()
+	fmt.Fprintf(w, "\n%s\n", r.State)
+	switch r.State {
+	case ReadinessReady:
+		return ExitReady
+	case ReadinessDegraded:
+		return ExitDegraded
+	default:
+		return ExitNotReady
+	}
+}
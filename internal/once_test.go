@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckOnce(t *testing.T) {
+	mounted := t.TempDir()
+	mounts := "nfs1:/export " + mounted + " nfs4 rw 0 0\n"
+
+	tests := []struct {
+		name   string
+		points []MountPoint
+		want   int
+		state  string
+	}{
+		{"healthy", []MountPoint{{Path: mounted}}, ExitReady, ReadinessReady},
+		{"optional unhealthy", []MountPoint{{Path: mounted}, {Path: "/mnt/missing", Optional: true}}, ExitDegraded, ReadinessDegraded},
+		{"critical unhealthy", []MountPoint{{Path: mounted}, {Path: "/mnt/missing"}}, ExitNotReady, ReadinessNotReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWatchdog("test-program", "1.0.0", "test_ns", tt.points, WatchdogOptions{MountsFile: writeMountsFixture(t, mounts)})
+			var out bytes.Buffer
+			if got := w.CheckOnce(&out); got != tt.want {
+				t.Errorf("expected exit code %d, got %d", tt.want, got)
+			}
+			if !strings.HasPrefix(out.String(), "NAME") || !strings.HasSuffix(out.String(), "\n"+tt.state+"\n") {
+				t.Errorf("unexpected output %q", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// recordOptionDrift compares the mount options of a mount point with its
+// expected options and exports whether they match. Unlike required options,
+// drift does not fail the check: it is logged when it appears and, with
+// degradeOnOptionDrift, marks a healthy mount point degraded.
+func (m *Watchdog) recordOptionDrift(mp MountPoint, entry MountEntry) {
+	if len(mp.ExpectOptions) == 0 {
+		return
+	}
+	drifted := missingOptions(entry.Options, mp.ExpectOptions)
+
+	m.mu.Lock()
+	if _, ok := m.lastHealthy[mp.Path]; !ok {
+		// Removed in the meantime, do not recreate its state.
+		m.mu.Unlock()
+		return
+	}
+	was := m.optionDrift[mp.Path]
+	if len(drifted) > 0 {
+		m.optionDrift[mp.Path] = drifted
+	} else {
+		delete(m.optionDrift, mp.Path)
+	}
+	m.mu.Unlock()
+
+	if len(drifted) > 0 {
+		m.nfsOptionsMatch.WithLabelValues(m.labels.values(mp)...).Set(0)
+	} else {
+		m.nfsOptionsMatch.WithLabelValues(m.labels.values(mp)...).Set(1)
+	}
+	switch {
+	case len(drifted) > 0 && !slices.Equal(drifted, was):
+		slog.Warn("mount options drifted from the expected ones", "mountpoint", mp.Path, "missing", strings.Join(drifted, ","), "options", strings.Join(entry.Options, ","))
+	case len(drifted) == 0 && len(was) > 0:
+		slog.Info("mount options match the expected ones again", "mountpoint", mp.Path)
+	}
+}
+
+// MountOptionDrift returns the expected options missing from the mount
+// options of the mount point on its last check, nil when they matched.
+func (m *Watchdog) MountOptionDrift(mountPoint string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.optionDrift[mountPoint]
+}
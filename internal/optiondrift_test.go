@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOptionDrift(t *testing.T) {
+	dir := t.TempDir()
+	mp := MountPoint{Path: dir, ExpectOptions: []string{"rw", "vers=4.1", "hard"}}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 ro,vers=4.2,hard 0 0\n"),
+	})
+
+	w.CheckMountPoint(mp)
+	if healthy, _ := w.IsMountHealthy(dir); !healthy {
+		t.Errorf("expected option drift not to fail the check: %+v", w.Status())
+	}
+	if got := w.MountOptionDrift(dir); !slices.Equal(got, []string{"rw", "vers=4.1"}) {
+		t.Errorf("expected rw and vers=4.1 to have drifted, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsOptionsMatch.WithLabelValues(dir, dir)); got != 0 {
+		t.Errorf("expected mount_options_match 0, got %v", got)
+	}
+	if r := w.Readiness(); r.State != ReadinessReady {
+		t.Errorf("expected drift not to degrade readiness by default, got %+v", r)
+	}
+
+	w.driftDegrades = true
+	if r := w.Readiness(); r.State != ReadinessDegraded || !slices.Equal(r.OptionDrift, []string{dir}) {
+		t.Errorf("expected drift to degrade readiness, got %+v", r)
+	}
+
+	w.mounts = MountsFile(writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw,vers=4.1,hard 0 0\n"))
+	w.CheckMountPoint(mp)
+	if got := testutil.ToFloat64(w.nfsOptionsMatch.WithLabelValues(dir, dir)); got != 1 {
+		t.Errorf("expected mount_options_match 1, got %v", got)
+	}
+	if r := w.Readiness(); r.State != ReadinessReady {
+		t.Errorf("expected readiness to recover with matching options, got %+v", r)
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// orphanScanBatch is the number of directory entries read at once when
+// scanning a write test directory for orphaned probe files.
+const orphanScanBatch = 256
+
+// orphanScanDue reports whether the write test directory of mp is due for a scan
+// for orphaned probe files, and if so records the scan.
+func (m *Watchdog) orphanScanDue(mp MountPoint, now time.Time) bool {
+	if m.orphanAge <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lastHealthy[mp.Path]; !ok {
+		return false
+	}
+	if last, ok := m.orphanScans[mp.Path]; ok && (m.orphanInterval <= 0 || now.Sub(last) < m.orphanInterval) {
+		return false
+	}
+	m.orphanScans[mp.Path] = now
+	return true
+}
+
+// removeOrphanProbes removes the orphaned probe files of mp, counting them,
+// and reports the recent probe files of other agents. A failed scan is logged
+// only, it does not fail the write test that passed.
+func (m *Watchdog) removeOrphanProbes(mp MountPoint) {
+	removed, foreign, err := removeOrphans(mp.WriteTestPath(), m.writeVerify.owner, m.orphanAge, time.Now())
+	if m.nfsForeignProbes != nil {
+		m.nfsForeignProbes.WithLabelValues(m.labels.values(mp)...).Set(float64(len(foreign)))
+	}
+	if len(foreign) > 0 {
+		// Another agent writes its probes here, or a client keeps seeing files long removed.
+		slog.Warn("found probe files of other agents", "mountpoint", mp.Path, "count", len(foreign), "files", foreign)
+	}
+	if removed > 0 {
+		if m.nfsOrphansRemoved != nil {
+			m.nfsOrphansRemoved.WithLabelValues(m.labels.values(mp)...).Add(float64(removed))
+		}
+		slog.Info("removed orphaned write test probe files", "mountpoint", mp.Path, "count", removed, "older_than", m.orphanAge.String())
+	}
+	if err != nil {
+		slog.Warn("orphaned write test probe file cleanup failed", "mountpoint", mp.Path, "error", err.Error())
+	}
+}
+
+// removeOrphans removes the probe files in dir whose mtime is age or more
+// before now and returns how many it removed, and the names of the recent
+// probe files of owners other than owner. The probe files of running write
+// tests are removed right after they are written, an old one was left by a
+// write test that never finished. The mtime is set by the NFS server clock,
+// so age must exceed the clock skew between the nodes.
+func removeOrphans(dir, owner string, age time.Duration, now time.Time) (removed int, foreign []string, err error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	var errs []error
+	for {
+		entries, err := f.ReadDir(orphanScanBatch)
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), probeFilePrefix) || !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				// Removed in the meantime, e.g. by another agent.
+				continue
+			}
+			if now.Sub(info.ModTime()) < age {
+				if probeFileOwner(entry.Name()) != owner {
+					foreign = append(foreign, entry.Name())
+				}
+				continue
+			}
+			if err := removeProbe(filepath.Join(dir, entry.Name())); err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			removed++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return removed, foreign, err
+		}
+	}
+	return removed, foreign, errors.Join(errs...)
+}
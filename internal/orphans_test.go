@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// writeAged writes an empty file in dir with an mtime age before now.
+func writeAged(t *testing.T, dir, name string, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("cannot write %s: %v", path, err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("cannot set the mtime of %s: %v", path, err)
+	}
+	return path
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	dir := t.TempDir()
+	orphan := writeAged(t, dir, probeFilePrefix+"vm-2_0000beef_123_1", 2*time.Hour)
+	recent := writeAged(t, dir, probeFilePrefix+"vm-1_cafe0000_123_2", time.Minute)
+	foreign := writeAged(t, dir, probeFilePrefix+"vm-2_0000beef_123_3", time.Minute)
+	unrelated := writeAged(t, dir, "data.csv", 2*time.Hour)
+
+	removed, others, err := removeOrphans(dir, "vm-1_cafe0000", time.Hour, time.Now())
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 orphan removed, got %d, %v", removed, err)
+	}
+	if len(others) != 1 || others[0] != filepath.Base(foreign) {
+		t.Errorf("expected the recent probe file of another agent to be reported, got %q", others)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected the old probe file to be removed, got %v", err)
+	}
+	for _, path := range []string{recent, foreign, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestOrphanCleanupAfterFirstWriteTest(t *testing.T) {
+	dir := t.TempDir()
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		EnableWriteTest: true,
+		OrphanProbeAge:  time.Hour,
+		Registerer:      prometheus.NewRegistry(),
+	})
+
+	orphan := writeAged(t, dir, probeFilePrefix+"123_1", 2*time.Hour)
+	if err := w.writeTest(mp); err != nil {
+		t.Fatalf("writeTest failed: %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected the orphan to be removed after the first write test, got %v", err)
+	}
+	if got := testutil.ToFloat64(w.nfsOrphansRemoved.WithLabelValues(dir, dir)); got != 1 {
+		t.Errorf("expected 1 orphan counted, got %v", got)
+	}
+
+	// Without an interval, the directory is scanned once.
+	orphan = writeAged(t, dir, probeFilePrefix+"123_2", 2*time.Hour)
+	if err := w.writeTest(mp); err != nil {
+		t.Fatalf("writeTest failed: %v", err)
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Errorf("expected no second scan without an interval: %v", err)
+	}
+}
@@ -0,0 +1,390 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxOTLPSpans bounds the check spans waiting for the next export; the oldest
+// are dropped first when the collector is unreachable for long.
+const maxOTLPSpans = 4096
+
+// OTLPExporter periodically pushes the metrics of the agent and a span per
+// check to an OpenTelemetry collector, using OTLP/HTTP with JSON encoding, for
+// environments moving off Prometheus pull. It coexists with the metrics
+// endpoint: both expose the same metrics.
+type OTLPExporter struct {
+	endpoint     *url.URL
+	interval     time.Duration
+	client       *http.Client
+	gatherer     prometheus.Gatherer
+	resource     otlpResource
+	scope        otlpScope
+	started      time.Time
+	exportsTotal *prometheus.CounterVec
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+// NewOTLPExporter exports every interval to the collector at endpoint, e.g.
+// http://collector:4318, posting to its /v1/metrics and /v1/traces paths. The
+// metrics are those of gatherer prefixed with the namespace.
+func NewOTLPExporter(namespace string, endpoint *url.URL, interval time.Duration, watchdog *Watchdog, gatherer prometheus.Gatherer) *OTLPExporter {
+	agent := watchdog.Agent(time.Now())
+	attributes := []otlpKeyValue{
+		otlpString("service.name", agent.Program),
+		otlpString("service.version", agent.Version),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, otlpString("host.name", hostname))
+	} else {
+		slog.Warn("cannot determine the hostname, OTLP exports are sent without it", "error", err.Error())
+	}
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		interval: interval,
+		// An export never outlives its interval, so exports cannot pile up.
+		client:   &http.Client{Timeout: interval},
+		gatherer: namespaceGatherer(namespace, gatherer),
+		resource: otlpResource{Attributes: attributes},
+		scope:    otlpScope{Name: agent.Program, Version: agent.Version},
+		started:  agent.StartTime,
+
+		exportsTotal: promauto.With(watchdog.Registerer()).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "otlp_exports_total",
+				Help:      "Number of OTLP exports by signal (metrics, traces) and result (success, failed)",
+			},
+			[]string{"signal", "result"},
+		),
+	}
+	watchdog.OnCheck(e.recordSpan)
+	return e
+}
+
+// Run exports the metrics and the pending spans every interval until ctx is
+// cancelled.
+func (e *OTLPExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.export(ctx, "metrics", e.exportMetrics)
+			e.export(ctx, "traces", e.exportSpans)
+		}
+	}
+}
+
+func (e *OTLPExporter) export(ctx context.Context, signal string, fn func(context.Context, time.Time) error) {
+	if err := fn(ctx, time.Now()); err != nil {
+		e.exportsTotal.WithLabelValues(signal, "failed").Inc()
+		slog.Warn("OTLP export failed", "signal", signal, "error", err.Error())
+	} else {
+		e.exportsTotal.WithLabelValues(signal, "success").Inc()
+	}
+}
+
+func (e *OTLPExporter) exportMetrics(ctx context.Context, now time.Time) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	return e.post(ctx, "/v1/metrics", otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     e.resource,
+		ScopeMetrics: []otlpScopeMetrics{{Scope: e.scope, Metrics: otlpMetrics(families, e.started, now)}},
+	}}})
+}
+
+// exportSpans sends the pending spans; they are put back when the export
+// fails, so a short collector outage loses none.
+func (e *OTLPExporter) exportSpans(ctx context.Context, _ time.Time) error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	err := e.post(ctx, "/v1/traces", otlpTracesRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: e.scope, Spans: spans}},
+	}}})
+	if err != nil {
+		e.mu.Lock()
+		e.spans = trimSpans(append(spans, e.spans...))
+		e.mu.Unlock()
+	}
+	return err
+}
+
+func (e *OTLPExporter) post(ctx context.Context, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint.JoinPath(path).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("OTLP endpoint returned %s", res.Status)
+	}
+	return nil
+}
+
+// recordSpan queues a span for the check. Each check is a trace of its own.
+func (e *OTLPExporter) recordSpan(record CheckRecord) {
+	span := otlpSpan{
+		TraceID:           randomHex(16),
+		SpanID:            randomHex(8),
+		Name:              "check " + record.MountPoint,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: uint64(record.Start.UnixNano()),
+		EndTimeUnixNano:   uint64(record.Start.Add(record.Duration).UnixNano()),
+		Attributes: []otlpKeyValue{
+			otlpString("mountpoint", record.MountPoint),
+			otlpString("name", record.Name),
+			otlpString("server", record.Server),
+			otlpString("export", record.Export),
+			otlpString("result", record.Result),
+			otlpBool("healthy", record.Healthy),
+		},
+		Status: otlpStatus{Code: otlpStatusOK},
+	}
+	if record.Error != "" {
+		span.Attributes = append(span.Attributes, otlpString("reason", record.Reason))
+		span.Status = otlpStatus{Code: otlpStatusError, Message: record.Error}
+	}
+
+	e.mu.Lock()
+	e.spans = trimSpans(append(e.spans, span))
+	e.mu.Unlock()
+}
+
+func trimSpans(spans []otlpSpan) []otlpSpan {
+	if len(spans) > maxOTLPSpans {
+		return spans[len(spans)-maxOTLPSpans:]
+	}
+	return spans
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// otlpMetrics converts Prometheus metric families to OTLP metrics: gauges and
+// untyped metrics to gauges, counters to monotonic cumulative sums and
+// histograms to cumulative histograms. Summaries are not used by the agent.
+func otlpMetrics(families []*dto.MetricFamily, start, now time.Time) []otlpMetric {
+	startNano, nowNano := uint64(start.UnixNano()), uint64(now.UnixNano())
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, mf := range families {
+		metric := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		var points []otlpNumberPoint
+		var histogramPoints []otlpHistogramPoint
+		for _, m := range mf.GetMetric() {
+			attributes := make([]otlpKeyValue, 0, len(m.GetLabel()))
+			for _, pair := range m.GetLabel() {
+				attributes = append(attributes, otlpString(pair.GetName(), pair.GetValue()))
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				points = append(points, otlpNumberPoint{Attributes: attributes, StartTimeUnixNano: startNano, TimeUnixNano: nowNano, AsDouble: m.GetCounter().GetValue()})
+			case dto.MetricType_GAUGE:
+				points = append(points, otlpNumberPoint{Attributes: attributes, TimeUnixNano: nowNano, AsDouble: m.GetGauge().GetValue()})
+			case dto.MetricType_UNTYPED:
+				points = append(points, otlpNumberPoint{Attributes: attributes, TimeUnixNano: nowNano, AsDouble: m.GetUntyped().GetValue()})
+			case dto.MetricType_HISTOGRAM:
+				histogramPoints = append(histogramPoints, otlpHistogram(m.GetHistogram(), attributes, startNano, nowNano))
+			}
+		}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			metric.Sum = &otlpSum{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			metric.Gauge = &otlpGauge{DataPoints: points}
+		case dto.MetricType_HISTOGRAM:
+			metric.Histogram = &otlpHistogramData{DataPoints: histogramPoints, AggregationTemporality: otlpCumulative}
+		default:
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// otlpHistogram converts the cumulative buckets of Prometheus to the
+// per-bucket counts of OTLP, the last one counting the values above all bounds.
+func otlpHistogram(h *dto.Histogram, attributes []otlpKeyValue, startNano, nowNano uint64) otlpHistogramPoint {
+	point := otlpHistogramPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: startNano,
+		TimeUnixNano:      nowNano,
+		Count:             h.GetSampleCount(),
+		Sum:               h.GetSampleSum(),
+	}
+	var previous uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-previous, 10))
+		previous = b.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+	return point
+}
+
+// OTLP/JSON payloads, see opentelemetry-proto. 64-bit integers are encoded as
+// strings and trace and span ids as hex, as required by the JSON encoding.
+
+const (
+	otlpCumulative       = 2
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Gauge       *otlpGauge         `json:"gauge,omitempty"`
+	Sum         *otlpSum           `json:"sum,omitempty"`
+	Histogram   *otlpHistogramData `json:"histogram,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64         `json:"timeUnixNano,string"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpHistogramData struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,string"`
+	TimeUnixNano      uint64         `json:"timeUnixNano,string"`
+	Count             uint64         `json:"count,string"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano uint64         `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   uint64         `json:"endTimeUnixNano,string"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func otlpBool(key string, value bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{BoolValue: &value}}
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type otlpRecorder struct {
+	mu     sync.Mutex
+	status int
+	bodies map[string][]byte
+}
+
+func (r *otlpRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies[req.URL.Path] = body
+	w.WriteHeader(r.status)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	dir := t.TempDir()
+	reg := prometheus.NewRegistry()
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(dir, "/mnt/missing"), WatchdogOptions{
+		MountsFile: writeMountsFixture(t, "server:/export "+dir+" nfs4 rw,hard 0 0\n"),
+		Registerer: reg,
+	})
+	recorder := &otlpRecorder{status: http.StatusOK, bodies: map[string][]byte{}}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewOTLPExporter("test_ns", endpoint, time.Second, w, reg)
+
+	w.CheckAll()
+	e.export(context.Background(), "metrics", e.exportMetrics)
+	e.export(context.Background(), "traces", e.exportSpans)
+
+	var metrics otlpMetricsRequest
+	if err := json.Unmarshal(recorder.bodies["/v1/metrics"], &metrics); err != nil {
+		t.Fatalf("cannot decode the metrics export: %v", err)
+	}
+	healthy := map[string]float64{}
+	for _, m := range metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name != "test_ns_mount_healthy" {
+			continue
+		}
+		for _, point := range m.Gauge.DataPoints {
+			for _, attribute := range point.Attributes {
+				if attribute.Key == "mountpoint" {
+					healthy[*attribute.Value.StringValue] = point.AsDouble
+				}
+			}
+		}
+	}
+	if want := map[string]float64{dir: 1, "/mnt/missing": 0}; !reflect.DeepEqual(healthy, want) {
+		t.Errorf("expected mount health %v in the metrics export, got %v", want, healthy)
+	}
+
+	var traces otlpTracesRequest
+	if err := json.Unmarshal(recorder.bodies["/v1/traces"], &traces); err != nil {
+		t.Fatalf("cannot decode the traces export: %v", err)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected a span per check, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if len(span.TraceID) != 32 || len(span.SpanID) != 16 || span.EndTimeUnixNano < span.StartTimeUnixNano {
+			t.Errorf("invalid span %+v", span)
+		}
+	}
+	if spans[0].Status.Code == spans[1].Status.Code {
+		t.Errorf("expected one passed and one failed check, got %+v", spans)
+	}
+	if got := testutil.ToFloat64(e.exportsTotal.WithLabelValues("traces", "success")); got != 1 {
+		t.Errorf("expected 1 successful traces export, got %v", got)
+	}
+
+	// Spans of a failed export are sent with the next one.
+	recorder.status = http.StatusServiceUnavailable
+	w.CheckAll()
+	e.export(context.Background(), "traces", e.exportSpans)
+	if got := testutil.ToFloat64(e.exportsTotal.WithLabelValues("traces", "failed")); got != 1 {
+		t.Errorf("expected 1 failed traces export, got %v", got)
+	}
+	if len(e.spans) != 2 {
+		t.Errorf("expected the spans to be kept after a failed export, got %d", len(e.spans))
+	}
+}
+
+func TestOTLPHistogram(t *testing.T) {
+	count := func(n uint64) *uint64 { return &n }
+	bound := func(f float64) *float64 { return &f }
+	h := &dto.Histogram{
+		SampleCount: count(5),
+		SampleSum:   bound(2.5),
+		Bucket: []*dto.Bucket{
+			{UpperBound: bound(0.1), CumulativeCount: count(1)},
+			{UpperBound: bound(1), CumulativeCount: count(3)},
+		},
+	}
+	point := otlpHistogram(h, nil, 1, 2)
+	if want := []string{"1", "2", "2"}; !reflect.DeepEqual(point.BucketCounts, want) {
+		t.Errorf("expected bucket counts %v, got %v", want, point.BucketCounts)
+	}
+	if want := []float64{0.1, 1}; !reflect.DeepEqual(point.ExplicitBounds, want) {
+		t.Errorf("expected bounds %v, got %v", want, point.ExplicitBounds)
+	}
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMountPointConflict is returned when an added mount point conflicts
+	// with a monitored one by path or alias.
+	ErrMountPointConflict = errors.New("mount point conflicts with a monitored one")
+	// ErrMountPointNotFound is returned for a mount point that is not monitored.
+	ErrMountPointNotFound = errors.New("mount point not monitored")
+)
+
+// AddMountPoint starts monitoring a mount point at runtime. It is not
+// persisted: it survives a config reload, see ReloadMountPoints, but not a
+// restart.
+func (m *Watchdog) AddMountPoint(mp MountPoint) (MountPointsDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	points := append(append([]MountPoint(nil), m.mountPoints...), mp)
+	if err := ValidateMountPoints(points); err != nil {
+		return MountPointsDiff{}, fmt.Errorf("%w: %w", ErrMountPointConflict, err)
+	}
+	return m.setMountPoints(points)
+}
+
+// RemoveMountPoint stops monitoring a mount point and removes its series.
+func (m *Watchdog) RemoveMountPoint(path string) (MountPointsDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	points := make([]MountPoint, 0, len(m.mountPoints))
+	for _, mp := range m.mountPoints {
+		if mp.Path != path {
+			points = append(points, mp)
+		}
+	}
+	if len(points) == len(m.mountPoints) {
+		return MountPointsDiff{}, fmt.Errorf("%w: %s", ErrMountPointNotFound, path)
+	}
+	return m.setMountPoints(points)
+}
+
+// SetMountPaused pauses or resumes the checks of a mount point. A paused mount
+// point keeps its last state and series but is left out of the global health
+// and readiness; a resumed one is checked again on its next due check.
+func (m *Watchdog) SetMountPaused(path string, paused bool) error {
+	m.mu.Lock()
+	var mp MountPoint
+	found := false
+	for _, candidate := range m.mountPoints {
+		if candidate.Path == path {
+			mp, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrMountPointNotFound, path)
+	}
+	if paused {
+		m.paused[path] = true
+	} else {
+		delete(m.paused, path)
+	}
+	m.mu.Unlock()
+
+	if paused {
+		m.nfsPaused.WithLabelValues(m.labels.values(mp)...).Set(1)
+	} else {
+		m.nfsPaused.WithLabelValues(m.labels.values(mp)...).Set(0)
+	}
+	return nil
+}
+
+// IsMountPaused reports whether the checks of the mount point are paused.
+func (m *Watchdog) IsMountPaused(mountPoint string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.paused[mountPoint]
+}
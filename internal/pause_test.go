@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPausedMountPointIsNotChecked(t *testing.T) {
+	mounted := t.TempDir()
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a", mounted), WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    writeMountsFixture(t, "nfs1:/export "+mounted+" nfs4 rw 0 0\n"),
+	})
+	w.CheckAll()
+	if w.IsHealthy() {
+		t.Fatal("expected unhealthy with /mnt/a unmounted")
+	}
+
+	if err := w.SetMountPaused("/mnt/a", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := testutil.ToFloat64(w.nfsPaused.WithLabelValues("/mnt/a", "/mnt/a")); got != 1 {
+		t.Errorf("expected mount_paused 1, got %v", got)
+	}
+	lastCheck := w.Status()[0].LastCheck
+	time.Sleep(time.Millisecond)
+	w.CheckAll()
+	if status := w.Status()[0]; status.MountPoint != "/mnt/a" || !status.Paused || !status.LastCheck.Equal(*lastCheck) {
+		t.Errorf("expected no check of the paused mount point, got %+v", status)
+	}
+	if !w.IsHealthy() {
+		t.Error("expected healthy with the unhealthy mount point paused")
+	}
+	if healthy, total := w.HealthyCount(); healthy != 1 || total != 1 {
+		t.Errorf("expected 1/1 healthy, got %d/%d", healthy, total)
+	}
+	if r := w.Readiness(); r.State != ReadinessReady || len(r.Paused) != 1 || r.Paused[0] != "/mnt/a" {
+		t.Errorf("unexpected readiness %+v", r)
+	}
+
+	if err := w.SetMountPaused("/mnt/a", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.IsHealthy() {
+		t.Error("expected unhealthy again after resume")
+	}
+	if err := w.SetMountPaused("/mnt/unknown", true); !errors.Is(err, ErrMountPointNotFound) {
+		t.Errorf("expected ErrMountPointNotFound, got %v", err)
+	}
+}
+
+func TestAddAndRemoveMountPoint(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{{Path: "/mnt/a", Alias: "a"}}, WatchdogOptions{CheckInterval: time.Second})
+
+	diff, err := w.AddMountPoint(MountPoint{Path: "/mnt/b"})
+	if err != nil || len(diff.Added) != 1 || diff.Added[0] != "/mnt/b" {
+		t.Fatalf("unexpected diff %+v, error %v", diff, err)
+	}
+	for _, mp := range []MountPoint{{Path: "/mnt/b"}, {Path: "/mnt/c", Alias: "a"}} {
+		if _, err := w.AddMountPoint(mp); !errors.Is(err, ErrMountPointConflict) {
+			t.Errorf("adding %+v: expected ErrMountPointConflict, got %v", mp, err)
+		}
+	}
+
+	if err := w.SetMountPaused("/mnt/b", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff, err = w.RemoveMountPoint("/mnt/b"); err != nil || len(diff.Removed) != 1 {
+		t.Fatalf("unexpected diff %+v, error %v", diff, err)
+	}
+	if w.IsMountPaused("/mnt/b") {
+		t.Error("expected the paused state to be removed with the mount point")
+	}
+	if got := testutil.CollectAndCount(w.nfsPaused); got != 0 {
+		t.Errorf("expected no mount_paused series, got %d", got)
+	}
+	if _, err := w.RemoveMountPoint("/mnt/b"); !errors.Is(err, ErrMountPointNotFound) {
+		t.Errorf("expected ErrMountPointNotFound, got %v", err)
+	}
+
+	w.SetDraining()
+	if _, err := w.AddMountPoint(MountPoint{Path: "/mnt/d"}); !errors.Is(err, ErrDraining) {
+		t.Errorf("expected ErrDraining, got %v", err)
+	}
+}
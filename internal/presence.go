@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PresenceCollector reports whether each mount point is present as an NFS mount,
+// checked at scrape time. Results are cached for cacheTTL and every check is
+// bounded by timeout, so a hung mount cannot stall the scrape.
+type PresenceCollector struct {
+	watchdog *Watchdog
+	cacheTTL time.Duration
+	timeout  time.Duration
+	check    func(mountPoint string) error
+	desc     *prometheus.Desc
+
+	mu      sync.Mutex
+	cache   map[string]presenceResult
+	running map[string]chan struct{}
+}
+
+type presenceResult struct {
+	present   bool
+	checkedAt time.Time
+}
+
+func NewPresenceCollector(namespace string, watchdog *Watchdog, cacheTTL, timeout time.Duration) *PresenceCollector {
+	return &PresenceCollector{
+		watchdog: watchdog,
+		cacheTTL: cacheTTL,
+		timeout:  timeout,
+		check: func(mountPoint string) error {
+			_, err := watchdog.checkPresent(watchdog.mountPoint(mountPoint), readMountTable(watchdog.mounts))
+			return err
+		},
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "mount_present"),
+			"1 if the mount point is present as an NFS mount, checked at scrape time",
+			watchdog.labels.names(), nil,
+		),
+		cache:   make(map[string]presenceResult),
+		running: make(map[string]chan struct{}),
+	}
+}
+
+func (c *PresenceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *PresenceCollector) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for _, mp := range c.watchdog.MountPoints() {
+		wg.Add(1)
+		go func(mp MountPoint) {
+			defer wg.Done()
+			value := 0.0
+			if c.present(mp.Path) {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, c.watchdog.labels.values(mp)...)
+		}(mp)
+	}
+	wg.Wait()
+}
+
+// present returns the cached result when fresh, otherwise runs a check and waits
+// for it at most c.timeout. A check still running from an earlier scrape is
+// reused rather than started again.
+func (c *PresenceCollector) present(mountPoint string) bool {
+	c.mu.Lock()
+	if r, ok := c.cache[mountPoint]; ok && time.Since(r.checkedAt) < c.cacheTTL {
+		c.mu.Unlock()
+		return r.present
+	}
+	done, ok := c.running[mountPoint]
+	if !ok {
+		done = make(chan struct{})
+		c.running[mountPoint] = done
+		go func() {
+			present := c.check(mountPoint) == nil
+			c.mu.Lock()
+			c.cache[mountPoint] = presenceResult{present: present, checkedAt: time.Now()}
+			delete(c.running, mountPoint)
+			c.mu.Unlock()
+			close(done)
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.cache[mountPoint].present
+	case <-time.After(c.timeout):
+		return false
+	}
+}
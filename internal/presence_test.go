@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPresenceCollectorCachesResults(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a"}, map[string]bool{"/mnt/a": false})
+	c := NewPresenceCollector("test_ns", w, time.Minute, time.Second)
+
+	var calls atomic.Int32
+	c.check = func(string) error {
+		calls.Add(1)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if !c.present("/mnt/a") {
+			t.Fatalf("expected mount point to be present")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single check within the cache TTL, got %d", got)
+	}
+}
+
+func TestPresenceCollectorReportsFailedCheck(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a"}, map[string]bool{"/mnt/a": false})
+	c := NewPresenceCollector("test_ns", w, 0, time.Second)
+	c.check = func(string) error { return errors.New("not mounted") }
+
+	if c.present("/mnt/a") {
+		t.Errorf("expected mount point to be reported absent")
+	}
+}
+
+func TestPresenceCollectorTimesOutHungCheck(t *testing.T) {
+	w := newTestWatchdog([]string{"/mnt/a"}, map[string]bool{"/mnt/a": false})
+	c := NewPresenceCollector("test_ns", w, time.Minute, 10*time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+	var calls atomic.Int32
+	c.check = func(string) error {
+		calls.Add(1)
+		<-release
+		return nil
+	}
+
+	start := time.Now()
+	if c.present("/mnt/a") {
+		t.Errorf("expected hung mount point to be reported absent")
+	}
+	if c.present("/mnt/a") {
+		t.Errorf("expected hung mount point to be reported absent")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected bounded check, took %s", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected the hung check to be reused, got %d checks", got)
+	}
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeHelperEnv marks a process started as the write test helper. The agent
+// re-executes itself with this variable set to run the probe under another
+// identity, since Go cannot switch the uid of a single goroutine.
+const ProbeHelperEnv = "NFS_MOUNTER_AGENT_PROBE_HELPER"
+
+// Exit codes of the probe helper.
+const (
+	probeExitFailed  = 1
+	probeExitCleanup = 2
+	probeExitUsage   = 64
+)
+
+// ProbeCredential is the identity the write test probe runs as.
+type ProbeCredential struct {
+	UID uint32
+	GID uint32
+}
+
+// probeExecutable returns the program started as the probe helper,
+// replaceable in tests.
+var probeExecutable = os.Executable
+
+// probe runs the write test of mp with its payload size and fsync setting, in
+// a helper process dropped to the probe credential when one is configured. The
+// helper is killed when the context given to Start is cancelled. It returns
+// the read-back duration.
+func (m *Watchdog) probe(mp MountPoint) (time.Duration, error) {
+	verify := m.writeVerify
+	verify.size, verify.subdir = mp.WriteTestSize, mp.WriteTestDir
+	if mp.WriteFsync != nil {
+		verify.fsync = *mp.WriteFsync
+	}
+	if m.probeCredential == nil {
+		return probeWrite(mp.CheckDir(), verify)
+	}
+	m.mu.RLock()
+	ctx := m.probeCtx
+	m.mu.RUnlock()
+	return probeWriteAs(ctx, mp.CheckDir(), verify, *m.probeCredential)
+}
+
+// probeWriteAs runs probeWrite in a helper process with the given identity,
+// killed when ctx is done. The helper reports failures on stderr, a cleanup
+// failure by its exit code and the read-back duration on stdout.
+func probeWriteAs(ctx context.Context, dir string, verify writeVerify, cred ProbeCredential) (time.Duration, error) {
+	exe, err := probeExecutable()
+	if err != nil {
+		return 0, fmt.Errorf("cannot find the probe helper: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, exe, dir, strconv.FormatBool(verify.enabled), verify.skew.String(), strconv.FormatBool(verify.fsync), strconv.Itoa(verify.size), verify.subdir, verify.owner)
+	cmd.Env = append(os.Environ(), ProbeHelperEnv+"=1")
+	if err := setProbeCredential(cmd, cred); err != nil {
+		return 0, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	// A missing or garbled duration only loses the read latency sample.
+	read, _ := time.ParseDuration(strings.TrimSpace(stdout.String()))
+	message := strings.TrimSpace(stderr.String())
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return read, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == probeExitCleanup:
+		return read, fmt.Errorf("%w: %s", errProbeCleanup, message)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == probeExitFailed:
+		return read, newHelperError(message)
+	case message != "":
+		return 0, fmt.Errorf("probe helper as uid %d gid %d failed: %w: %s", cred.UID, cred.GID, err, message)
+	default:
+		return 0, fmt.Errorf("probe helper as uid %d gid %d failed: %w", cred.UID, cred.GID, err)
+	}
+}
+
+// RunProbeHelper is the entry point of the probe helper process. It takes the
+// directory, the verification flag, the tolerated skew, the fsync flag, the
+// payload size, the write test subdirectory, possibly empty, and the owner of
+// the probe file name as arguments, prints the read-back duration and returns
+// the exit code.
+func RunProbeHelper(args []string) int {
+	if len(args) != 7 {
+		fmt.Fprintf(os.Stderr, "usage: %s=1 <program> DIR VERIFY SKEW FSYNC SIZE SUBDIR OWNER\n", ProbeHelperEnv)
+		return probeExitUsage
+	}
+	enabled, err := strconv.ParseBool(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid verify argument: %v\n", err)
+		return probeExitUsage
+	}
+	skew, err := time.ParseDuration(args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid skew argument: %v\n", err)
+		return probeExitUsage
+	}
+
+	fsync, err := strconv.ParseBool(args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid fsync argument: %v\n", err)
+		return probeExitUsage
+	}
+
+	size, err := strconv.Atoi(args[4])
+	if err != nil || size < 0 || size > MaxWriteTestSize {
+		fmt.Fprintf(os.Stderr, "invalid size argument: %q\n", args[4])
+		return probeExitUsage
+	}
+
+	read, err := probeWrite(args[0], writeVerify{enabled: enabled, skew: skew, fsync: fsync, size: size, subdir: args[5], owner: args[6]})
+	if read > 0 {
+		fmt.Println(read)
+	}
+	if err == nil {
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, err)
+	if errors.Is(err, errProbeCleanup) {
+		return probeExitCleanup
+	}
+	return probeExitFailed
+}
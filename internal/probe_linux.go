@@ -0,0 +1,25 @@
+//go:build linux
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setProbeCredential makes cmd run as cred with no supplementary groups.
+func setProbeCredential(cmd *exec.Cmd, cred ProbeCredential) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: cred.UID, Gid: cred.GID, Groups: []uint32{}},
+	}
+	return nil
+}
+
+// dropPageCache evicts the cached pages of f, so the next read of the file is
+// served by the NFS server rather than the client cache.
+func dropPageCache(f *os.File) error {
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// worldReadableDir returns a temporary directory that other users can
+// traverse, unlike t.TempDir, whose parent is private.
+func worldReadableDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// useProbeHelperCopy points the probe helper to a copy of the test binary
+// that other users can execute.
+func useProbeHelperCopy(t *testing.T, dir string) {
+	t.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	helper := filepath.Join(dir, "helper")
+	dst, err := os.OpenFile(helper, os.O_CREATE|os.O_WRONLY, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	original := probeExecutable
+	probeExecutable = func() (string, error) { return helper, nil }
+	t.Cleanup(func() { probeExecutable = original })
+}
+
+func TestProbeWriteAsDropsPrivileges(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the probe identity requires root")
+	}
+	root := worldReadableDir(t)
+	useProbeHelperCopy(t, root)
+	nobody := ProbeCredential{UID: 65534, GID: 65534}
+
+	dir := filepath.Join(root, "mount")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := probeWriteAs(context.Background(), dir, writeVerify{}, nobody)
+	if err == nil || !strings.HasPrefix(err.Error(), "open "+dir) || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected the helper to be denied writing as nobody, got %v", err)
+	}
+	if _, err := probeWrite(dir, writeVerify{}); err != nil {
+		t.Fatalf("expected the probe as root to pass, got %v", err)
+	}
+
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	read, err := probeWriteAs(context.Background(), dir, writeVerify{enabled: true, skew: time.Minute, fsync: true}, nobody)
+	if err != nil {
+		t.Errorf("expected the probe as nobody to pass in a world-writable directory, got %v", err)
+	}
+	if read <= 0 {
+		t.Errorf("expected the helper to report the read-back duration, got %v", read)
+	}
+}
+
+func TestWatchdogWriteTestUsesProbeCredential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the probe identity requires root")
+	}
+	root := worldReadableDir(t)
+	useProbeHelperCopy(t, root)
+
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{
+		CheckInterval:   time.Second,
+		EnableWriteTest: true,
+		ProbeCredential: &ProbeCredential{UID: 65534, GID: 65534},
+	})
+	if err := w.writeTest(MountPoint{Path: root}); err == nil {
+		t.Error("expected the write test as nobody to fail in a directory owned by root")
+	}
+}
+
+func TestProbeWriteAsStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := probeWriteAs(ctx, t.TempDir(), writeVerify{}, ProbeCredential{UID: 65534, GID: 65534}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected no helper to run after the agent stopped, got %v", err)
+	}
+}
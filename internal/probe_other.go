@@ -0,0 +1,18 @@
+//go:build !linux
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setProbeCredential(*exec.Cmd, ProbeCredential) error {
+	return errors.New("running the write test as another user is only supported on linux")
+}
+
+// dropPageCache is a no-op: the read-back may be served by the client cache.
+func dropPageCache(*os.File) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain lets the test binary act as the probe helper, as the agent does.
+func TestMain(m *testing.M) {
+	if os.Getenv(ProbeHelperEnv) != "" {
+		os.Exit(RunProbeHelper(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunProbeHelper(t *testing.T) {
+	if code := RunProbeHelper([]string{t.TempDir(), "true", "1m", "true", "0", "", "vm-1_cafe0000"}); code != 0 {
+		t.Errorf("expected exit code 0 for a writable directory, got %d", code)
+	}
+	if code := RunProbeHelper([]string{t.TempDir(), "true", "0s", "false", "4096", ".probe", "vm-1_cafe0000"}); code != 0 {
+		t.Errorf("expected exit code 0 for a new write test directory, got %d", code)
+	}
+	if code := RunProbeHelper([]string{"/nonexistent", "false", "0s", "false", "0", "", ""}); code != probeExitFailed {
+		t.Errorf("expected exit code %d for a missing directory, got %d", probeExitFailed, code)
+	}
+	for _, args := range [][]string{nil, {"/tmp", "true", "0s"}, {"/tmp", "maybe", "0s", "false"}, {"/tmp", "true", "soon", "false"}, {"/tmp", "true", "0s", "later", "0", "", ""}, {"/tmp", "true", "0s", "false", "-1", "", ""}} {
+		if code := RunProbeHelper(args); code != probeExitUsage {
+			t.Errorf("expected exit code %d for arguments %q, got %d", probeExitUsage, args, code)
+		}
+	}
+}
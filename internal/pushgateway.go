@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// maxPushBackoff caps the delay between pushes after consecutive failures.
+const maxPushBackoff = 5 * time.Minute
+
+// PushgatewayPusher periodically pushes a metrics registry to a Prometheus
+// Pushgateway, for agents that cannot be scraped. The group is identified by
+// the job and instance labels.
+type PushgatewayPusher struct {
+	pusher           *push.Pusher
+	interval         time.Duration
+	deleteOnShutdown bool
+	trigger          chan struct{}
+	pushesTotal      *prometheus.CounterVec
+}
+
+// NewPushgatewayPusher pushes gatherer to the Pushgateway at url every
+// interval, and registers its push counter with reg. The instance label is
+// the hostname when empty. With deleteOnShutdown, the group is deleted when
+// Run stops, otherwise a final push records the last state.
+func NewPushgatewayPusher(namespace, url, job, instance string, interval time.Duration, deleteOnShutdown bool, reg prometheus.Registerer, gatherer prometheus.Gatherer) *PushgatewayPusher {
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			slog.Warn("cannot determine hostname for the pushgateway instance label", "error", err.Error())
+			hostname = "unknown"
+		}
+		instance = hostname
+	}
+	return &PushgatewayPusher{
+		pusher: push.New(url, job).
+			Gatherer(gatherer).
+			Grouping("instance", instance).
+			// A push never outlives its interval, so pushes cannot pile up.
+			Client(&http.Client{Timeout: interval}),
+		interval:         interval,
+		deleteOnShutdown: deleteOnShutdown,
+		trigger:          make(chan struct{}, 1),
+
+		pushesTotal: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "pushgateway_pushes_total",
+				Help:      "Number of Pushgateway pushes by result (success, failed)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+// Run pushes every interval until ctx is cancelled, then pushes a last time or
+// deletes the group. After a failure, the delay doubles up to maxPushBackoff.
+func (p *PushgatewayPusher) Run(ctx context.Context) {
+	delay := p.interval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.shutdown()
+			return
+		case <-p.trigger:
+			if delay > p.interval {
+				// Backing off after failed pushes, the timer retries.
+				continue
+			}
+		case <-timer.C:
+		}
+		if err := p.pusher.PushContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			p.pushesTotal.WithLabelValues("failed").Inc()
+			delay = min(2*delay, max(maxPushBackoff, p.interval))
+			slog.Warn("pushgateway push failed", "next_attempt_in", delay.String(), "error", err.Error())
+		} else {
+			p.pushesTotal.WithLabelValues("success").Inc()
+			delay = p.interval
+		}
+		timer.Reset(delay)
+	}
+}
+
+// Trigger pushes as soon as possible rather than at the next interval, e.g.
+// after every check cycle, so the Pushgateway gets each snapshot. A push
+// already pending absorbs the trigger.
+func (p *PushgatewayPusher) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// shutdown leaves no stale series of a terminated agent: it deletes the group
+// or replaces it with the final state.
+func (p *PushgatewayPusher) shutdown() {
+	if p.deleteOnShutdown {
+		// Bounded by the client timeout.
+		if err := p.pusher.Delete(); err != nil {
+			slog.Warn("cannot delete the pushgateway group", "error", err.Error())
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+	if err := p.Push(ctx); err != nil {
+		slog.Warn("final pushgateway push failed", "error", err.Error())
+	}
+}
+
+// Push replaces the group with the current state at once, e.g. before a
+// single check cycle exits, without Run.
+func (p *PushgatewayPusher) Push(ctx context.Context) error {
+	if err := p.pusher.PushContext(ctx); err != nil {
+		p.pushesTotal.WithLabelValues("failed").Inc()
+		return err
+	}
+	p.pushesTotal.WithLabelValues("success").Inc()
+	return nil
+}
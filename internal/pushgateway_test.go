@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// pushgatewayRecorder records the requests received by a fake Pushgateway.
+type pushgatewayRecorder struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+	status   int
+}
+
+func (r *pushgatewayRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	r.bodies = append(r.bodies, string(body))
+	w.WriteHeader(r.status)
+}
+
+func (r *pushgatewayRecorder) snapshot() ([]string, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests...), append([]string(nil), r.bodies...)
+}
+
+func newPushgatewayTestRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_ns_mount_healthy", Help: "test"})
+	gauge.Set(1)
+	reg.MustRegister(gauge)
+	return reg
+}
+
+func TestPushgatewayPusherRun(t *testing.T) {
+	host, _ := os.Hostname()
+
+	for _, deleteOnShutdown := range []bool{false, true} {
+		recorder := &pushgatewayRecorder{status: http.StatusOK}
+		srv := httptest.NewServer(recorder)
+		p := NewPushgatewayPusher("test_ns", srv.URL, "nfs_mounter_agent", "", 10*time.Millisecond, deleteOnShutdown, nil, newPushgatewayTestRegistry())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			p.Run(ctx)
+			close(done)
+		}()
+		for deadline := time.Now().Add(2 * time.Second); testutil.ToFloat64(p.pushesTotal.WithLabelValues("success")) < 1; {
+			if time.Now().After(deadline) {
+				t.Fatal("no push received")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-done
+		srv.Close()
+
+		requests, bodies := recorder.snapshot()
+		group := "/metrics/job/nfs_mounter_agent/instance/" + host
+		if requests[0] != "PUT "+group || !strings.Contains(bodies[0], "test_ns_mount_healthy") {
+			t.Errorf("unexpected first push %q: %q", requests[0], bodies[0])
+		}
+		last := requests[len(requests)-1]
+		if deleteOnShutdown && last != "DELETE "+group {
+			t.Errorf("expected the group to be deleted on shutdown, got %q", last)
+		}
+		if !deleteOnShutdown && last != "PUT "+group {
+			t.Errorf("expected a final push on shutdown, got %q", last)
+		}
+	}
+}
+
+func TestPushgatewayPusherCountsFailures(t *testing.T) {
+	srv := httptest.NewServer(&pushgatewayRecorder{status: http.StatusInternalServerError})
+	defer srv.Close()
+	p := NewPushgatewayPusher("test_ns", srv.URL, "job", "", 10*time.Millisecond, false, nil, newPushgatewayTestRegistry())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	for deadline := time.Now().Add(2 * time.Second); testutil.ToFloat64(p.pushesTotal.WithLabelValues("failed")) < 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a failed push to be counted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPushgatewayPusherTrigger(t *testing.T) {
+	recorder := &pushgatewayRecorder{status: http.StatusOK}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	p := NewPushgatewayPusher("test_ns", srv.URL, "ci", "runner-42", time.Hour, false, nil, newPushgatewayTestRegistry())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+	p.Trigger()
+	for deadline := time.Now().Add(2 * time.Second); testutil.ToFloat64(p.pushesTotal.WithLabelValues("success")) < 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a push on trigger, long before the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if requests, _ := recorder.snapshot(); requests[0] != "PUT /metrics/job/ci/instance/runner-42" {
+		t.Errorf("expected the configured grouping, got %q", requests[0])
+	}
+}
+
+func TestPushgatewayPusherPush(t *testing.T) {
+	recorder := &pushgatewayRecorder{status: http.StatusOK}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	p := NewPushgatewayPusher("test_ns", srv.URL, "ci", "runner-42", time.Hour, true, nil, newPushgatewayTestRegistry())
+
+	// Without Run, as with --once.
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("unexpected push error: %v", err)
+	}
+	if requests, _ := recorder.snapshot(); len(requests) != 1 || requests[0] != "PUT /metrics/job/ci/instance/runner-42" {
+		t.Errorf("expected a single push, got %q", requests)
+	}
+	if got := testutil.ToFloat64(p.pushesTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("expected 1 successful push, got %v", got)
+	}
+
+	recorder.mu.Lock()
+	recorder.status = http.StatusInternalServerError
+	recorder.mu.Unlock()
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("expected an error from a failed push")
+	}
+	if got := testutil.ToFloat64(p.pushesTotal.WithLabelValues("failed")); got != 1 {
+		t.Errorf("expected 1 failed push, got %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"net/http"
+	"time"
+)
+
+// Readiness states, from best to worst.
+const (
+	ReadinessReady    = "ready"
+	ReadinessDegraded = "degraded"
+	ReadinessNotReady = "not_ready"
+)
+
+// Readiness summarizes mount health for orchestrators: ready when all mount
+// points are healthy, degraded when only optional ones are unhealthy or
+// healthy ones exceed the space warning threshold or, with
+// DegradeOnOptionDrift, have drifted mount options, and not
+// ready when a critical (non-optional) mount point is unhealthy, the agent is
+// draining or no mount point is monitored at all (unless healthy when empty).
+// Pending, paused and silenced mount points are listed but do not affect the
+// state.
+type Readiness struct {
+	State             string   `json:"state"`
+	Draining          bool     `json:"draining,omitempty"`
+	NoMountPoints     bool     `json:"no_mount_points,omitempty"`
+	UnhealthyCritical []string `json:"unhealthy_critical"`
+	UnhealthyOptional []string `json:"unhealthy_optional"`
+	Pending           []string `json:"pending"`
+	Paused            []string `json:"paused"`
+	Silenced          []string `json:"silenced"`
+	SpaceLow          []string `json:"space_low"`
+	OptionDrift       []string `json:"option_drift"`
+}
+
+// Readiness returns the current readiness state and the mount points
+// responsible for it, each list sorted by path.
+func (m *Watchdog) Readiness() Readiness {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r := Readiness{Draining: m.draining, NoMountPoints: len(m.mountPoints) == 0, UnhealthyCritical: []string{}, UnhealthyOptional: []string{}, Pending: []string{}, Paused: []string{}, Silenced: []string{}, SpaceLow: []string{}, OptionDrift: []string{}}
+	now := time.Now()
+	for _, mp := range m.sortedMountPoints() {
+		if m.paused[mp.Path] {
+			r.Paused = append(r.Paused, mp.Path)
+			continue
+		}
+		if m.mountSilenced(mp, now) {
+			r.Silenced = append(r.Silenced, mp.Path)
+			continue
+		}
+		if m.pending[mp.Path] {
+			r.Pending = append(r.Pending, mp.Path)
+			continue
+		}
+		if m.lastHealthy[mp.Path] {
+			if m.spaceLow[mp.Path] {
+				r.SpaceLow = append(r.SpaceLow, mp.Path)
+			}
+			if m.driftDegrades && len(m.optionDrift[mp.Path]) > 0 {
+				r.OptionDrift = append(r.OptionDrift, mp.Path)
+			}
+			continue
+		}
+		if mp.Optional {
+			r.UnhealthyOptional = append(r.UnhealthyOptional, mp.Path)
+		} else {
+			r.UnhealthyCritical = append(r.UnhealthyCritical, mp.Path)
+		}
+	}
+
+	switch {
+	case r.Draining || len(r.UnhealthyCritical) > 0 || (r.NoMountPoints && !m.healthyWhenEmpty):
+		r.State = ReadinessNotReady
+	case len(r.UnhealthyOptional) > 0 || len(r.SpaceLow) > 0 || len(r.OptionDrift) > 0:
+		r.State = ReadinessDegraded
+	default:
+		r.State = ReadinessReady
+	}
+	return r
+}
+
+// ReadinessHandler serves the three-state readiness as JSON.
+type ReadinessHandler struct {
+	watchdog       *Watchdog
+	degradedStatus int
+}
+
+// NewReadinessHandler answers 200 when ready, degradedStatus when degraded
+// and 503 when not ready.
+func NewReadinessHandler(watchdog *Watchdog, degradedStatus int) *ReadinessHandler {
+	return &ReadinessHandler{watchdog: watchdog, degradedStatus: degradedStatus}
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r := h.watchdog.Readiness()
+	status := http.StatusOK
+	switch r.State {
+	case ReadinessDegraded:
+		status = h.degradedStatus
+	case ReadinessNotReady:
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, r)
+}
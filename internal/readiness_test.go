@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		healthy        map[string]bool
+		draining       bool
+		degradedStatus int
+		wantStatus     int
+		wantState      string
+		wantCritical   int
+		wantOptional   int
+	}{
+		{"ready", map[string]bool{"/mnt/a": true, "/mnt/b": true}, false, http.StatusOK, http.StatusOK, ReadinessReady, 0, 0},
+		{"degraded", map[string]bool{"/mnt/a": true, "/mnt/b": false}, false, http.StatusOK, http.StatusOK, ReadinessDegraded, 0, 1},
+		{"degraded as 503", map[string]bool{"/mnt/a": true, "/mnt/b": false}, false, http.StatusServiceUnavailable, http.StatusServiceUnavailable, ReadinessDegraded, 0, 1},
+		{"not ready", map[string]bool{"/mnt/a": false, "/mnt/b": false}, false, http.StatusOK, http.StatusServiceUnavailable, ReadinessNotReady, 1, 1},
+		{"draining", map[string]bool{"/mnt/a": true, "/mnt/b": true}, true, http.StatusOK, http.StatusServiceUnavailable, ReadinessNotReady, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchdog := newTestWatchdog(nil, tt.healthy)
+			watchdog.mountPoints = []MountPoint{{Path: "/mnt/a"}, {Path: "/mnt/b", Optional: true}}
+			watchdog.draining = tt.draining
+
+			rec := httptest.NewRecorder()
+			NewReadinessHandler(watchdog, tt.degradedStatus).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			var body Readiness
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+			}
+			if body.State != tt.wantState {
+				t.Errorf("expected state %q, got %q", tt.wantState, body.State)
+			}
+			if len(body.UnhealthyCritical) != tt.wantCritical || len(body.UnhealthyOptional) != tt.wantOptional {
+				t.Errorf("unexpected unhealthy mount points %+v", body)
+			}
+		})
+	}
+}
+
+func TestReadinessListsSortedByPath(t *testing.T) {
+	watchdog := newTestWatchdog(nil, map[string]bool{})
+	watchdog.mountPoints = []MountPoint{{Path: "/mnt/c"}, {Path: "/mnt/a"}, {Path: "/mnt/b"}}
+
+	got := watchdog.Readiness().UnhealthyCritical
+	if len(got) != 3 || got[0] != "/mnt/a" || got[1] != "/mnt/b" || got[2] != "/mnt/c" {
+		t.Errorf("expected unhealthy mount points sorted by path, got %v", got)
+	}
+}
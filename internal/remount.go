@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// remountTimeout bounds the unmount and the mount of a remount attempt, as
+// both can block on an unresponsive NFS server.
+const remountTimeout = 30 * time.Second
+
+// runMountCommand runs umount and mount for the exec mount backend,
+// replaceable in tests.
+var runMountCommand = func(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// maxRemountHistory is the number of remount attempts per mount point kept
+// for /status.
+const maxRemountHistory = 10
+
+// RemountEvent is a remount attempt of a mount point, as listed by /status.
+type RemountEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// recordRemount adds a remount attempt to the history of the mount point,
+// dropping the oldest beyond maxRemountHistory.
+func (m *Watchdog) recordRemount(mountPoint, action string, at time.Time, err error) {
+	event := RemountEvent{Time: at, Action: action, Result: "success"}
+	if err != nil {
+		event.Result, event.Error = "failed", err.Error()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lastHealthy[mountPoint]; !ok {
+		// Removed in the meantime, do not recreate its state.
+		return
+	}
+	m.eventLog.add(Event{Time: at, Type: EventRemount, MountPoint: mountPoint, Action: action, Result: event.Result, Error: event.Error})
+	history := append(m.remounts[mountPoint], event)
+	if len(history) > maxRemountHistory {
+		history = history[len(history)-maxRemountHistory:]
+	}
+	m.remounts[mountPoint] = history
+}
+
+// Remount actions, from the gentlest to the most forceful.
+const (
+	RemountActionRemount = "remount"      // mount -o remount, in place
+	RemountActionLazy    = "lazy_umount"  // umount -l, then mount
+	RemountActionForce   = "force_umount" // umount -f, then mount
+)
+
+// remountActions is the escalation ladder of the remount attempts.
+var remountActions = []string{RemountActionRemount, RemountActionLazy, RemountActionForce}
+
+// maxRemountBackoff bounds the doubling time between remount attempts.
+const maxRemountBackoff = 30 * time.Minute
+
+// remountLadder is the escalation state of the remount attempts of a mount
+// point while its checks keep failing.
+type remountLadder struct {
+	attempts  int
+	notBefore time.Time
+}
+
+// recordFailure counts consecutive failed checks of a mount point and returns
+// the remount action due, if any. The count restarts after every remount
+// attempt, so attempts are at least remountAfter checks and the backoff
+// apart. Every attempt escalates to the next action, up to a forced unmount;
+// a passed check starts over with an in-place remount.
+func (m *Watchdog) recordFailure(mp MountPoint, err error, now time.Time) (action string, due bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.failures, mp.Path)
+		delete(m.remountLadders, mp.Path)
+		return "", false
+	}
+	if !m.enableRemount || mp.RemountSource == "" || mp.Absent {
+		return "", false
+	}
+	m.failures[mp.Path]++
+	ladder := m.remountLadders[mp.Path]
+	if m.failures[mp.Path] < m.remountAfter || now.Before(ladder.notBefore) {
+		return "", false
+	}
+	m.failures[mp.Path] = 0
+	action = remountActions[min(ladder.attempts, len(remountActions)-1)]
+	if m.remountBackoff > 0 {
+		ladder.notBefore = now.Add(min(m.remountBackoff<<min(ladder.attempts, 16), maxRemountBackoff))
+	}
+	ladder.attempts++
+	m.remountLadders[mp.Path] = ladder
+	return action, true
+}
+
+// remount runs a remount action: an in-place remount, or an unmount, lazy or
+// forced, followed by a mount of the configured source. Both unmounts detach
+// the mount point even when the server is unresponsive.
+func (m *Watchdog) remount(mp MountPoint, action string) {
+	slog.Warn("remounting", "mountpoint", mp.Path, "source", mp.RemountSource, "action", action, "failed_checks", m.remountAfter)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), remountTimeout)
+	defer cancel()
+
+	var err error
+	switch action {
+	case RemountActionRemount:
+		err = m.mountBackend.Remount(ctx, mp)
+	default:
+		force := action == RemountActionForce
+		if err := m.mountBackend.Unmount(ctx, mp.Path, force, !force); err != nil {
+			// Not mounted at all is fine, the mount below is what matters.
+			slog.Info("umount failed, mounting anyway", "mountpoint", mp.Path, "action", action, "error", err.Error())
+		}
+		err = m.mountBackend.Mount(ctx, mp)
+	}
+	if err != nil {
+		m.recordRemount(mp.Path, action, start, err)
+		m.nfsRemountsTotal.WithLabelValues(m.labels.values(mp, action, "failed")...).Inc()
+		slog.Error("remount failed", "mountpoint", mp.Path, "action", action, "error", err.Error())
+		return
+	}
+	m.recordRemount(mp.Path, action, start, nil)
+	m.nfsRemountsTotal.WithLabelValues(m.labels.values(mp, action, "success")...).Inc()
+	slog.Info("remounted", "mountpoint", mp.Path, "source", mp.RemountSource, "action", action)
+}
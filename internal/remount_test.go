@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeMountCommands records the umount and mount commands instead of running them.
+func fakeMountCommands(t *testing.T, mountErr error) *[]string {
+	t.Helper()
+	var commands []string
+	original := runMountCommand
+	runMountCommand = func(_ context.Context, name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if name == "mount" {
+			return mountErr
+		}
+		return nil
+	}
+	t.Cleanup(func() { runMountCommand = original })
+	return &commands
+}
+
+func TestRemountAfterConsecutiveFailures(t *testing.T) {
+	commands := fakeMountCommands(t, nil)
+
+	mp := MountPoint{
+		Path:           "/this/path/should/not/exist/for_nfs_watchdog_test",
+		RemountSource:  "nfs1:/export",
+		RemountOptions: []string{"hard", "vers=4.1"},
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval: time.Second,
+		EnableRemount: true,
+		RemountAfter:  2,
+		EventLogSize:  20,
+	})
+
+	w.CheckMountPoint(mp)
+	if len(*commands) != 0 {
+		t.Fatalf("expected no remount after one failure, got %q", *commands)
+	}
+	w.CheckMountPoint(mp)
+	want := []string{"mount -o remount,hard,vers=4.1 " + mp.Path}
+	if !slices.Equal(*commands, want) {
+		t.Fatalf("expected %q, got %q", want, *commands)
+	}
+	if got := testutil.ToFloat64(w.nfsRemountsTotal.WithLabelValues(mp.Path, mp.Path, RemountActionRemount, "success")); got != 1 {
+		t.Errorf("expected one successful remount, got %v", got)
+	}
+
+	// The count restarts after an attempt.
+	w.CheckMountPoint(mp)
+	if len(*commands) != 1 {
+		t.Errorf("expected the next attempt only after another %d failures, got %q", w.remountAfter, *commands)
+	}
+
+	// Failing on, the attempts escalate to a lazy and then a forced unmount.
+	for range 5 {
+		w.CheckMountPoint(mp)
+	}
+	want = append(want,
+		"umount -l "+mp.Path, "mount -t nfs -o hard,vers=4.1 nfs1:/export "+mp.Path,
+		"umount -f "+mp.Path, "mount -t nfs -o hard,vers=4.1 nfs1:/export "+mp.Path,
+		"umount -f "+mp.Path, "mount -t nfs -o hard,vers=4.1 nfs1:/export "+mp.Path,
+	)
+	if !slices.Equal(*commands, want) {
+		t.Fatalf("expected %q, got %q", want, *commands)
+	}
+	if got := testutil.ToFloat64(w.nfsRemountsTotal.WithLabelValues(mp.Path, mp.Path, RemountActionForce, "success")); got != 2 {
+		t.Errorf("expected two forced remounts, got %v", got)
+	}
+	var actions []string
+	for _, e := range w.eventLog.Events() {
+		if e.Type == EventRemount {
+			actions = append(actions, e.Action)
+		}
+	}
+	if want := []string{RemountActionRemount, RemountActionLazy, RemountActionForce, RemountActionForce}; !slices.Equal(actions, want) {
+		t.Errorf("expected remount events %v, got %v", want, actions)
+	}
+
+	// A passed check starts over with an in-place remount.
+	if action, due := w.recordFailure(mp, nil, time.Now()); due || action != "" {
+		t.Errorf("expected no remount after a passed check, got %q", action)
+	}
+	if action, _ := w.recordFailure(mp, errors.New("stale"), time.Now()); action != "" {
+		t.Errorf("expected no remount after a single failure, got %q", action)
+	}
+	if action, _ := w.recordFailure(mp, errors.New("stale"), time.Now()); action != RemountActionRemount {
+		t.Errorf("expected the ladder to start over, got %q", action)
+	}
+}
+
+func TestRemountBackoff(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a", RemountSource: "nfs1:/export"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:  time.Second,
+		EnableRemount:  true,
+		RemountAfter:   1,
+		RemountBackoff: time.Minute,
+	})
+	failed := errors.New("stale file handle")
+	now := time.Now()
+
+	if _, due := w.recordFailure(mp, failed, now); !due {
+		t.Fatal("expected a first remount at once")
+	}
+	if _, due := w.recordFailure(mp, failed, now.Add(59*time.Second)); due {
+		t.Error("expected no remount within the backoff")
+	}
+	if action, due := w.recordFailure(mp, failed, now.Add(time.Minute)); !due || action != RemountActionLazy {
+		t.Errorf("expected a lazy unmount after the backoff, got %q", action)
+	}
+	if _, due := w.recordFailure(mp, failed, now.Add(2*time.Minute)); due {
+		t.Error("expected the backoff to double after the second attempt")
+	}
+	if _, due := w.recordFailure(mp, failed, now.Add(3*time.Minute)); !due {
+		t.Error("expected a remount once the doubled backoff passed")
+	}
+}
+
+func TestRemountFailureAndOptOut(t *testing.T) {
+	commands := fakeMountCommands(t, errors.New("mount.nfs: Connection timed out"))
+
+	mp := MountPoint{Path: "/this/path/should/not/exist/for_nfs_watchdog_test", RemountSource: "nfs1:/export"}
+	noSource := MountPoint{Path: "/this/path/should/not/exist/either"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp, noSource}, WatchdogOptions{
+		CheckInterval: time.Second,
+		EnableRemount: true,
+		RemountAfter:  1,
+	})
+
+	w.CheckMountPoint(mp)
+	if got := testutil.ToFloat64(w.nfsRemountsTotal.WithLabelValues(mp.Path, mp.Path, RemountActionRemount, "failed")); got != 1 {
+		t.Errorf("expected one failed remount, got %v", got)
+	}
+
+	*commands = nil
+	w.CheckMountPoint(noSource)
+	w.enableRemount = false
+	w.CheckMountPoint(mp)
+	if len(*commands) != 0 {
+		t.Errorf("expected no remount without a source or with remounting disabled, got %q", *commands)
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// checkScheduler tracks when each mount point is next due, so mount points
+// with their own check interval are checked independently of the global one.
+// It is owned by the check loop and not safe for concurrent use.
+type checkScheduler struct {
+	next map[string]time.Time
+	// jitter lengthens or shortens every delay by up to this fraction of it,
+	// drawn by rand, so the checks of many agents drift apart.
+	jitter float64
+	rand   func() float64
+}
+
+func newCheckScheduler(jitter float64) *checkScheduler {
+	return &checkScheduler{next: make(map[string]time.Time), jitter: jitter, rand: rand.Float64}
+}
+
+// spread applies the jitter to delay.
+func (s *checkScheduler) spread(delay time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return delay
+	}
+	return time.Duration(float64(delay) * (1 + s.jitter*(2*s.rand()-1)))
+}
+
+// intervalOf returns the check interval of mp: its own, or the global one.
+func intervalOf(mp MountPoint, global time.Duration) time.Duration {
+	if mp.CheckInterval > 0 {
+		return mp.CheckInterval
+	}
+	return global
+}
+
+// reset schedules every mount point one interval after now, e.g. after a
+// check of all mount points or a change of the global interval.
+func (s *checkScheduler) reset(points []MountPoint, global time.Duration, now time.Time) {
+	s.next = make(map[string]time.Time, len(points))
+	for _, mp := range points {
+		s.next[mp.Path] = now.Add(s.spread(intervalOf(mp, global)))
+	}
+}
+
+// due returns the mount points due at now and schedules their next check.
+// Mount points added since the last call are due at once; removed ones are
+// forgotten.
+func (s *checkScheduler) due(points []MountPoint, global time.Duration, now time.Time) []MountPoint {
+	var due []MountPoint
+	next := make(map[string]time.Time, len(points))
+	for _, mp := range points {
+		at, ok := s.next[mp.Path]
+		if !ok || !now.Before(at) {
+			due = append(due, mp)
+			at = now.Add(s.spread(intervalOf(mp, global)))
+		}
+		next[mp.Path] = at
+	}
+	s.next = next
+	return due
+}
+
+// retry brings the next check of mp forward to delay from now, unless it is
+// due sooner anyway.
+func (s *checkScheduler) retry(mp MountPoint, delay time.Duration, now time.Time) {
+	retryAt := now.Add(s.spread(delay))
+	if at, ok := s.next[mp.Path]; !ok || retryAt.Before(at) {
+		s.next[mp.Path] = retryAt
+	}
+}
+
+// retryDelay returns how soon a mount point failing its last checks is checked
+// again: the retry interval after the first failed check, multiplied by the
+// backoff for every further one, at most interval. ok is false for a mount
+// point passing its last check, or without a retry interval.
+func (m *Watchdog) retryDelay(mp MountPoint, interval time.Duration) (delay time.Duration, ok bool) {
+	if m.retryInterval <= 0 {
+		return 0, false
+	}
+	m.mu.RLock()
+	failed := m.streaks[mp.Path].failed
+	m.mu.RUnlock()
+	if failed == 0 {
+		return 0, false
+	}
+	backoff := float64(m.retryInterval) * math.Pow(m.retryBackoff, float64(failed-1))
+	if backoff >= float64(interval) {
+		return interval, true
+	}
+	return time.Duration(backoff), true
+}
+
+// scheduleRetries brings the next check of the failing mount points among
+// points forward according to retryDelay.
+func (m *Watchdog) scheduleRetries(s *checkScheduler, points []MountPoint, global time.Duration, now time.Time) {
+	for _, mp := range points {
+		if delay, ok := m.retryDelay(mp, intervalOf(mp, global)); ok {
+			s.retry(mp, delay, now)
+		}
+	}
+}
+
+// wait returns the time from now until the next mount point is due, or the
+// global interval when no mount point is scheduled.
+func (s *checkScheduler) wait(global time.Duration, now time.Time) time.Duration {
+	var earliest time.Time
+	for _, at := range s.next {
+		if earliest.IsZero() || at.Before(earliest) {
+			earliest = at
+		}
+	}
+	if earliest.IsZero() {
+		return global
+	}
+	return max(earliest.Sub(now), 0)
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCheckSchedulerPerMountIntervals(t *testing.T) {
+	fast := MountPoint{Path: "/mnt/fast", CheckInterval: 5 * time.Second}
+	archive := MountPoint{Path: "/mnt/archive", CheckInterval: 5 * time.Minute}
+	plain := MountPoint{Path: "/mnt/plain"}
+	points := []MountPoint{fast, archive, plain}
+	global := 30 * time.Second
+
+	start := time.Now()
+	s := newCheckScheduler(0)
+	s.reset(points, global, start)
+	if got := s.wait(global, start); got != 5*time.Second {
+		t.Errorf("expected the fast mount point to be due first, waiting %s", got)
+	}
+
+	checks := map[string]int{}
+	for now := start; now.Before(start.Add(5 * time.Minute)); now = now.Add(s.wait(global, now)) {
+		for _, mp := range s.due(points, global, now) {
+			checks[mp.Path]++
+		}
+	}
+	// The first call at start finds nothing due.
+	if checks[fast.Path] != 59 || checks[plain.Path] != 9 || checks[archive.Path] != 0 {
+		t.Errorf("unexpected checks in 5 minutes: %v", checks)
+	}
+}
+
+func TestCheckSchedulerAddedAndRemovedMountPoints(t *testing.T) {
+	global := time.Minute
+	now := time.Now()
+	s := newCheckScheduler(0)
+	s.reset([]MountPoint{{Path: "/mnt/a"}}, global, now)
+
+	due := s.due([]MountPoint{{Path: "/mnt/b"}}, global, now)
+	if len(due) != 1 || due[0].Path != "/mnt/b" {
+		t.Errorf("expected an added mount point to be due at once, got %v", due)
+	}
+	if _, ok := s.next["/mnt/a"]; ok {
+		t.Errorf("expected a removed mount point to be forgotten")
+	}
+	if got := s.wait(global, now); got != global {
+		t.Errorf("expected the next check in %s, got %s", global, got)
+	}
+	if got := newCheckScheduler(0).wait(global, now); got != global {
+		t.Errorf("expected an empty scheduler to wait the global interval, got %s", got)
+	}
+}
+
+func TestRetryDelayBacksOff(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{
+		CheckInterval: time.Minute,
+		RetryInterval: 5 * time.Second,
+		RetryBackoff:  2,
+		MountsFile:    writeMountsFixture(t, ""),
+	})
+	mp := w.MountPoints()[0]
+	if _, ok := w.retryDelay(mp, time.Minute); ok {
+		t.Error("expected no retry before a failed check")
+	}
+
+	var delays []time.Duration
+	for range 5 {
+		w.CheckAll()
+		delay, ok := w.retryDelay(mp, time.Minute)
+		if !ok {
+			t.Fatal("expected a retry of the failing mount point")
+		}
+		delays = append(delays, delay)
+	}
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute}
+	if !slices.Equal(delays, want) {
+		t.Errorf("expected retries after %v, got %v", want, delays)
+	}
+
+	now := time.Now()
+	s := newCheckScheduler(0)
+	s.reset([]MountPoint{mp}, time.Minute, now)
+	w.streaks[mp.Path] = checkStreak{failed: 1}
+	w.scheduleRetries(s, []MountPoint{mp}, time.Minute, now)
+	if got := s.wait(time.Minute, now); got != 5*time.Second {
+		t.Errorf("expected the failing mount point re-checked in 5s, got %s", got)
+	}
+	w.streaks[mp.Path] = checkStreak{passed: 1}
+	s.reset([]MountPoint{mp}, time.Minute, now)
+	w.scheduleRetries(s, []MountPoint{mp}, time.Minute, now)
+	if got := s.wait(time.Minute, now); got != time.Minute {
+		t.Errorf("expected a passing mount point checked on its interval, got %s", got)
+	}
+}
+
+func TestCheckSchedulerJitter(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a"}
+	global := 30 * time.Second
+	now := time.Now()
+
+	s := newCheckScheduler(0.1)
+	for draw, want := range map[float64]time.Duration{0: 27 * time.Second, 0.5: global, 1: 33 * time.Second} {
+		s.rand = func() float64 { return draw }
+		s.reset([]MountPoint{mp}, global, now)
+		if got := s.wait(global, now); got != want {
+			t.Errorf("draw %v: expected the next check in %s, got %s", draw, want, got)
+		}
+	}
+
+	s = newCheckScheduler(0.1)
+	for range 100 {
+		s.reset([]MountPoint{mp}, global, now)
+		if got := s.wait(global, now); got < 27*time.Second || got > 33*time.Second {
+			t.Fatalf("expected the next check within ±10%% of %s, got %s", global, got)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SelfTestResult is the outcome of verifying a single environment assumption.
+type SelfTestResult struct {
+	Name string
+	Err  error
+}
+
+// SelfTest verifies the environment the watchdog depends on: a readable and
+// parsable mount table and, with the write test enabled, a writable mount point.
+func (m *Watchdog) SelfTest() []SelfTestResult {
+	var results []SelfTestResult
+
+	name := mountTableName(m.mounts)
+	entries, err := m.mounts.ListMounts()
+	results = append(results, SelfTestResult{Name: name + " is readable", Err: err})
+	if err == nil && len(entries) == 0 {
+		err = errors.New("no mount entries found")
+	}
+	results = append(results, SelfTestResult{Name: name + " has parsable mount entries", Err: err})
+
+	var writable []MountPoint
+	for _, mp := range m.MountPoints() {
+		if m.writeTestEnabled(mp) {
+			writable = append(writable, mp)
+		}
+	}
+	if len(writable) > 0 {
+		var failures []string
+		for _, mp := range writable {
+			if _, err := m.probe(mp); err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			failures = nil
+			break
+		}
+		if failures != nil {
+			err = fmt.Errorf("no mount point is writable: %s", strings.Join(failures, "; "))
+		} else {
+			err = nil
+		}
+		results = append(results, SelfTestResult{Name: "write test file can be created", Err: err})
+	}
+	return results
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeMountsFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("cannot write mounts fixture: %v", err)
+	}
+	return path
+}
+
+func TestSelfTestPasses(t *testing.T) {
+	mountsFile := writeMountsFixture(t, "server:/export /mnt/a nfs4 rw,hard 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/nonexistent", t.TempDir()), WatchdogOptions{
+		CheckInterval:   time.Second,
+		EnableWriteTest: true,
+		MountsFile:      mountsFile,
+	})
+
+	results := w.SelfTest()
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: unexpected failure: %v", r.Name, r.Err)
+		}
+	}
+}
+
+func TestSelfTestFails(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/nonexistent"), WatchdogOptions{
+		CheckInterval:   time.Second,
+		EnableWriteTest: true,
+		MountsFile:      writeMountsFixture(t, ""),
+	})
+
+	results := w.SelfTest()
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("expected empty mounts file to be readable, got %v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Errorf("expected empty mounts file to fail the parse check")
+	}
+	if results[2].Err == nil {
+		t.Errorf("expected write test to fail without writable mount points")
+	}
+}
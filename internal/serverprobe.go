@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ports of the server probe: NFS, unless a mount sets port=, and rpcbind.
+const (
+	nfsPort     = 2049
+	rpcbindPort = 111
+)
+
+// defaultServerProbeTimeout bounds a dial when no timeout is configured.
+const defaultServerProbeTimeout = 2 * time.Second
+
+// dialServer opens a TCP connection to a server, replaceable in tests.
+var dialServer = func(ctx context.Context, addr netip.AddrPort) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr.String())
+}
+
+// serverTargets returns the addresses and ports the server probe dials for
+// the monitored mounts. A mount point that is not mounted is probed at the
+// server of its remount source, so an outage is told apart from a client
+// problem also when the mount is gone.
+func (m *Watchdog) serverTargets(points []MountPoint, table *mountTable) []netip.AddrPort {
+	seen := make(map[netip.AddrPort]bool)
+	for _, mp := range points {
+		if mp.Absent {
+			continue
+		}
+		entry, err := table.findFor(mp)
+		if err != nil || !entry.isNFS() {
+			if mp.RemountSource == "" {
+				continue
+			}
+			entry = MountEntry{Source: mp.RemountSource, Options: mp.RemountOptions}
+		}
+		addrs, err := entry.serverAddrs()
+		if err != nil {
+			continue
+		}
+		ports := []uint16{nfsPortOf(entry)}
+		if m.serverProbeRPCBind {
+			ports = append(ports, rpcbindPort)
+		}
+		for _, addr := range addrs {
+			for _, port := range ports {
+				seen[netip.AddrPortFrom(addr, port)] = true
+			}
+		}
+	}
+	targets := make([]netip.AddrPort, 0, len(seen))
+	for target := range seen {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Compare(targets[j]) < 0 })
+	return targets
+}
+
+// nfsPortOf returns the NFS port of a mount, from its port= option if set.
+func nfsPortOf(entry MountEntry) uint16 {
+	for _, opt := range entry.Options {
+		if value, ok := strings.CutPrefix(opt, "port="); ok {
+			if port, err := strconv.ParseUint(value, 10, 16); err == nil && port != 0 {
+				return uint16(port)
+			}
+		}
+	}
+	return nfsPort
+}
+
+// probeServers dials every target concurrently, each bounded by the server
+// probe timeout, and exports whether the connection was accepted.
+func (m *Watchdog) probeServers(points []MountPoint, table *mountTable) {
+	targets := m.serverTargets(points, table)
+	reachable := make([]bool, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reachable[i] = probeServer(target, m.serverProbeTimeout)
+		}()
+	}
+	wg.Wait()
+
+	m.serverTCPReachable.Reset()
+	for i, target := range targets {
+		value := 0.0
+		if reachable[i] {
+			value = 1
+		}
+		m.serverTCPReachable.WithLabelValues(target.Addr().String(), strconv.Itoa(int(target.Port()))).Set(value)
+	}
+}
+
+// probeServer reports whether the server accepts a TCP connection on the
+// port within timeout.
+func probeServer(target netip.AddrPort, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := dialServer(ctx, target)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
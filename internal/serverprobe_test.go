@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServerTargets(t *testing.T) {
+	points := []MountPoint{
+		{Path: "/mnt/a"},
+		{Path: "/mnt/b"},
+		{Path: "/mnt/custom-port"},
+		{Path: "/mnt/unmounted", RemountSource: "10.0.0.3:/export"},
+		{Path: "/mnt/local"},
+		{Path: "/mnt/absent", Absent: true},
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{ServerProbeRPCBind: true})
+	table := readMountTable(MountsFile(writeMountsFixture(t, ""+
+		"10.0.0.1:/export/a /mnt/a nfs4 rw,addr=10.0.0.1 0 0\n"+
+		"10.0.0.1:/export/b /mnt/b nfs4 rw,addr=10.0.0.1 0 0\n"+
+		"10.0.0.2:/export /mnt/custom-port nfs rw,port=20049,addr=10.0.0.2 0 0\n"+
+		"tmpfs /mnt/local tmpfs rw 0 0\n"+
+		"10.0.0.4:/export /mnt/absent nfs4 rw 0 0\n")))
+
+	got := fmt.Sprint(w.serverTargets(points, table))
+	want := "[10.0.0.1:111 10.0.0.1:2049 10.0.0.2:111 10.0.0.2:20049 10.0.0.3:111 10.0.0.3:2049]"
+	if got != want {
+		t.Errorf("expected targets %s, got %s", want, got)
+	}
+}
+
+func TestProbeServersExportsReachability(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+	open := netip.MustParseAddrPort(listener.Addr().String()).Port()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	closedPort := netip.MustParseAddrPort(closed.Addr().String()).Port()
+	_ = closed.Close()
+
+	points := testMountPoints("/mnt/up", "/mnt/down")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{EnableServerProbe: true, ServerProbeTimeout: time.Second})
+	table := readMountTable(MountsFile(writeMountsFixture(t, fmt.Sprintf(""+
+		"127.0.0.1:/up /mnt/up nfs4 rw,port=%d,addr=127.0.0.1 0 0\n"+
+		"127.0.0.1:/down /mnt/down nfs4 rw,port=%d,addr=127.0.0.1 0 0\n", open, closedPort))))
+
+	w.probeServers(points, table)
+	if got := testutil.ToFloat64(w.serverTCPReachable.WithLabelValues("127.0.0.1", fmt.Sprint(open))); got != 1 {
+		t.Errorf("expected the listening port to be reachable, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.serverTCPReachable.WithLabelValues("127.0.0.1", fmt.Sprint(closedPort))); got != 0 {
+		t.Errorf("expected the closed port to be unreachable, got %v", got)
+	}
+}
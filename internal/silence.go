@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxMountSilence bounds a silence set through the API, so a forgotten
+// maintenance window cannot hide a mount point from the global health for good.
+const MaxMountSilence = 7 * 24 * time.Hour
+
+// MountSilence is a maintenance window of a mount point: it is checked and
+// exported as usual but left out of the global health and readiness.
+type MountSilence struct {
+	MountPoint string `json:"mount_point"`
+	// Until is zero for a mount point silenced by its settings.
+	Until time.Time `json:"until,omitzero"`
+}
+
+// SilenceMount silences a monitored mount point until now+duration, replacing
+// an existing silence.
+func (m *Watchdog) SilenceMount(path string, duration time.Duration, now time.Time) (MountSilence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lastHealthy[path]; !ok {
+		return MountSilence{}, fmt.Errorf("%w: %s", ErrMountPointNotFound, path)
+	}
+	silence := MountSilence{MountPoint: path, Until: now.Add(duration)}
+	m.silences[path] = silence.Until
+	return silence, nil
+}
+
+// UnsilenceMount ends the silence of a mount point set by SilenceMount and
+// reports whether there was an active one. A silence set by the mount point
+// settings stays.
+func (m *Watchdog) UnsilenceMount(path string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lastHealthy[path]; !ok {
+		return false, fmt.Errorf("%w: %s", ErrMountPointNotFound, path)
+	}
+	until, ok := m.silences[path]
+	delete(m.silences, path)
+	return ok && now.Before(until), nil
+}
+
+// MountSilences returns the active silences sorted by mount point.
+func (m *Watchdog) MountSilences(now time.Time) []MountSilence {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	silences := []MountSilence{}
+	for _, mp := range m.mountPoints {
+		if mp.Silenced {
+			silences = append(silences, MountSilence{MountPoint: mp.Path})
+		} else if until, ok := m.silences[mp.Path]; ok && now.Before(until) {
+			silences = append(silences, MountSilence{MountPoint: mp.Path, Until: until})
+		}
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].MountPoint < silences[j].MountPoint })
+	return silences
+}
+
+// IsMountSilenced reports whether the mount point is in a maintenance window.
+func (m *Watchdog) IsMountSilenced(mountPoint string, now time.Time) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mp := range m.mountPoints {
+		if mp.Path == mountPoint {
+			return m.mountSilenced(mp, now)
+		}
+	}
+	return false
+}
+
+// mountSilenced reports whether mp is silenced by its settings or an active
+// silence. Expired silences are left until replaced or removed with the mount
+// point. The caller holds m.mu.
+func (m *Watchdog) mountSilenced(mp MountPoint, now time.Time) bool {
+	return mp.Silenced || now.Before(m.silences[mp.Path])
+}
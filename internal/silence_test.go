@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSilenceMount(t *testing.T) {
+	mounted := t.TempDir()
+	reg := prometheus.NewRegistry()
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a", mounted), WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    writeMountsFixture(t, "nfs1:/export "+mounted+" nfs4 rw 0 0\n"),
+		Registerer:    reg,
+	})
+	w.CheckAll()
+	if w.IsHealthy() {
+		t.Fatal("expected unhealthy with /mnt/a unmounted")
+	}
+
+	now := time.Now()
+	silence, err := w.SilenceMount("/mnt/a", time.Hour, now)
+	if err != nil || !silence.Until.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected silence %+v, error %v", silence, err)
+	}
+	if !w.IsHealthy() {
+		t.Error("expected healthy with the unhealthy mount point silenced")
+	}
+	if r := w.Readiness(); r.State != ReadinessReady || len(r.Silenced) != 1 || r.Silenced[0] != "/mnt/a" {
+		t.Errorf("unexpected readiness %+v", r)
+	}
+	if status := w.Status()[0]; status.MountPoint != "/mnt/a" || !status.Silenced || status.Healthy {
+		t.Errorf("expected /mnt/a silenced and still reported unhealthy, got %+v", status)
+	}
+	expected := `
+# HELP test_ns_mount_silenced 1 while the mount point is in a maintenance window and left out of the global health, 0 otherwise
+# TYPE test_ns_mount_silenced gauge
+test_ns_mount_silenced{mountpoint="/mnt/a",name="/mnt/a"} 1
+test_ns_mount_silenced{mountpoint="` + mounted + `",name="` + mounted + `"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "test_ns_mount_silenced"); err != nil {
+		t.Error(err)
+	}
+
+	if w.IsMountSilenced("/mnt/a", now.Add(2*time.Hour)) {
+		t.Error("expected the silence to expire")
+	}
+	if got := w.MountSilences(now.Add(2 * time.Hour)); len(got) != 0 {
+		t.Errorf("expected no active silence after expiry, got %+v", got)
+	}
+
+	if found, err := w.UnsilenceMount("/mnt/a", now); !found || err != nil {
+		t.Errorf("expected the silence to end, got %t, %v", found, err)
+	}
+	if w.IsHealthy() {
+		t.Error("expected unhealthy again after the silence ended")
+	}
+	if _, err := w.SilenceMount("/mnt/unknown", time.Hour, now); !errors.Is(err, ErrMountPointNotFound) {
+		t.Errorf("expected ErrMountPointNotFound, got %v", err)
+	}
+}
+
+func TestSilencedSetting(t *testing.T) {
+	mp, err := ParseMountPoint("/archive?silenced")
+	if err != nil || !mp.Silenced {
+		t.Fatalf("expected a silenced mount point, got %+v, error %v", mp, err)
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval: time.Second,
+		MountsFile:    writeMountsFixture(t, ""),
+	})
+	w.CheckAll()
+	if !w.IsHealthy() {
+		t.Error("expected the silenced mount point not to affect the global health")
+	}
+	if got := w.MountSilences(time.Now()); len(got) != 1 || !got[0].Until.IsZero() {
+		t.Errorf("expected a silence without end, got %+v", got)
+	}
+	if found, _ := w.UnsilenceMount("/archive", time.Now()); found || !w.IsMountSilenced("/archive", time.Now()) {
+		t.Error("expected a silence set by the settings to stay")
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package internal
+
+import "syscall"
+
+// stRdonly is ST_RDONLY from statvfs(3): the filesystem is read-only, either
+// mounted so or forced by the kernel after errors.
+const stRdonly = 0x1
+
+// statfs returns the read-only state and usage the kernel reports for the
+// filesystem of path.
+func statfs(path string) (fsStat, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return fsStat{}, err
+	}
+	blockSize := uint64(st.Frsize)
+	if blockSize == 0 {
+		blockSize = uint64(st.Bsize)
+	}
+	return fsStat{
+		readOnly:   st.Flags&stRdonly != 0,
+		sizeBytes:  st.Blocks * blockSize,
+		freeBytes:  st.Bfree * blockSize,
+		availBytes: st.Bavail * blockSize,
+		filesFree:  st.Ffree,
+	}, nil
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package internal
+
+import "testing"
+
+func TestStatfsOnWritableDir(t *testing.T) {
+	st, err := statfs(t.TempDir())
+	if err != nil {
+		t.Fatalf("statfs failed: %v", err)
+	}
+	if st.readOnly {
+		t.Errorf("expected temp dir to be writable")
+	}
+	if st.sizeBytes == 0 || st.availBytes > st.freeBytes || st.freeBytes > st.sizeBytes {
+		t.Errorf("implausible usage %+v", st)
+	}
+}
+
+func TestStatfsMissingPath(t *testing.T) {
+	if _, err := statfs("/this/path/should/not/exist/for_nfs_watchdog_test"); err == nil {
+		t.Errorf("expected error for missing path")
+	}
+}
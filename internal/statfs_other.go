@@ -0,0 +1,7 @@
+//go:build !linux
+
+package internal
+
+func statfs(string) (fsStat, error) {
+	return fsStat{}, errStatfsUnsupported
+}
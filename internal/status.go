@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MountStatus is the last known state of a mount point.
+type MountStatus struct {
+	MountPoint string `json:"mountpoint"`
+	Name       string `json:"name"`
+	Healthy    bool   `json:"healthy"`
+	Optional   bool   `json:"optional,omitempty"`
+	Pending    bool   `json:"pending,omitempty"`
+	Paused     bool   `json:"paused,omitempty"`
+	Silenced   bool   `json:"silenced,omitempty"`
+	SpaceLow   bool   `json:"space_low,omitempty"`
+	// OptionDrift lists the expected mount options missing on the last check.
+	OptionDrift []string `json:"option_drift,omitempty"`
+	// Held is set while the reported health is frozen by a hold of the NFS
+	// server; Error still reflects the last check.
+	Held      bool       `json:"held,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	// CheckDuration is the duration of the last check in seconds.
+	CheckDuration float64 `json:"check_duration_seconds,omitempty"`
+	// LastError is the error of the last failed check, also once the mount
+	// point passes its checks again.
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+	Checks      CheckCounts       `json:"checks"`
+	Remounts    []RemountEvent    `json:"remounts,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// CheckCounts counts the checks of a mount point since it is monitored.
+type CheckCounts struct {
+	Total  int `json:"total"`
+	Failed int `json:"failed"`
+}
+
+// AgentInfo identifies the running agent in /status.
+type AgentInfo struct {
+	Program       string    `json:"program"`
+	Version       string    `json:"version"`
+	StartTime     time.Time `json:"start_time"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+// ConfigSummary is the part of the configuration shared by all mount points,
+// as served by /status.
+type ConfigSummary struct {
+	CheckInterval       string `json:"check_interval"`
+	CheckTimeout        string `json:"check_timeout,omitempty"`
+	FailureThreshold    int    `json:"failure_threshold"`
+	SuccessThreshold    int    `json:"success_threshold"`
+	WriteTest           bool   `json:"write_test"`
+	LockTest            bool   `json:"lock_test"`
+	Remount             bool   `json:"remount"`
+	RemountAfter        int    `json:"remount_after,omitempty"`
+	MaxConcurrentChecks int    `json:"max_concurrent_checks"`
+	MountTable          string `json:"mount_table"`
+	MountPoints         int    `json:"mount_points"`
+}
+
+// Agent returns the program, version and uptime of the agent at now.
+func (m *Watchdog) Agent(now time.Time) AgentInfo {
+	return AgentInfo{
+		Program:       m.programName,
+		Version:       m.programVersion,
+		StartTime:     m.started,
+		UptimeSeconds: now.Sub(m.started).Seconds(),
+	}
+}
+
+// ConfigSummary returns the current global settings of the checks.
+func (m *Watchdog) ConfigSummary() ConfigSummary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	summary := ConfigSummary{
+		CheckInterval:       m.checkInterval.String(),
+		FailureThreshold:    m.failureThreshold,
+		SuccessThreshold:    m.successThreshold,
+		WriteTest:           m.enableWriteTest,
+		LockTest:            m.enableLockTest,
+		Remount:             m.enableRemount,
+		MaxConcurrentChecks: m.maxConcurrentChecks,
+		MountTable:          mountTableName(m.mounts),
+		MountPoints:         len(m.mountPoints),
+	}
+	if m.checkTimeout > 0 {
+		summary.CheckTimeout = m.checkTimeout.String()
+	}
+	if m.enableRemount {
+		summary.RemountAfter = m.remountAfter
+	}
+	return summary
+}
+
+// Status returns the state of all mount points sorted by path.
+func (m *Watchdog) Status() []MountStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	statuses := make([]MountStatus, 0, len(m.mountPoints))
+	for _, mp := range m.sortedMountPoints() {
+		status := MountStatus{
+			MountPoint:  mp.Path,
+			Name:        mp.Name(),
+			Healthy:     m.lastHealthy[mp.Path],
+			Optional:    mp.Optional,
+			Pending:     m.pending[mp.Path],
+			Paused:      m.paused[mp.Path],
+			Silenced:    m.mountSilenced(mp, now),
+			SpaceLow:    m.spaceLow[mp.Path],
+			OptionDrift: m.optionDrift[mp.Path],
+			Checks:      m.checkCounts[mp.Path],
+			Remounts:    m.remounts[mp.Path],
+			Tags:        mp.Tags,
+		}
+		if server, ok := m.servers[mp.Path]; ok {
+			status.Held = now.Before(m.holds[server])
+		}
+		if result, ok := m.lastChecks[mp.Path]; ok {
+			at := result.at
+			status.LastCheck = &at
+			status.Error = result.err
+			status.CheckDuration = result.duration.Seconds()
+		}
+		if failure, ok := m.lastFailures[mp.Path]; ok {
+			at := failure.at
+			status.LastErrorAt = &at
+			status.LastError = failure.err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// StatusHandler serves a snapshot of the agent and all mount points, as JSON
+// for support tickets and dashboards, as an aligned text table of the mount
+// points for terminals (?format=text or Accept: text/plain) or as an HTML page
+// for browsers (?format=html or Accept: text/html).
+type StatusHandler struct {
+	watchdog *Watchdog
+	now      func() time.Time
+}
+
+func NewStatusHandler(watchdog *Watchdog) *StatusHandler {
+	return &StatusHandler{watchdog: watchdog, now: time.Now}
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statuses := h.watchdog.Status()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		switch accept := r.Header.Get("Accept"); {
+		case strings.HasPrefix(accept, "text/plain"):
+			format = "text"
+		case strings.HasPrefix(accept, "text/html"):
+			format = "html"
+		}
+	}
+	switch format {
+	case "", "json":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"healthy":      h.watchdog.IsHealthy(),
+			"agent":        h.watchdog.Agent(h.now()),
+			"config":       h.watchdog.ConfigSummary(),
+			"mount_points": statuses,
+		})
+	case "text":
+		color, _ := strconv.ParseBool(r.URL.Query().Get("color"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeStatusText(w, statuses, h.now(), color)
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := writeStatusHTML(w, h.watchdog, statuses, h.now()); err != nil {
+			slog.Warn("cannot render the status page", "error", err.Error())
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q (json, text, html)", format), http.StatusBadRequest)
+	}
+}
+
+const (
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiReset = "\033[0m"
+)
+
+// statusColumns returns the STATUS and AGE of a mount point in the text and
+// HTML renderings.
+func statusColumns(s MountStatus, now time.Time) (status, age string) {
+	status, age = "FAIL", "-"
+	switch {
+	case s.Paused:
+		status = "PAUSED"
+	case s.Pending:
+		status = "PENDING"
+	case s.Healthy && s.SpaceLow:
+		status = "LOW_SPACE"
+	case s.Healthy:
+		status = "OK"
+	}
+	if s.LastCheck != nil {
+		age = now.Sub(*s.LastCheck).Truncate(time.Second).String()
+	}
+	return status, age
+}
+
+// writeStatusText renders one line per mount point with the columns NAME,
+// STATUS, AGE and ERROR, e.g. for `watch curl -s host:9090/status?format=text`.
+// Columns are padded before coloring, so the alignment holds with colors on.
+func writeStatusText(w io.Writer, statuses []MountStatus, now time.Time, color bool) {
+	rows := [][]string{{"NAME", "STATUS", "AGE", "ERROR"}}
+	for _, s := range statuses {
+		status, age := statusColumns(s, now)
+		errText := s.Error
+		if s.LastCheck == nil && errText == "" {
+			errText = "not checked yet"
+		}
+		rows = append(rows, []string{s.Name, status, age, errText})
+	}
+
+	widths := make([]int, 3)
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], len(row[i]))
+		}
+	}
+
+	for i, row := range rows {
+		var line strings.Builder
+		for col := range widths {
+			cell := fmt.Sprintf("%-*s", widths[col], row[col])
+			if color && i > 0 && col == 1 {
+				switch row[col] {
+				case "OK":
+					cell = ansiGreen + cell + ansiReset
+				case "FAIL":
+					cell = ansiRed + cell + ansiReset
+				}
+			}
+			line.WriteString(cell)
+			line.WriteString("  ")
+		}
+		line.WriteString(row[3])
+		_, _ = io.WriteString(w, strings.TrimRight(line.String(), " ")+"\n")
+	}
+}
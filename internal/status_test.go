@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStatusTestWatchdog(now time.Time) *Watchdog {
+	w := newTestWatchdog(nil, map[string]bool{"/mnt/a": true, "/var/vcap/store/job": false, "/mnt/new": false})
+	w.mountPoints = []MountPoint{{Path: "/mnt/a"}, {Path: "/var/vcap/store/job", Alias: "job"}, {Path: "/mnt/new"}}
+	w.lastChecks = map[string]checkResult{
+		"/mnt/a":              {at: now.Add(-5 * time.Second)},
+		"/var/vcap/store/job": {at: now.Add(-12 * time.Second), err: "stat(/var/vcap/store/job) failed"},
+	}
+	return w
+}
+
+func TestStatusHandlerJSON(t *testing.T) {
+	h := NewStatusHandler(newStatusTestWatchdog(time.Now()))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var body struct {
+		Healthy     bool          `json:"healthy"`
+		MountPoints []MountStatus `json:"mount_points"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+	}
+	if body.Healthy || len(body.MountPoints) != 3 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	// Sorted by path, not in configuration order.
+	if job := body.MountPoints[2]; job.Name != "job" || job.Error == "" || job.LastCheck == nil {
+		t.Errorf("unexpected status %+v", job)
+	}
+	if unchecked := body.MountPoints[1]; unchecked.MountPoint != "/mnt/new" || unchecked.LastCheck != nil {
+		t.Errorf("expected no last check for an unchecked mount point, got %+v", unchecked)
+	}
+}
+
+func TestStatusHandlerText(t *testing.T) {
+	now := time.Now()
+	h := NewStatusHandler(newStatusTestWatchdog(now))
+	h.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status?format=text", nil))
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header and 3 lines, got %q", rec.Body.String())
+	}
+	if lines[0] != "NAME      STATUS  AGE  ERROR" {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if lines[1] != "/mnt/a    OK      5s" {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+	if lines[2] != "/mnt/new  FAIL    -    not checked yet" {
+		t.Errorf("unexpected line %q", lines[2])
+	}
+	if lines[3] != "job       FAIL    12s  stat(/var/vcap/store/job) failed" {
+		t.Errorf("unexpected line %q", lines[3])
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/status?color=true", nil)
+	req.Header.Set("Accept", "text/plain")
+	h.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), ansiGreen+"OK    "+ansiReset) {
+		t.Errorf("expected colored status, got %q", rec.Body.String())
+	}
+}
+
+func TestStatusHandlerAgentAndHistory(t *testing.T) {
+	fakeMountCommands(t, errors.New("mount.nfs: access denied"))
+
+	mp := MountPoint{Path: "/this/path/should/not/exist/for_nfs_watchdog_test", RemountSource: "nfs1:/export"}
+	w := NewWatchdog("test-program", "1.2.3", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval: 30 * time.Second,
+		EnableRemount: true,
+		RemountAfter:  1,
+	})
+	w.CheckMountPoint(mp)
+	w.CheckMountPoint(mp)
+
+	h := NewStatusHandler(w)
+	h.now = func() time.Time { return w.started.Add(time.Minute) }
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var body struct {
+		Agent       AgentInfo     `json:"agent"`
+		Config      ConfigSummary `json:"config"`
+		MountPoints []MountStatus `json:"mount_points"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode body %q: %v", rec.Body.String(), err)
+	}
+	if body.Agent.Version != "1.2.3" || body.Agent.UptimeSeconds != 60 {
+		t.Errorf("unexpected agent %+v", body.Agent)
+	}
+	if body.Config.CheckInterval != "30s" || !body.Config.Remount || body.Config.RemountAfter != 1 || body.Config.MountPoints != 1 {
+		t.Errorf("unexpected config summary %+v", body.Config)
+	}
+	status := body.MountPoints[0]
+	if status.Checks != (CheckCounts{Total: 2, Failed: 2}) || status.LastError == "" || status.LastErrorAt == nil {
+		t.Errorf("unexpected check history %+v", status)
+	}
+	if len(status.Remounts) != 2 || status.Remounts[0].Result != "failed" || !strings.Contains(status.Remounts[0].Error, "access denied") {
+		t.Errorf("unexpected remount history %+v", status.Remounts)
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+// statusPageRefresh is the reload interval of the HTML status page.
+const statusPageRefresh = 10 * time.Second
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Agent.Program}} status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.8em; text-align: left; vertical-align: top; border-bottom: 1px solid #ddd; }
+.OK { color: #1a7f37; } .FAIL { color: #cf222e; } .PAUSED, .PENDING, .LOW_SPACE { color: #9a6700; }
+.history span { display: inline-block; width: 4px; height: 14px; margin-right: 1px; }
+.history .ok { background: #1a7f37; } .history .failed { background: #cf222e; }
+.error { font-family: monospace; }
+</style>
+</head>
+<body>
+<h1 class="{{if .Healthy}}OK{{else}}FAIL{{end}}">{{if .Healthy}}Healthy{{else}}Unhealthy{{end}}</h1>
+<p>{{.Agent.Program}} {{.Agent.Version}}, up {{.Uptime}}, page generated {{.Now.Format "2006-01-02 15:04:05 MST"}}</p>
+<table>
+<tr><th>Name</th><th>Mount point</th><th>Status</th><th>Last check</th><th>History</th><th>Last error</th></tr>
+{{range .Rows}}<tr>
+<td>{{.Name}}</td>
+<td>{{.MountPoint}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{.Age}}</td>
+<td class="history">{{range .Recent}}<span class="{{if .Error}}failed{{else}}ok{{end}}" title="{{.At.Format "15:04:05"}}{{if .Error}}: {{.Error}}{{end}}"></span>{{end}}</td>
+<td class="error">{{.LastError}}{{if .LastErrorAt}} <small>({{.LastErrorAt.Format "2006-01-02 15:04:05"}})</small>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// statusRow is a mount point as shown on the HTML status page.
+type statusRow struct {
+	MountStatus
+	Status string
+	Age    string
+	Recent []historyPoint
+}
+
+// historyPoint is a recent check drawn in the history column.
+type historyPoint struct {
+	At    time.Time
+	Error string
+}
+
+// writeStatusHTML renders a self-refreshing page with one row per mount point
+// and the outcome of its recent checks, for operators without Prometheus.
+func writeStatusHTML(w io.Writer, watchdog *Watchdog, statuses []MountStatus, now time.Time) error {
+	agent := watchdog.Agent(now)
+	rows := make([]statusRow, len(statuses))
+	for i, s := range statuses {
+		status, age := statusColumns(s, now)
+		row := statusRow{MountStatus: s, Status: status, Age: age}
+		for _, check := range watchdog.recentChecksOf(s.MountPoint) {
+			row.Recent = append(row.Recent, historyPoint{At: check.at, Error: check.err})
+		}
+		rows[i] = row
+	}
+	return statusPage.Execute(w, map[string]any{
+		"Healthy": watchdog.IsHealthy(),
+		"Agent":   agent,
+		"Uptime":  time.Duration(agent.UptimeSeconds * float64(time.Second)).Truncate(time.Second).String(),
+		"Now":     now,
+		"Refresh": int(statusPageRefresh.Seconds()),
+		"Rows":    rows,
+	})
+}
+
+// recentChecksOf returns the last checks of a mount point, oldest first.
+func (m *Watchdog) recentChecksOf(mountPoint string) []checkResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]checkResult(nil), m.recentChecks[mountPoint]...)
+}
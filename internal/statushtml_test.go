@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusHandlerHTML(t *testing.T) {
+	mp := MountPoint{Path: "/mnt/a", Alias: "a"}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second})
+	now := time.Now()
+	w.recordCheck(mp.Path, now.Add(-2*time.Second), time.Millisecond, errors.New("<script>stale</script>"))
+	w.recordCheck(mp.Path, now.Add(-time.Second), time.Millisecond, nil)
+	w.setHealthy(mp.Path, true)
+
+	h := NewStatusHandler(w)
+	h.now = func() time.Time { return now }
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected an HTML page for a browser, got %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`<td class="OK">OK</td>`, `<td>1s</td>`, `<span class="failed"`, `<span class="ok"`, "&lt;script&gt;stale&lt;/script&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in the page:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>") {
+		t.Error("expected errors to be escaped")
+	}
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SystemdNotifier sends sd_notify(3) state updates to the socket systemd
+// passes in $NOTIFY_SOCKET to services with Type=notify.
+type SystemdNotifier struct {
+	addr *net.UnixAddr
+	// watchdogTimeout is WatchdogSec= of the unit, 0 when the watchdog is off.
+	watchdogTimeout time.Duration
+}
+
+// NewSystemdNotifier reads $NOTIFY_SOCKET, and $WATCHDOG_USEC when
+// $WATCHDOG_PID is unset or the pid of the agent.
+func NewSystemdNotifier() (*SystemdNotifier, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil, errors.New("NOTIFY_SOCKET is not set, not running as a systemd service with Type=notify")
+	}
+	if socket[0] == '@' {
+		// Abstract namespace socket.
+		socket = "\x00" + socket[1:]
+	}
+	n := &SystemdNotifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n, nil
+	}
+	if usec := os.Getenv("WATCHDOG_USEC"); usec != "" {
+		v, err := strconv.ParseInt(usec, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+		}
+		n.watchdogTimeout = time.Duration(v) * time.Microsecond
+	}
+	return n, nil
+}
+
+// WatchdogTimeout returns WatchdogSec= of the unit, 0 when the watchdog is off.
+func (n *SystemdNotifier) WatchdogTimeout() time.Duration {
+	return n.watchdogTimeout
+}
+
+// Notify sends a state such as "READY=1" or "STOPPING=1".
+func (n *SystemdNotifier) Notify(state string) error {
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Run sends READY=1 once the first check cycle of the watchdog completed and,
+// with the systemd watchdog enabled, WATCHDOG=1 every half WatchdogSec= as
+// long as no check cycle has been running for longer than WatchdogSec=. A
+// wedged check loop, e.g. on a hung NFS mount, thus stops the pings and
+// systemd restarts the agent. Run returns when ctx is done.
+func (n *SystemdNotifier) Run(ctx context.Context, watchdog *Watchdog) {
+	var ping <-chan time.Time
+	if n.watchdogTimeout > 0 {
+		ticker := time.NewTicker(n.watchdogTimeout / 2)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	ready := watchdog.firstCycle
+	stalled := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ready:
+			ready = nil
+			n.send("READY=1")
+		case now := <-ping:
+			busy := watchdog.cycleRunningFor(now)
+			if busy > n.watchdogTimeout {
+				if !stalled {
+					slog.Error("check cycle stalled, stopping systemd watchdog pings", "running_for", busy.String())
+					stalled = true
+				}
+				continue
+			}
+			if stalled {
+				slog.Info("check cycle progressing again, resuming systemd watchdog pings")
+				stalled = false
+			}
+			n.send("WATCHDOG=1")
+		}
+	}
+}
+
+// send notifies systemd, logging a failure.
+func (n *SystemdNotifier) send(state string) {
+	if err := n.Notify(state); err != nil {
+		slog.Warn("cannot notify systemd", "state", state, "error", err.Error())
+	}
+}
+
+// cycleRunningFor returns how long the running check cycle has been running,
+// 0 when no cycle is running.
+func (m *Watchdog) cycleRunningFor(now time.Time) time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cycleStarted.IsZero() {
+		return 0
+	}
+	return now.Sub(m.cycleStarted)
+}
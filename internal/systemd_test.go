@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotifySocket points $NOTIFY_SOCKET at a socket collecting the states.
+func listenNotifySocket(t *testing.T) <-chan string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	states := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			states <- string(buf[:n])
+		}
+	}()
+	return states
+}
+
+func expectState(t *testing.T, states <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-states:
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected %q, got nothing", want)
+	}
+}
+
+func TestNewSystemdNotifier(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if _, err := NewSystemdNotifier(); err == nil {
+		t.Error("expected an error without NOTIFY_SOCKET")
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "@agent")
+	t.Setenv("WATCHDOG_USEC", "20000000")
+	n, err := NewSystemdNotifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n.addr.Name != "\x00agent" || n.WatchdogTimeout() != 20*time.Second {
+		t.Errorf("unexpected notifier %+v", n)
+	}
+
+	// The watchdog of another process.
+	t.Setenv("WATCHDOG_PID", "1")
+	if n, err = NewSystemdNotifier(); err != nil || n.WatchdogTimeout() != 0 {
+		t.Errorf("expected no watchdog for another pid, got %s, %v", n.WatchdogTimeout(), err)
+	}
+}
+
+func TestSystemdNotifierRun(t *testing.T) {
+	states := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "40000")
+	n, err := NewSystemdNotifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx, w)
+		close(done)
+	}()
+
+	// Pings before the first check cycle, READY=1 after it.
+	expectState(t, states, "WATCHDOG=1")
+	w.CheckAll()
+	for got := <-states; got != "READY=1"; got = <-states {
+		if got != "WATCHDOG=1" {
+			t.Fatalf("unexpected state %q", got)
+		}
+	}
+
+	// A check cycle running for longer than the watchdog timeout stops the pings.
+	w.mu.Lock()
+	w.cycleStarted = time.Now().Add(-time.Second)
+	w.mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	for len(states) > 0 {
+		<-states
+	}
+	select {
+	case got := <-states:
+		t.Fatalf("expected no ping from a stalled check loop, got %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	w.mu.Lock()
+	w.cycleStarted = time.Time{}
+	w.mu.Unlock()
+	expectState(t, states, "WATCHDOG=1")
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// TextfileWriter writes the metrics of the agent in the Prometheus text format
+// to a .prom file read by the node_exporter textfile collector, for hosts that
+// should not run another listener.
+type TextfileWriter struct {
+	path        string
+	gatherer    prometheus.Gatherer
+	writesTotal *prometheus.CounterVec
+	lastErr     string
+}
+
+// NewTextfileWriter writes the metric families of gatherer prefixed with the
+// namespace to path, and registers its write counter with reg. The Go runtime
+// and process metrics are left out, as node_exporter exports its own under the
+// same names.
+func NewTextfileWriter(namespace, path string, reg prometheus.Registerer, gatherer prometheus.Gatherer) *TextfileWriter {
+	return &TextfileWriter{
+		path:     path,
+		gatherer: namespaceGatherer(namespace, gatherer),
+
+		writesTotal: promauto.With(reg).NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "textfile_writes_total",
+				Help:      "Number of metric textfile writes by result (success, failed)",
+			},
+			[]string{"result"},
+		),
+	}
+}
+
+// Write replaces the file atomically: the metrics are written to a temporary
+// file in the same directory, which is then renamed, so the collector never
+// reads a partial file. A failure is logged once per distinct error.
+func (t *TextfileWriter) Write() {
+	if err := prometheus.WriteToTextfile(t.path, t.gatherer); err != nil {
+		t.writesTotal.WithLabelValues("failed").Inc()
+		if err.Error() != t.lastErr {
+			t.lastErr = err.Error()
+			slog.Warn("cannot write the metrics textfile", "path", t.path, "error", err.Error())
+		}
+		return
+	}
+	t.writesTotal.WithLabelValues("success").Inc()
+	if t.lastErr != "" {
+		t.lastErr = ""
+		slog.Info("metrics textfile written again", "path", t.path)
+	}
+}
+
+// namespaceGatherer gathers the metric families of gatherer prefixed with the
+// namespace, leaving out e.g. the Go runtime and process metrics.
+func namespaceGatherer(namespace string, gatherer prometheus.Gatherer) prometheus.Gatherer {
+	prefix := namespace + "_"
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		kept := families[:0]
+		for _, mf := range families {
+			if strings.HasPrefix(mf.GetName(), prefix) {
+				kept = append(kept, mf)
+			}
+		}
+		return kept, err
+	})
+}
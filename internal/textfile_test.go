@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTextfileWriter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector())
+	path := filepath.Join(t.TempDir(), "nfsma.prom")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{MountsFile: writeMountsFixture(t, ""), Registerer: reg})
+	writer := NewTextfileWriter("test_ns", path, reg, reg)
+	w.OnCheckCycle(writer.Write)
+
+	w.CheckAll()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read the textfile: %v", err)
+	}
+	if !strings.Contains(string(content), `test_ns_mount_healthy{export="",mountpoint="/mnt/a",name="/mnt/a",server=""} 0`) {
+		t.Errorf("expected the mount health in the textfile, got:\n%s", content)
+	}
+	if strings.Contains(string(content), "go_goroutines") {
+		t.Error("expected no Go runtime metrics in the textfile")
+	}
+	if got := testutil.ToFloat64(writer.writesTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("expected 1 successful write, got %v", got)
+	}
+
+	writer.path = filepath.Join(t.TempDir(), "missing", "nfsma.prom")
+	writer.Write()
+	if got := testutil.ToFloat64(writer.writesTotal.WithLabelValues("failed")); got != 1 {
+		t.Errorf("expected 1 failed write, got %v", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate signed by parent, self-signed when parent is nil.
+func testCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPEM, _ := testCert(t, "ca", nil, nil, x509.ExtKeyUsageAny)
+	_, _, serverPEM, serverKeyPEM := testCert(t, "server", ca, caKey, x509.ExtKeyUsageServerAuth)
+	_, _, clientPEM, clientKeyPEM := testCert(t, "client", ca, caKey, x509.ExtKeyUsageClientAuth)
+	certFile := writeFile(t, dir, "server.crt", serverPEM)
+	keyFile := writeFile(t, dir, "server.key", serverKeyPEM)
+	caFile := writeFile(t, dir, "ca.crt", caPEM)
+
+	for _, args := range [][3]string{{certFile, "", ""}, {certFile, caFile, ""}, {certFile, keyFile, keyFile}} {
+		if _, err := NewTLSConfig(args[0], args[1], args[2]); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+
+	cfg, err := NewTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+	url := "https://" + listener.Addr().String() + "/health"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, _ := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	get := func(certs []tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get([]tls.Certificate{clientCert}); err != nil {
+		t.Errorf("expected a client with a certificate to connect, got %v", err)
+	}
+	if err := get(nil); err == nil {
+		t.Error("expected a client without a certificate to be rejected")
+	}
+}
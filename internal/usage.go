@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var errStatfsUnsupported = errors.New("statfs is only supported on linux")
+
+// fsStat is the statfs view of a mounted filesystem.
+type fsStat struct {
+	readOnly   bool
+	sizeBytes  uint64
+	freeBytes  uint64 // free blocks, including those reserved for root
+	availBytes uint64 // free blocks available to unprivileged users
+	filesFree  uint64
+}
+
+// usedPercent returns the used share of the space available to unprivileged
+// users, as df reports it: used / (used + available).
+func (st fsStat) usedPercent() float64 {
+	used := st.sizeBytes - st.freeBytes
+	if used+st.availBytes == 0 {
+		return 0
+	}
+	return 100 * float64(used) / float64(used+st.availBytes)
+}
+
+// recordUsage exports the usage of a mount point and, with a space warning
+// threshold, whether its usage exceeds it.
+func (m *Watchdog) recordUsage(mp MountPoint, st fsStat) {
+	m.nfsSizeBytes.WithLabelValues(m.labels.values(mp)...).Set(float64(st.sizeBytes))
+	m.nfsFreeBytes.WithLabelValues(m.labels.values(mp)...).Set(float64(st.availBytes))
+	m.nfsFilesFree.WithLabelValues(m.labels.values(mp)...).Set(float64(st.filesFree))
+	threshold := m.SpaceWarnPercent()
+	if threshold <= 0 {
+		return
+	}
+
+	used := st.usedPercent()
+	low := used > threshold
+	m.mu.Lock()
+	if _, ok := m.lastHealthy[mp.Path]; !ok {
+		// Removed in the meantime, do not recreate its state.
+		m.mu.Unlock()
+		return
+	}
+	was := m.spaceLow[mp.Path]
+	if low {
+		m.spaceLow[mp.Path] = true
+	} else {
+		delete(m.spaceLow, mp.Path)
+	}
+	m.mu.Unlock()
+
+	if low {
+		m.nfsSpaceLow.WithLabelValues(m.labels.values(mp)...).Set(1)
+	} else {
+		m.nfsSpaceLow.WithLabelValues(m.labels.values(mp)...).Set(0)
+	}
+	switch {
+	case low && !was:
+		slog.Warn("mount point low on space, degraded", "mountpoint", mp.Path, "used_percent", used, "threshold_percent", threshold)
+	case !low && was:
+		slog.Info("mount point space usage back below the threshold", "mountpoint", mp.Path, "used_percent", used, "threshold_percent", threshold)
+	}
+}
+
+// SpaceWarnPercent returns the space warning threshold, 0 when disabled.
+func (m *Watchdog) SpaceWarnPercent() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.spaceWarnPercent
+}
+
+// SetSpaceWarnPercent changes a positive space warning threshold, applied from
+// the next check on. The low space metric is only registered when the
+// watchdog is created with a threshold, so enabling or disabling the warning
+// takes a restart and is ignored here.
+func (m *Watchdog) SetSpaceWarnPercent(percent float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.spaceWarnPercent > 0 && percent > 0 {
+		m.spaceWarnPercent = percent
+	}
+}
+
+// IsMountSpaceLow reports whether the usage of the mount point exceeded the
+// space warning threshold on its last check.
+func (m *Watchdog) IsMountSpaceLow(mountPoint string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.spaceLow[mountPoint]
+}
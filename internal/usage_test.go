@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUsedPercent(t *testing.T) {
+	tests := []struct {
+		st   fsStat
+		want float64
+	}{
+		{fsStat{sizeBytes: 100, freeBytes: 40, availBytes: 40}, 60},
+		// Blocks reserved for root count as neither used nor available, like df.
+		{fsStat{sizeBytes: 100, freeBytes: 25, availBytes: 0}, 100},
+		{fsStat{sizeBytes: 100, freeBytes: 30, availBytes: 10}, 87.5},
+		{fsStat{}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.st.usedPercent(); got != tt.want {
+			t.Errorf("usedPercent(%+v) = %v, want %v", tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMountPointRecordsUsage(t *testing.T) {
+	mounted := t.TempDir()
+	mounts := writeMountsFixture(t, "nfs1:/export "+mounted+" nfs4 rw 0 0\n")
+	st, err := statfs(mounted)
+	if err != nil {
+		t.Skipf("statfs unavailable: %v", err)
+	}
+
+	// A threshold below the current usage marks the mount point degraded.
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(mounted), WatchdogOptions{
+		MountsFile:       mounts,
+		SpaceWarnPercent: max(st.usedPercent()/2, 0.001),
+	})
+	w.CheckAll()
+
+	if got := testutil.ToFloat64(w.nfsSizeBytes.WithLabelValues(mounted, mounted)); got <= 0 {
+		t.Errorf("expected a positive mount_size_bytes, got %v", got)
+	}
+	if got := testutil.CollectAndCount(w.nfsFilesFree); got != 1 {
+		t.Errorf("expected 1 mount_files_free series, got %d", got)
+	}
+	if st.usedPercent() == 0 {
+		t.Skip("empty filesystem, cannot exceed the threshold")
+	}
+	if !w.IsMountSpaceLow(mounted) || testutil.ToFloat64(w.nfsSpaceLow.WithLabelValues(mounted, mounted)) != 1 {
+		t.Error("expected the mount point to be low on space")
+	}
+	if !w.IsHealthy() {
+		t.Error("expected low space to leave the mount point healthy")
+	}
+	if r := w.Readiness(); r.State != ReadinessDegraded || len(r.SpaceLow) != 1 {
+		t.Errorf("expected degraded readiness, got %+v", r)
+	}
+
+	// Removing the mount point removes its usage series and state.
+	if _, err := w.SetMountPoints(nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.IsMountSpaceLow(mounted) || testutil.CollectAndCount(w.nfsSizeBytes) != 0 || testutil.CollectAndCount(w.nfsSpaceLow) != 0 {
+		t.Error("expected the usage state and series to be removed")
+	}
+}
+
+func TestSetSpaceWarnPercent(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{SpaceWarnPercent: 90})
+	w.SetSpaceWarnPercent(80)
+	if got := w.SpaceWarnPercent(); got != 80 {
+		t.Errorf("expected 80, got %g", got)
+	}
+	w.SetSpaceWarnPercent(0)
+	if got := w.SpaceWarnPercent(); got != 80 {
+		t.Errorf("expected disabling to be ignored, got %g", got)
+	}
+
+	// Without a threshold at startup the low space metric is not registered.
+	w = NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{})
+	w.SetSpaceWarnPercent(80)
+	if got := w.SpaceWarnPercent(); got != 0 {
+		t.Errorf("expected enabling to be ignored, got %g", got)
+	}
+}
@@ -1,13 +1,20 @@
 package internal
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	crand "crypto/rand"
 	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,42 +23,515 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// StateChange describes a transition of a mount point between healthy and unhealthy.
+type StateChange struct {
+	MountPoint      string    `json:"mountpoint"`
+	Healthy         bool      `json:"healthy"`
+	PreviousHealthy bool      `json:"previous_healthy"`
+	Error           string    `json:"error,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// CheckRecord describes a finished check of a mount point. Result and Reason
+// are the labels of checks_total, Healthy the reported health after it.
+type CheckRecord struct {
+	MountPoint string
+	Name       string
+	Server     string
+	Export     string
+	Start      time.Time
+	Duration   time.Duration
+	Result     string
+	Reason     string
+	Error      string
+	Healthy    bool
+}
+
+// WatchdogOptions holds the check settings shared by all mount points.
+type WatchdogOptions struct {
+	CheckInterval   time.Duration
+	EnableWriteTest bool
+	// StrictWriteTestCleanup fails the write test when the probe file was
+	// written but cannot be removed, and the lock test when its lock file
+	// cannot; by default this is only counted and logged.
+	StrictWriteTestCleanup bool
+	// OrphanProbeAge removes the probe files older than this from the check
+	// directory of a mount point with the write test, left e.g. by an agent
+	// that crashed mid write test: after its first passed write test, and
+	// then every OrphanProbeInterval, 0 only once. 0 disables the cleanup.
+	OrphanProbeAge      time.Duration
+	OrphanProbeInterval time.Duration
+	// InstanceID tells the probe files of this agent apart from those of
+	// other agents on the same host, next to the hostname in their names;
+	// random when empty.
+	InstanceID string
+	// WriteVerify reads the write test probe back and compares its random
+	// nonce. WriteVerifySkew bounds the difference between the probe mtime
+	// (NFS server clock) and the local clock, 0 disables that check.
+	WriteVerify     bool
+	WriteVerifySkew time.Duration
+	// WriteFsync syncs the probe file to the server and drops it from the
+	// page cache before it is read back, so the read is not served by the
+	// client cache.
+	WriteFsync bool
+	// EnableLockTest takes and releases a POSIX lock on a test file in every
+	// check, catching a broken NFS lock service.
+	EnableLockTest bool
+	// ProbeCredential runs the write test in a helper process with this uid
+	// and gid, so root-squashed mounts are probed as an unprivileged user.
+	ProbeCredential *ProbeCredential
+	// EnableStatfsCheck inspects statfs flags to detect mounts the kernel
+	// forced read-only while /proc/mounts still lists them as rw.
+	EnableStatfsCheck bool
+	// SpaceWarnPercent marks a mount point degraded while its usage exceeds
+	// this percentage, 0 disables.
+	SpaceWarnPercent float64
+	// EventLogSize is the number of recent transitions, check errors and
+	// remount attempts kept in memory, 0 keeps none.
+	EventLogSize int
+	// DegradeOnOptionDrift marks a healthy mount point degraded while its
+	// mount options drifted from its expected options.
+	DegradeOnOptionDrift bool
+	// MountsFile is the mount table to read, /proc/mounts when empty.
+	MountsFile string
+	// MountTable replaces MountsFile as the source of the mounts, e.g. a
+	// fixed table in tests.
+	MountTable MountTable
+	// EnableNFSProc cross-references the servers of the monitored mounts with
+	// the NFS client records in /proc/fs/nfsfs/servers.
+	EnableNFSProc bool
+	// LatencyWindow is the sliding window of the slowest check duration gauge.
+	LatencyWindow time.Duration
+	// CheckDurationBuckets, WriteTestBuckets and LockTestBuckets are the
+	// buckets in seconds of the check, write test (including its read-back)
+	// and lock test duration histograms, the Prometheus default buckets when
+	// empty.
+	CheckDurationBuckets []float64
+	WriteTestBuckets     []float64
+	LockTestBuckets      []float64
+	// AvailabilityWindows are the sliding windows of the availability ratio
+	// gauge, none disables it.
+	AvailabilityWindows []time.Duration
+	// SkipInitialCheck makes Start wait for the first tick instead of
+	// checking all mount points synchronously on startup.
+	SkipInitialCheck bool
+	// MountTableErrorHold keeps the last known health of a mount point for
+	// up to this long while the mount table cannot be read, as its state is
+	// unknown rather than bad. 0 reports such checks unhealthy right away.
+	MountTableErrorHold time.Duration
+	// InitialDelay postpones the first check, so agents deployed together do
+	// not hit shared NFS servers at once. With RandomizeInitialDelay, the delay
+	// is picked uniformly between 0 and InitialDelay.
+	InitialDelay          time.Duration
+	RandomizeInitialDelay bool
+	// StrictDependencies checks the depends-on path of a mount point on every
+	// cycle; by default a dependency stays satisfied once it was met.
+	StrictDependencies bool
+	// WatchMountEvents triggers a check of all mount points as soon as the
+	// kernel reports a change of the mount table, in addition to the periodic
+	// checks. Unsupported mount tables fall back to polling only.
+	WatchMountEvents bool
+	// MountEventsMinInterval is the minimum time between two event-triggered
+	// check cycles; events arriving sooner are coalesced into one deferred cycle.
+	MountEventsMinInterval time.Duration
+	// HealthyWhenEmpty makes IsHealthy report true when no mount point is
+	// monitored, e.g. after a reload removed all of them. By default an
+	// empty watchlist is unhealthy rather than vacuously fine.
+	HealthyWhenEmpty bool
+	// EnableRemount remounts a mount point with a remount source after
+	// RemountAfter consecutive failed checks, escalating from an in-place
+	// remount to a lazy and then a forced unmount while the checks keep
+	// failing. RemountBackoff is the least time between two attempts, doubling
+	// after every attempt up to maxRemountBackoff; 0 only waits RemountAfter
+	// checks.
+	EnableRemount  bool
+	RemountAfter   int
+	RemountBackoff time.Duration
+	// MountBackend mounts and unmounts for the remounts, MountOnStartup and
+	// UnmountAll; nil runs the mount and umount commands, see NewMountBackend.
+	MountBackend MountBackend
+	// CheckTimeout bounds the filesystem operations of a check; a check that
+	// does not finish in time is unhealthy with result "timeout". 0 waits
+	// indefinitely.
+	CheckTimeout time.Duration
+	// MaxConcurrentChecks bounds the mount points checked at once within a
+	// check cycle; 0 or 1 checks them one after another.
+	MaxConcurrentChecks int
+	// MountOnStartup makes Start mount the remount source of mount points
+	// that are not mounted yet, retrying until all are mounted, before the
+	// first check.
+	MountOnStartup bool
+	// FailureThreshold and SuccessThreshold damp flapping: a mount point turns
+	// unhealthy after FailureThreshold consecutive failed checks and healthy
+	// again after SuccessThreshold consecutive passed ones. Values below 1
+	// mean 1, reporting every check result at once.
+	FailureThreshold int
+	SuccessThreshold int
+	// RetryInterval re-checks a mount point failing its checks sooner than its
+	// check interval, so a recovery is reported quickly; 0 disables it. Every
+	// further failed check multiplies the delay by RetryBackoff (below 1 means
+	// 1), up to the check interval.
+	RetryInterval time.Duration
+	RetryBackoff  float64
+	// CheckJitter lengthens or shortens every check interval by a random
+	// fraction up to CheckJitter, e.g. 0.1 for ±10%, so the checks of many
+	// agents started together do not hit the NFS servers at the same time.
+	CheckJitter float64
+	// EnableServerProbe dials the NFS server of every monitored mount over
+	// TCP after each check cycle, and with ServerProbeRPCBind also rpcbind,
+	// each bounded by ServerProbeTimeout.
+	EnableServerProbe  bool
+	ServerProbeRPCBind bool
+	ServerProbeTimeout time.Duration
+	// Registerer receives the metrics of the watchdog and of the components
+	// built on it. When nil, the metrics are kept but not registered.
+	Registerer prometheus.Registerer
+}
+
 type Watchdog struct {
-	mountPoints          []string
+	mountPoints          []MountPoint
 	checkInterval        time.Duration
 	enableWriteTest      bool
+	strictCleanup        bool
+	orphanAge            time.Duration
+	orphanInterval       time.Duration
+	orphanScans          map[string]time.Time
+	writeVerify          writeVerify
+	probeCredential      *ProbeCredential
+	probeCtx             context.Context // cancelled when the agent stops, kills the probe helpers
+	enableLockTest       bool
+	enableStatfsCheck    bool
+	spaceWarnPercent     float64
+	spaceLow             map[string]bool
+	optionDrift          map[string][]string
+	driftDegrades        bool
+	skipInitialCheck     bool
+	mountTableHold       time.Duration
+	healthyWhenEmpty     bool
+	enableRemount        bool
+	remountAfter         int
+	remountBackoff       time.Duration
+	remountLadders       map[string]remountLadder
+	mountBackend         MountBackend
+	checkTimeout         time.Duration
+	maxConcurrentChecks  int
+	mountOnStartup       bool
+	failureThreshold     int
+	successThreshold     int
+	retryInterval        time.Duration
+	retryBackoff         float64
+	checkJitter          float64
+	serverProbeRPCBind   bool
+	serverProbeTimeout   time.Duration
+	initialDelay         time.Duration
+	strictDependencies   bool
+	watchMountEvents     bool
+	eventsMinInterval    time.Duration
+	mounts               MountTable
+	nfsfsServersFile     string
+	nfsProcErr           string
+	labels               mountLabeler
+	freshness            freshnessDescs
 	mu                   sync.RWMutex
 	lastHealthy          map[string]bool
+	aliases              map[string]string
+	checked              map[string]bool
+	pending              map[string]bool
+	paused               map[string]bool
+	silences             map[string]time.Time
+	dependencyMet        map[string]bool
+	servers              map[string]string
+	exports              map[string]string
+	lookups              map[string]MountLookup
+	unreadableSince      map[string]time.Time
+	holds                map[string]time.Time
+	failures             map[string]int
+	streaks              map[string]checkStreak
+	running              map[string]chan error
+	check                func(MountPoint, *mountTable) error // checkMounted, replaceable in tests
+	lastChecks           map[string]checkResult
+	lastFailures         map[string]checkResult
+	lastSuccesses        map[string]time.Time
+	recentChecks         map[string][]checkResult
+	eventLog             *EventLog
+	registerer           prometheus.Registerer
+	checkCounts          map[string]CheckCounts
+	remounts             map[string][]RemountEvent
+	programName          string
+	programVersion       string
+	started              time.Time
+	listeners            []func(StateChange)
+	cycleListeners       []func()
+	checkListeners       []func(CheckRecord)
+	latencyWindow        time.Duration
+	latencies            map[string]*latencyWindow
+	availabilityWindows  []time.Duration
+	availability         map[string]*availabilityHistory
+	intervalChanged      chan time.Duration
+	cycleStarted         time.Time     // start of the running check cycle, zero when idle
+	firstCycle           chan struct{} // closed when the first check cycle completed
+	firstCycleOnce       sync.Once
+	draining             bool
 	buildInfo            *prometheus.GaugeVec
+	startTime            prometheus.Gauge
+	monitoredMounts      prometheus.Gauge
 	nfsMountHealthy      *prometheus.GaugeVec
 	nfsChecksTotal       *prometheus.CounterVec
+	nfsLastErrorInfo     *prometheus.GaugeVec
+	nfsFlapsTotal        *prometheus.CounterVec
+	nfsFSTypeInfo        *prometheus.GaugeVec
+	nfsSourceInfo        *prometheus.GaugeVec
 	nfsRemountsTotal     *prometheus.CounterVec
 	nfsWriteTestDuration *prometheus.HistogramVec
+	nfsWriteTestRead     *prometheus.HistogramVec
+	nfsCleanupFailures   *prometheus.CounterVec
+	nfsOrphansRemoved    *prometheus.CounterVec
+	nfsForeignProbes     *prometheus.GaugeVec
+	nfsLockTestDuration  *prometheus.HistogramVec
+	nfsMissingOptions    *prometheus.GaugeVec
+	nfsOptionsMatch      *prometheus.GaugeVec
+	nfsReadOnly          *prometheus.GaugeVec
+	nfsSizeBytes         *prometheus.GaugeVec
+	nfsFreeBytes         *prometheus.GaugeVec
+	nfsFilesFree         *prometheus.GaugeVec
+	nfsSpaceLow          *prometheus.GaugeVec
+	nfsSlowestCheck      *prometheus.GaugeVec
+	nfsCheckDuration     *prometheus.HistogramVec
+	nfsAvailability      *prometheus.GaugeVec
+	nfsPending           *prometheus.GaugeVec
+	nfsPaused            *prometheus.GaugeVec
+	nfsLastCheckTime     *prometheus.GaugeVec
+	nfsLastSuccessTime   *prometheus.GaugeVec
+	nfsTransitionTime    *prometheus.GaugeVec
+	nfsServerReachable   *prometheus.GaugeVec
+	serverTCPReachable   *prometheus.GaugeVec
+	nfsServerHealthy     *prometheus.GaugeVec
+	nfsServerHoldUntil   *prometheus.GaugeVec
+	nfsMountActual       *prometheus.GaugeVec
+	drainingGauge        prometheus.Gauge
 }
 
-func NewWatchdog(programName, programVersion, namespace string, points []string, interval time.Duration, enableWriteTest bool) *Watchdog {
+func NewWatchdog(programName, programVersion, namespace string, points []MountPoint, opts WatchdogOptions) *Watchdog {
 	// Build info metric
 
-	var writeTestMetric *prometheus.HistogramVec
+	labels := newMountLabeler(points)
+	factory := promauto.With(opts.Registerer)
 
-	if enableWriteTest {
-		writeTestMetric = promauto.NewHistogramVec(
+	var writeTestMetric, writeTestReadMetric *prometheus.HistogramVec
+	var cleanupFailuresMetric, orphansRemovedMetric *prometheus.CounterVec
+	var foreignProbesMetric *prometheus.GaugeVec
+
+	if opts.EnableWriteTest || anyWriteTest(points) {
+		writeTestMetric = factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "write_test_duration_seconds",
 				Help:      "Duration of NFS mount write test",
-				Buckets:   prometheus.DefBuckets,
+				Buckets:   bucketsOrDefault(opts.WriteTestBuckets),
+			},
+			labels.names(),
+		)
+		cleanupFailuresMetric = factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "write_test_cleanup_failures_total",
+				Help:      "Number of write test probe files that were written but could not be removed",
+			},
+			labels.names(),
+		)
+		if opts.OrphanProbeAge > 0 {
+			orphansRemovedMetric = factory.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: namespace,
+					Name:      "write_test_orphans_removed_total",
+					Help:      "Number of probe files older than the orphan age removed from the check directory, left by interrupted write tests",
+				},
+				labels.names(),
+			)
+			foreignProbesMetric = factory.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace: namespace,
+					Name:      "write_test_foreign_probe_files",
+					Help:      "Number of recent probe files of other agents or agent instances found by the last scan of the write test directory",
+				},
+				labels.names(),
+			)
+		}
+		if opts.WriteVerify {
+			writeTestReadMetric = factory.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: namespace,
+					Name:      "write_test_read_duration_seconds",
+					Help:      "Duration of reading the write test probe file back for verification",
+					Buckets:   bucketsOrDefault(opts.WriteTestBuckets),
+				},
+				labels.names(),
+			)
+		}
+	}
+	var lockTestMetric *prometheus.HistogramVec
+	if opts.EnableLockTest {
+		lockTestMetric = factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "lock_test_duration_seconds",
+				Help:      "Duration of taking and releasing a POSIX lock on the NFS mount",
+				Buckets:   bucketsOrDefault(opts.LockTestBuckets),
+			},
+			labels.names(),
+		)
+	}
+	var serverReachableMetric *prometheus.GaugeVec
+	if opts.EnableNFSProc {
+		serverReachableMetric = factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "nfs_server_reachable",
+				Help:      "1 if the kernel NFS client holds an active record for the server of monitored mounts, 0 otherwise",
+			},
+			[]string{"server"},
+		)
+	}
+	var serverTCPReachableMetric *prometheus.GaugeVec
+	if opts.EnableServerProbe {
+		serverTCPReachableMetric = factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "server_reachable",
+				Help:      "1 if the NFS server of monitored mounts accepts TCP connections on the port, 0 otherwise",
+			},
+			[]string{"server", "port"},
+		)
+	}
+	var readOnlyMetric *prometheus.GaugeVec
+	if opts.EnableStatfsCheck {
+		readOnlyMetric = factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_read_only",
+				Help:      "1 if statfs reports the NFS mount as read-only, 0 otherwise",
+			},
+			labels.names(),
+		)
+	}
+
+	var availabilityMetric *prometheus.GaugeVec
+	if len(opts.AvailabilityWindows) > 0 {
+		availabilityMetric = factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_availability_ratio",
+				Help:      "Share of passed checks of the mount point within the sliding window",
+			},
+			labels.names("window"),
+		)
+	}
+
+	var spaceLowMetric *prometheus.GaugeVec
+	if opts.SpaceWarnPercent > 0 {
+		spaceLowMetric = factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_space_low",
+				Help:      "1 if the used space of the mount exceeds the space warning threshold, 0 otherwise",
 			},
-			[]string{"mountpoint"},
+			labels.names(),
 		)
 	}
+
+	if opts.MountTable == nil {
+		if opts.MountsFile == "" {
+			opts.MountsFile = defaultMountsFile
+		}
+		opts.MountTable = MountsFile(opts.MountsFile)
+	}
+	if opts.MountBackend == nil {
+		opts.MountBackend = execMountBackend{}
+	}
+	if opts.ServerProbeTimeout <= 0 {
+		opts.ServerProbeTimeout = defaultServerProbeTimeout
+	}
+	if opts.RandomizeInitialDelay && opts.InitialDelay > 0 {
+		opts.InitialDelay = rand.N(opts.InitialDelay + 1)
+	}
+
 	m := &Watchdog{
-		mountPoints:     points,
-		checkInterval:   interval,
-		enableWriteTest: enableWriteTest,
-		lastHealthy:     make(map[string]bool, len(points)),
+		mountPoints:         points,
+		checkInterval:       opts.CheckInterval,
+		enableWriteTest:     opts.EnableWriteTest,
+		strictCleanup:       opts.StrictWriteTestCleanup,
+		orphanAge:           opts.OrphanProbeAge,
+		orphanInterval:      opts.OrphanProbeInterval,
+		orphanScans:         make(map[string]time.Time),
+		writeVerify:         writeVerify{enabled: opts.WriteVerify, skew: opts.WriteVerifySkew, fsync: opts.WriteFsync, owner: newProbeOwner(opts.InstanceID)},
+		probeCredential:     opts.ProbeCredential,
+		enableLockTest:      opts.EnableLockTest,
+		enableStatfsCheck:   opts.EnableStatfsCheck,
+		spaceWarnPercent:    opts.SpaceWarnPercent,
+		spaceLow:            make(map[string]bool),
+		optionDrift:         make(map[string][]string),
+		driftDegrades:       opts.DegradeOnOptionDrift,
+		skipInitialCheck:    opts.SkipInitialCheck,
+		mountTableHold:      opts.MountTableErrorHold,
+		healthyWhenEmpty:    opts.HealthyWhenEmpty,
+		enableRemount:       opts.EnableRemount,
+		remountAfter:        opts.RemountAfter,
+		remountBackoff:      opts.RemountBackoff,
+		remountLadders:      make(map[string]remountLadder),
+		mountBackend:        opts.MountBackend,
+		checkTimeout:        opts.CheckTimeout,
+		maxConcurrentChecks: opts.MaxConcurrentChecks,
+		mountOnStartup:      opts.MountOnStartup,
+		failureThreshold:    max(opts.FailureThreshold, 1),
+		successThreshold:    max(opts.SuccessThreshold, 1),
+		retryInterval:       opts.RetryInterval,
+		retryBackoff:        max(opts.RetryBackoff, 1),
+		checkJitter:         opts.CheckJitter,
+		serverProbeRPCBind:  opts.ServerProbeRPCBind,
+		serverProbeTimeout:  opts.ServerProbeTimeout,
+		initialDelay:        opts.InitialDelay,
+		strictDependencies:  opts.StrictDependencies,
+		watchMountEvents:    opts.WatchMountEvents,
+		eventsMinInterval:   opts.MountEventsMinInterval,
+		mounts:              opts.MountTable,
+		nfsfsServersFile:    defaultNFSFSServersFile,
+		labels:              labels,
+		freshness:           newFreshnessDescs(namespace, labels),
+		lastHealthy:         make(map[string]bool, len(points)),
+		checked:             make(map[string]bool, len(points)),
+		pending:             make(map[string]bool),
+		paused:              make(map[string]bool),
+		silences:            make(map[string]time.Time),
+		dependencyMet:       make(map[string]bool),
+		servers:             make(map[string]string),
+		exports:             make(map[string]string),
+		lookups:             make(map[string]MountLookup),
+		unreadableSince:     make(map[string]time.Time),
+		holds:               make(map[string]time.Time),
+		failures:            make(map[string]int),
+		streaks:             make(map[string]checkStreak),
+		running:             make(map[string]chan error),
+		lastChecks:          make(map[string]checkResult, len(points)),
+		lastFailures:        make(map[string]checkResult),
+		lastSuccesses:       make(map[string]time.Time, len(points)),
+		recentChecks:        make(map[string][]checkResult, len(points)),
+		eventLog:            NewEventLog(opts.EventLogSize),
+		registerer:          opts.Registerer,
+		checkCounts:         make(map[string]CheckCounts, len(points)),
+		remounts:            make(map[string][]RemountEvent),
+		programName:         programName,
+		programVersion:      programVersion,
+		started:             time.Now(),
+		aliases:             make(map[string]string),
+		probeCtx:            context.Background(),
+		latencyWindow:       opts.LatencyWindow,
+		latencies:           make(map[string]*latencyWindow, len(points)),
+		availabilityWindows: opts.AvailabilityWindows,
+		availability:        make(map[string]*availabilityHistory, len(points)),
+		intervalChanged:     make(chan time.Duration, 1),
+		firstCycle:          make(chan struct{}),
 
-		buildInfo: promauto.NewGaugeVec(
+		buildInfo: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "build_info",
@@ -59,181 +539,1597 @@ func NewWatchdog(programName, programVersion, namespace string, points []string,
 			},
 			[]string{"program", "version"},
 		),
-		nfsMountHealthy: promauto.NewGaugeVec(
+		startTime: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "start_time_seconds",
+				Help:      "Start time of " + programName + " since unix epoch in seconds",
+			},
+		),
+		monitoredMounts: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "monitored_mounts",
+				Help:      "Number of monitored mount points",
+			},
+		),
+		nfsMountHealthy: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Name:      "mount_healthy",
 				Help:      "1 if NFS mount is healthy, 0 otherwise",
 			},
-			[]string{"mountpoint"},
+			labels.names("server", "export"),
+		),
+
+		nfsMountActual: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_healthy_actual",
+				Help:      "Result of the last check, 1 if healthy, 0 otherwise, also while mount_healthy is held",
+			},
+			labels.names(),
 		),
 
-		nfsChecksTotal: promauto.NewCounterVec(
+		nfsChecksTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "checks_total",
-				Help:      "Number of NFS health checks",
+				Help:      "Number of NFS health checks by result (ok, error, timeout) and error reason",
+			},
+			labels.names("server", "export", "result", "reason"),
+		),
+		nfsFlapsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "mount_flaps_total",
+				Help:      "Number of changes between passing and failing checks, also those absorbed by the failure and success thresholds",
+			},
+			labels.names(),
+		),
+		nfsFSTypeInfo: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_fstype_info",
+				Help:      "1 with the filesystem type mounted on the mount point, no series while nothing is mounted",
+			},
+			labels.names("fstype"),
+		),
+		nfsSourceInfo: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_source_info",
+				Help:      "1 with the last known NFS server and export of the mount point",
+			},
+			labels.names("server", "export"),
+		),
+		nfsLastErrorInfo: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_last_error_info",
+				Help:      "1 with the reason of the failed last check of a mount point, no series while it passes",
 			},
-			[]string{"mountpoint", "result"},
+			labels.names("reason"),
 		),
 
-		nfsRemountsTotal: promauto.NewCounterVec(
+		nfsRemountsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "remounts_total",
-				Help:      "Number of NFS remount attempts (reserved for future self-healing)",
+				Help:      "Number of remount attempts after consecutive failed checks, by action (remount, lazy_umount, force_umount) and result (success, failed)",
 			},
-			[]string{"mountpoint", "result"},
+			labels.names("action", "result"),
 		),
 
 		nfsWriteTestDuration: writeTestMetric,
+		nfsWriteTestRead:     writeTestReadMetric,
+		nfsCleanupFailures:   cleanupFailuresMetric,
+		nfsOrphansRemoved:    orphansRemovedMetric,
+		nfsForeignProbes:     foreignProbesMetric,
+		nfsLockTestDuration:  lockTestMetric,
+		nfsReadOnly:          readOnlyMetric,
+		nfsSpaceLow:          spaceLowMetric,
+		nfsAvailability:      availabilityMetric,
+		nfsServerReachable:   serverReachableMetric,
+		serverTCPReachable:   serverTCPReachableMetric,
+
+		nfsCheckDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "check_duration_seconds",
+				Help:      "Duration of the full check of a mount point, including the write and lock tests",
+				Buckets:   bucketsOrDefault(opts.CheckDurationBuckets),
+			},
+			labels.names(),
+		),
+		nfsSlowestCheck: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "slowest_check_duration_seconds",
+				Help:      "Maximum mount check duration observed within the latency window",
+			},
+			labels.names(),
+		),
+
+		nfsPaused: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_paused",
+				Help:      "1 while checks of the mount point are paused by the admin API, 0 otherwise",
+			},
+			labels.names(),
+		),
+		nfsLastCheckTime: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_last_check_timestamp_seconds",
+				Help:      "Unix time the last check of the mount point finished, passed or failed",
+			},
+			labels.names(),
+		),
+		nfsLastSuccessTime: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_last_success_timestamp_seconds",
+				Help:      "Unix time the last passed check of the mount point finished",
+			},
+			labels.names(),
+		),
+		nfsTransitionTime: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_state_transition_timestamp_seconds",
+				Help:      "Unix time the reported health of the mount point last changed, or was first established",
+			},
+			labels.names(),
+		),
+		nfsPending: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_pending",
+				Help:      "1 while the mount point waits for its depends-on path, 0 otherwise",
+			},
+			labels.names(),
+		),
+
+		nfsServerHealthy: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "nfs_server_healthy",
+				Help:      "1 if all mount points served by the NFS server are healthy, 0 otherwise",
+			},
+			[]string{"server"},
+		),
+
+		nfsServerHoldUntil: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "nfs_server_hold_until_seconds",
+				Help:      "Unix time until which the reported health of the mount points of the NFS server is held",
+			},
+			[]string{"server"},
+		),
+
+		drainingGauge: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "draining",
+				Help:      "1 while the agent is draining before shutdown, 0 otherwise",
+			},
+		),
+
+		nfsMissingOptions: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_missing_options",
+				Help:      "Number of required mount options missing from the NFS mount",
+			},
+			labels.names(),
+		),
+		nfsOptionsMatch: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_options_match",
+				Help:      "1 if the mount options contain all expected options, 0 while they drifted",
+			},
+			labels.names(),
+		),
+		nfsSizeBytes: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_size_bytes",
+				Help:      "Size of the mounted filesystem in bytes, as reported by statfs",
+			},
+			labels.names(),
+		),
+		nfsFreeBytes: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_free_bytes",
+				Help:      "Free space of the mounted filesystem available to unprivileged users in bytes, as reported by statfs",
+			},
+			labels.names(),
+		),
+		nfsFilesFree: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mount_files_free",
+				Help:      "Free inodes of the mounted filesystem, as reported by statfs",
+			},
+			labels.names(),
+		),
 	}
 
+	m.check = m.checkMounted
+	if opts.Registerer != nil {
+		opts.Registerer.MustRegister(m)
+	}
 	m.buildInfo.WithLabelValues(programName, programVersion).Set(1)
+	m.startTime.Set(unixSeconds(m.started))
+	m.monitoredMounts.Set(float64(len(points)))
 
 	// Initialize lastHealthy default to false
 	for _, mp := range points {
-		m.lastHealthy[mp] = false
+		m.lastHealthy[mp.Path] = false
+		m.pending[mp.Path] = mp.DependsOn != ""
+		if mp.Alias != "" {
+			m.aliases[mp.Alias] = mp.Path
+		}
 	}
 
 	return m
 }
 
-func (m *Watchdog) setHealthy(mountPoint string, healthy bool) {
+// setHealthy records the result of a check and returns the previous state and
+// whether the mount point had been checked before.
+func (m *Watchdog) setHealthy(mountPoint string, healthy bool) (previous bool, known bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	previous, known = m.lastHealthy[mountPoint], m.checked[mountPoint]
 	m.lastHealthy[mountPoint] = healthy
+	m.checked[mountPoint] = true
+	return previous, known
 }
 
-func (m *Watchdog) IsHealthy() bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	for _, mp := range m.mountPoints {
-		if !m.lastHealthy[mp] {
-			return false
-		}
-	}
-	return true
-}
+// maxRecentChecks is the number of checks per mount point kept for the history
+// of the HTML status page.
+const maxRecentChecks = 30
 
-func (m *Watchdog) IsMountHealthy(mountPoint string) (bool, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	h, ok := m.lastHealthy[mountPoint]
-	return h, ok
+// checkResult is the outcome of the last check of a mount point.
+type checkResult struct {
+	at       time.Time
+	duration time.Duration
+	err      string
 }
 
-func (m *Watchdog) CheckMountPoint(mountPoint string) {
-	err := m.checkMounted(mountPoint)
+func (m *Watchdog) recordCheck(mountPoint string, at time.Time, duration time.Duration, err error) {
+	result := checkResult{at: at, duration: duration}
+	if err != nil {
+		result.err = err.Error()
+	}
+	m.mu.Lock()
+	// A check error is logged once, until the error changes or a check passes.
+	if previous, ok := m.lastChecks[mountPoint]; err != nil && (!ok || previous.err != result.err) {
+		m.eventLog.add(Event{Time: at, Type: EventCheckError, MountPoint: mountPoint, Error: result.err})
+	}
+	m.lastChecks[mountPoint] = result
+	counts := m.checkCounts[mountPoint]
+	counts.Total++
 	if err != nil {
-		m.nfsChecksTotal.WithLabelValues(mountPoint, "error").Inc()
-		m.nfsMountHealthy.WithLabelValues(mountPoint).Set(0)
-		m.setHealthy(mountPoint, false)
-		log.Printf("mountpoint %s unhealthy: %v", mountPoint, err)
+		counts.Failed++
+		m.lastFailures[mountPoint] = result
 	} else {
-		m.nfsChecksTotal.WithLabelValues(mountPoint, "ok").Inc()
-		m.nfsMountHealthy.WithLabelValues(mountPoint).Set(1)
-		m.setHealthy(mountPoint, true)
+		m.lastSuccesses[mountPoint] = at.Add(duration)
+	}
+	m.checkCounts[mountPoint] = counts
+	recent := append(m.recentChecks[mountPoint], result)
+	if len(recent) > maxRecentChecks {
+		recent = recent[len(recent)-maxRecentChecks:]
 	}
+	m.recentChecks[mountPoint] = recent
+	m.mu.Unlock()
 }
 
-func (m *Watchdog) CheckAll() {
-	for _, mp := range m.mountPoints {
-		m.CheckMountPoint(mp)
+// MountLookups returns the mount table lookups of the last check of each
+// monitored mount point, sorted by path. Mount points not checked yet
+// are left out.
+func (m *Watchdog) MountLookups() []MountLookup {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lookups := make([]MountLookup, 0, len(m.mountPoints))
+	for _, mp := range m.sortedMountPoints() {
+		if lookup, ok := m.lookups[mp.Path]; ok {
+			if m.writeTestEnabled(mp) {
+				lookup.WriteProbe = m.probePattern(mp)
+			}
+			lookups = append(lookups, lookup)
+		}
 	}
+	return lookups
 }
 
-func (m *Watchdog) checkMounted(mountPoint string) error {
-	// Check directory exists
-	info, err := os.Stat(mountPoint)
-	if err != nil {
-		return fmt.Errorf("stat(%s) failed: %w", mountPoint, err)
-	}
-	if !info.IsDir() {
-		return fmt.Errorf("%s is not a directory", mountPoint)
+// recordLookup remembers the mount table lookup of a check for /admin/mounts
+// and the NFS server and export of the mount point for the per-server rollup
+// and the server and export labels. The last known ones are kept while the
+// mount is gone, so an outage unmounting it still counts against its server.
+// When they change, the series labeled with the old ones are removed.
+func (m *Watchdog) recordLookup(mp MountPoint, table *mountTable, at time.Time) {
+	mountPoint := mp.Path
+	lookup := table.lookup(mp)
+	lookup.CheckedAt = at
+
+	m.nfsFSTypeInfo.DeletePartialMatch(prometheus.Labels{"mountpoint": mountPoint})
+	if lookup.Entry != nil {
+		m.nfsFSTypeInfo.WithLabelValues(m.labels.values(mp, lookup.Entry.FSType)...).Set(1)
 	}
 
-	// Check /proc/mounts for NFS
-	isNFS, err := m.isOnNFS(mountPoint)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups[mountPoint] = lookup
+	if lookup.Entry == nil || !lookup.Entry.isNFS() {
+		return
+	}
+	server, err := lookup.Entry.serverHost()
 	if err != nil {
-		return fmt.Errorf("checking /proc/mounts failed: %w", err)
+		return
 	}
-	if !isNFS {
-		return fmt.Errorf("%s is not an NFS mount", mountPoint)
+	export, _ := lookup.Entry.exportPath()
+	if server == m.servers[mountPoint] && export == m.exports[mountPoint] {
+		return
 	}
+	m.servers[mountPoint] = server
+	m.exports[mountPoint] = export
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{m.nfsMountHealthy, m.nfsChecksTotal, m.nfsSourceInfo} {
+		vec.DeletePartialMatch(prometheus.Labels{"mountpoint": mountPoint})
+	}
+	m.nfsSourceInfo.WithLabelValues(m.labels.values(mp, server, export)...).Set(1)
+}
 
-	// Write test
-	if m.enableWriteTest {
-		if err := m.writeTest(mountPoint); err != nil {
-			return fmt.Errorf("write test failed on %s: %w", mountPoint, err)
+// source returns the last known NFS server and export of a mount point, empty
+// while not known.
+func (m *Watchdog) source(mountPoint string) (server, export string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.servers[mountPoint], m.exports[mountPoint]
+}
+
+// ServerHealth groups the mount points by NFS server: a server is healthy
+// when all its mount points are. Absent and pending mount points, and those
+// whose server is not known yet, are left out.
+func (m *Watchdog) ServerHealth() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health := make(map[string]bool)
+	for _, mp := range m.mountPoints {
+		server, ok := m.servers[mp.Path]
+		if !ok || mp.Absent || m.pending[mp.Path] || m.paused[mp.Path] {
+			continue
 		}
+		healthy, seen := health[server]
+		health[server] = m.lastHealthy[mp.Path] && (healthy || !seen)
 	}
-	return nil
+	return health
 }
 
-func (m *Watchdog) isOnNFS(mountPoint string) (bool, error) {
-	f, err := os.Open("/proc/mounts")
-	if err != nil {
-		return false, err
+// EventLog returns the log of recent events, nil when disabled.
+func (m *Watchdog) EventLog() *EventLog {
+	return m.eventLog
+}
+
+// Registerer returns the registerer of the watchdog metrics, nil when they
+// are not registered.
+func (m *Watchdog) Registerer() prometheus.Registerer {
+	return m.registerer
+}
+
+// OnStateChange registers a listener called whenever a checked mount point
+// flips between healthy and unhealthy. Listeners run on the goroutine of the
+// check, concurrently for different mount points, and must not block.
+func (m *Watchdog) OnStateChange(fn func(StateChange)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// OnCheckCycle registers a listener called on the check loop after every
+// check cycle, once the metrics of the cycle are updated.
+func (m *Watchdog) OnCheckCycle(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycleListeners = append(m.cycleListeners, fn)
+}
+
+// OnCheck registers a listener called after every check of a mount point,
+// on the goroutine of the check, so it must not block.
+func (m *Watchdog) OnCheck(fn func(CheckRecord)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkListeners = append(m.checkListeners, fn)
+}
+
+func (m *Watchdog) notifyCheck(record CheckRecord) {
+	m.mu.RLock()
+	listeners := m.checkListeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(record)
 	}
-	defer func(f *os.File) {
-		_ = f.Close()
-	}(f)
+}
+
+func (m *Watchdog) notifyStateChange(change StateChange) {
+	m.mu.RLock()
+	listeners := m.listeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) < 3 {
+// IsHealthy reports whether all non-optional mount points are healthy.
+// Pending, paused and silenced mount points do not count. Without any mount
+// point, it reports healthyWhenEmpty.
+func (m *Watchdog) IsHealthy() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.mountPoints) == 0 {
+		return m.healthyWhenEmpty
+	}
+	now := time.Now()
+	for _, mp := range m.mountPoints {
+		if mp.Optional || m.pending[mp.Path] || m.paused[mp.Path] || m.mountSilenced(mp, now) {
 			continue
 		}
-		mp := fields[1]
-		fsType := fields[2]
-
-		// /proc/mounts uses escaped paths, but for simple BOSH paths
-		// without spaces, a direct comparison is fine.
-		if mp == mountPoint && (fsType == "nfs" || strings.HasPrefix(fsType, "nfs4")) {
-			return true, nil
+		if !m.lastHealthy[mp.Path] {
+			return false
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return false, err
-	}
-	return false, errors.New("mount-point not found in /proc/mounts")
+	return true
 }
 
-func (m *Watchdog) writeTest(mountPoint string) error {
-	timer := prometheus.NewTimer(m.nfsWriteTestDuration.WithLabelValues(mountPoint))
-	defer timer.ObserveDuration()
+// HealthyCount returns the number of healthy mount points and the number of
+// mount points considered, leaving out optional, pending, paused and silenced
+// ones like IsHealthy.
+func (m *Watchdog) HealthyCount() (healthy int, total int) {
+	healthy, _, total = m.HealthCounts()
+	return healthy, total
+}
 
-	name := fmt.Sprintf(".nfs_mounter_test_%d_%d", os.Getpid(), time.Now().UnixNano())
-	path := filepath.Join(mountPoint, name)
+// HealthCounts is HealthyCount, also returning how many of the mount points
+// considered have not been checked yet.
+func (m *Watchdog) HealthCounts() (healthy, unknown, total int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.healthCounts()
+}
 
-	if err := os.WriteFile(path, []byte("ok\n"), 0o644); err != nil {
-		return err
-	}
-	if err := os.Remove(path); err != nil {
-		return err
+// healthCounts is HealthCounts; the caller holds m.mu.
+func (m *Watchdog) healthCounts() (healthy, unknown, total int) {
+	now := time.Now()
+	for _, mp := range m.mountPoints {
+		if mp.Optional || m.pending[mp.Path] || m.paused[mp.Path] || m.mountSilenced(mp, now) {
+			continue
+		}
+		total++
+		switch {
+		case m.lastHealthy[mp.Path]:
+			healthy++
+		case !m.checked[mp.Path]:
+			unknown++
+		}
 	}
-	return nil
+	return healthy, unknown, total
 }
 
-func (m *Watchdog) Start(ctx context.Context) {
-	log.Printf("starting watchdog, interval=%s, mountpoints=%v", m.checkInterval, m.mountPoints)
-
-	// Initial check so /health reflects state quickly
-	m.CheckAll()
+// HealthState is the tri-state of IsHealthy: HealthUnknown while a mount point
+// it considers has not been checked yet and none of the checked ones failed.
+func (m *Watchdog) HealthState() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	healthy, unknown, total := m.healthCounts()
+	switch {
+	case len(m.mountPoints) == 0:
+		if m.healthyWhenEmpty {
+			return HealthOK
+		}
+		return HealthUnhealthy
+	case healthy+unknown < total:
+		return HealthUnhealthy
+	case unknown > 0:
+		return HealthUnknown
+	}
+	return HealthOK
+}
 
-	ticker := time.NewTicker(m.checkInterval)
-	defer ticker.Stop()
+// MountPoints returns a copy of the monitored mount points.
+func (m *Watchdog) MountPoints() []MountPoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]MountPoint(nil), m.mountPoints...)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("watchdog received context cancellation, stopping")
+// mountPoint returns the monitored mount point with the path, or one without
+// settings if it is not monitored.
+func (m *Watchdog) mountPoint(path string) MountPoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mp := range m.mountPoints {
+		if mp.Path == path {
+			return mp
+		}
+	}
+	return MountPoint{Path: path}
+}
+
+// sortedMountPoints returns a copy of the mount points sorted by path, so
+// listings are stable across reloads and config file order. The caller must
+// hold m.mu.
+func (m *Watchdog) sortedMountPoints() []MountPoint {
+	points := append([]MountPoint(nil), m.mountPoints...)
+	sort.Slice(points, func(i, j int) bool { return points[i].Path < points[j].Path })
+	return points
+}
+
+// MountPointsDiff lists the mount point paths changed by SetMountPoints.
+type MountPointsDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Updated []string `json:"updated"`
+}
+
+// ErrDraining is returned when mount points are added while the agent is draining.
+var ErrDraining = errors.New("agent is draining, no new mount points accepted")
+
+// SetMountPoints atomically replaces the monitored mount points. Removed mount
+// points lose their health state and metric series; updated ones keep their
+// health state but their series are recreated, as labels may have changed.
+// While draining, changes adding mount points are rejected.
+func (m *Watchdog) SetMountPoints(points []MountPoint) (MountPointsDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setMountPoints(points)
+}
+
+// ReloadMountPoints is SetMountPoints for a config reload: points replace the
+// mount points of the flags and the config file, while those added with the
+// admin API or discovered are kept, unless points has one with the same path.
+func (m *Watchdog) ReloadMountPoints(points []MountPoint) (MountPointsDiff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	merged := append([]MountPoint(nil), points...)
+	for _, mp := range m.mountPoints {
+		if mp.Origin != OriginRuntime && mp.Origin != OriginDiscovered {
+			continue
+		}
+		if !slices.ContainsFunc(points, func(p MountPoint) bool { return p.Path == mp.Path }) {
+			merged = append(merged, mp)
+		}
+	}
+	if err := ValidateMountPoints(merged); err != nil {
+		return MountPointsDiff{}, err
+	}
+	return m.setMountPoints(merged)
+}
+
+// setMountPoints is SetMountPoints for callers holding m.mu.
+func (m *Watchdog) setMountPoints(points []MountPoint) (MountPointsDiff, error) {
+	if m.draining {
+		for _, mp := range points {
+			if _, ok := m.lastHealthy[mp.Path]; !ok {
+				return MountPointsDiff{}, ErrDraining
+			}
+		}
+	}
+
+	previous := make(map[string]MountPoint, len(m.mountPoints))
+	for _, mp := range m.mountPoints {
+		previous[mp.Path] = mp
+	}
+
+	diff := MountPointsDiff{Added: []string{}, Removed: []string{}, Updated: []string{}}
+	m.aliases = make(map[string]string)
+	for _, mp := range points {
+		old, ok := previous[mp.Path]
+		delete(previous, mp.Path)
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, mp.Path)
+			m.lastHealthy[mp.Path] = false
+			m.pending[mp.Path] = mp.DependsOn != ""
+		case !reflect.DeepEqual(old, mp):
+			diff.Updated = append(diff.Updated, mp.Path)
+			m.deleteSeries(mp.Path)
+			delete(m.failures, mp.Path)
+			delete(m.remountLadders, mp.Path)
+			delete(m.orphanScans, mp.Path)
+			delete(m.streaks, mp.Path)
+			if old.DependsOn != mp.DependsOn {
+				delete(m.dependencyMet, mp.Path)
+				m.pending[mp.Path] = mp.DependsOn != ""
+			}
+		}
+		if mp.Alias != "" {
+			m.aliases[mp.Alias] = mp.Path
+		}
+	}
+	for path := range previous {
+		diff.Removed = append(diff.Removed, path)
+		delete(m.lastHealthy, path)
+		delete(m.checked, path)
+		delete(m.lastChecks, path)
+		delete(m.lastFailures, path)
+		delete(m.lastSuccesses, path)
+		delete(m.recentChecks, path)
+		delete(m.checkCounts, path)
+		delete(m.remounts, path)
+		delete(m.pending, path)
+		delete(m.paused, path)
+		delete(m.silences, path)
+		delete(m.spaceLow, path)
+		delete(m.optionDrift, path)
+		delete(m.dependencyMet, path)
+		delete(m.servers, path)
+		delete(m.exports, path)
+		delete(m.lookups, path)
+		delete(m.unreadableSince, path)
+		delete(m.failures, path)
+		delete(m.remountLadders, path)
+		delete(m.orphanScans, path)
+		delete(m.streaks, path)
+		delete(m.latencies, path)
+		delete(m.availability, path)
+		m.deleteSeries(path)
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Updated)
+
+	m.mountPoints = append([]MountPoint(nil), points...)
+	m.monitoredMounts.Set(float64(len(points)))
+	if len(points) == 0 {
+		slog.Warn("no mount points monitored anymore (monitored_mounts == 0)", "healthy", m.healthyWhenEmpty)
+	}
+	return diff, nil
+}
+
+// SetDraining marks the agent as draining before shutdown: the global health
+// reports 503 while checks keep running, and no new mount points are accepted.
+func (m *Watchdog) SetDraining() {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+	m.drainingGauge.Set(1)
+}
+
+// IsDraining reports whether SetDraining has been called.
+func (m *Watchdog) IsDraining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
+}
+
+// deleteSeries removes all per-mount metric series of a mount point.
+func (m *Watchdog) deleteSeries(mountPoint string) {
+	labels := prometheus.Labels{"mountpoint": mountPoint}
+	vecs := []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{m.nfsMountHealthy, m.nfsMountActual, m.nfsChecksTotal, m.nfsLastErrorInfo, m.nfsFlapsTotal, m.nfsFSTypeInfo, m.nfsSourceInfo, m.nfsRemountsTotal, m.nfsMissingOptions, m.nfsOptionsMatch, m.nfsSlowestCheck, m.nfsCheckDuration, m.nfsPending, m.nfsPaused, m.nfsLastCheckTime, m.nfsLastSuccessTime, m.nfsTransitionTime, m.nfsSizeBytes, m.nfsFreeBytes, m.nfsFilesFree}
+	if m.nfsWriteTestDuration != nil {
+		vecs = append(vecs, m.nfsWriteTestDuration, m.nfsCleanupFailures)
+	}
+	if m.nfsWriteTestRead != nil {
+		vecs = append(vecs, m.nfsWriteTestRead)
+	}
+	if m.nfsOrphansRemoved != nil {
+		vecs = append(vecs, m.nfsOrphansRemoved, m.nfsForeignProbes)
+	}
+	if m.nfsLockTestDuration != nil {
+		vecs = append(vecs, m.nfsLockTestDuration)
+	}
+	if m.nfsReadOnly != nil {
+		vecs = append(vecs, m.nfsReadOnly)
+	}
+	if m.nfsSpaceLow != nil {
+		vecs = append(vecs, m.nfsSpaceLow)
+	}
+	if m.nfsAvailability != nil {
+		vecs = append(vecs, m.nfsAvailability)
+	}
+	for _, vec := range vecs {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+// TagKeys returns the tag keys used as metric labels, fixed at construction.
+func (m *Watchdog) TagKeys() []string {
+	return append([]string(nil), m.labels.tagKeys...)
+}
+
+// CheckInterval returns the current interval between check cycles.
+func (m *Watchdog) CheckInterval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkInterval
+}
+
+// SetCheckInterval changes the interval between check cycles, applied by Start
+// from the next tick on.
+func (m *Watchdog) SetCheckInterval(interval time.Duration) {
+	m.mu.Lock()
+	m.checkInterval = interval
+	m.mu.Unlock()
+
+	// Keep only the latest pending change.
+	select {
+	case <-m.intervalChanged:
+	default:
+	}
+	m.intervalChanged <- interval
+}
+
+// LookupAlias returns the path of the mount point with the given alias.
+func (m *Watchdog) LookupAlias(alias string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	path, ok := m.aliases[alias]
+	return path, ok
+}
+
+func (m *Watchdog) IsMountHealthy(mountPoint string) (bool, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.lastHealthy[mountPoint]
+	return h, ok
+}
+
+// MountHealthState is the tri-state of IsMountHealthy: HealthUnknown until the
+// mount point has been checked.
+func (m *Watchdog) MountHealthState(mountPoint string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	healthy, ok := m.lastHealthy[mountPoint]
+	switch {
+	case !ok:
+		return "", false
+	case !m.checked[mountPoint]:
+		return HealthUnknown, true
+	case healthy:
+		return HealthOK, true
+	}
+	return HealthUnhealthy, true
+}
+
+// IsMountPending reports whether the mount point waits for its depends-on path.
+func (m *Watchdog) IsMountPending(mountPoint string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pending[mountPoint]
+}
+
+// CheckMountPoint checks a single mount point against a fresh read of the
+// mount table. CheckAll shares one read across all mount points instead.
+func (m *Watchdog) CheckMountPoint(mp MountPoint) {
+	m.checkMountPoint(mp, readMountTable(m.mounts))
+}
+
+func (m *Watchdog) checkMountPoint(mp MountPoint, table *mountTable) {
+	mountPoint := mp.Path
+	if m.IsMountPaused(mountPoint) || m.awaitDependency(mp) {
+		return
+	}
+	start := time.Now()
+	err := m.checkWithTimeout(mp, table)
+	if !m.isMonitored(mountPoint) {
+		// Removed while the check was running, do not recreate its series.
+		return
+	}
+	duration := time.Since(start)
+	m.observeCheckDuration(mp, start, duration)
+	m.observeAvailability(mp, start, err == nil)
+	healthy := err == nil
+	m.recordLookup(mp, table, start)
+	server, export := m.source(mountPoint)
+	result, reason := "ok", ""
+	// The reason replaces the one of an earlier failure.
+	m.nfsLastErrorInfo.DeletePartialMatch(prometheus.Labels{"mountpoint": mountPoint})
+	if err != nil {
+		result, reason = "error", errorReason(err)
+		if reason == reasonTimeout {
+			result = "timeout"
+		}
+		m.nfsLastErrorInfo.WithLabelValues(m.labels.values(mp, reason)...).Set(1)
+		m.nfsMountActual.WithLabelValues(m.labels.values(mp)...).Set(0)
+	} else {
+		m.nfsMountActual.WithLabelValues(m.labels.values(mp)...).Set(1)
+	}
+	m.nfsChecksTotal.WithLabelValues(m.labels.values(mp, server, export, result, reason)...).Inc()
+
+	// Every check is logged with the same fields; passed checks of healthy
+	// mount points only at debug level.
+	level, msg := slog.LevelDebug, "check passed"
+	lastKnown, held := m.heldHealth(mountPoint, err, start)
+	switch {
+	case held:
+		healthy = lastKnown
+		if err != nil {
+			level, msg = slog.LevelInfo, "check failed, keeping last known state"
+		}
+	default:
+		var flapped bool
+		healthy, flapped = m.dampHealth(mountPoint, err)
+		failureThreshold, successThreshold := m.HealthThresholds()
+		if flapped {
+			m.nfsFlapsTotal.WithLabelValues(m.labels.values(mp)...).Inc()
+		}
+		switch {
+		case !healthy && err != nil:
+			level, msg = slog.LevelWarn, "mount point unhealthy"
+		case !healthy:
+			level, msg = slog.LevelInfo, fmt.Sprintf("check passed, still unhealthy until %d consecutive passed checks", successThreshold)
+		case err != nil:
+			level, msg = slog.LevelInfo, fmt.Sprintf("check failed, still healthy until %d consecutive failed checks", failureThreshold)
+		}
+	}
+	// Also set while held, the series may have been replaced by a new source.
+	if healthy {
+		m.nfsMountHealthy.WithLabelValues(m.labels.values(mp, server, export)...).Set(1)
+	} else {
+		m.nfsMountHealthy.WithLabelValues(m.labels.values(mp, server, export)...).Set(0)
+	}
+	attrs := []any{"mountpoint", mountPoint, "check_result", result, "duration", duration.Seconds(), "error_class", reason, "healthy", healthy}
+	record := CheckRecord{MountPoint: mountPoint, Name: mp.Name(), Server: server, Export: export, Start: start, Duration: duration, Result: result, Reason: reason, Healthy: healthy}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+		record.Error = err.Error()
+	}
+	slog.Log(context.Background(), level, msg, attrs...)
+	m.notifyCheck(record)
+
+	m.recordCheck(mountPoint, start, duration, err)
+	finished := unixSeconds(start.Add(duration))
+	m.nfsLastCheckTime.WithLabelValues(m.labels.values(mp)...).Set(finished)
+	if err == nil {
+		m.nfsLastSuccessTime.WithLabelValues(m.labels.values(mp)...).Set(finished)
+	}
+	previous, known := m.setHealthy(mountPoint, healthy)
+	if !known || previous != healthy {
+		m.nfsTransitionTime.WithLabelValues(m.labels.values(mp)...).Set(finished)
+	}
+	if known && previous != healthy {
+		change := StateChange{
+			MountPoint:      mountPoint,
+			Healthy:         healthy,
+			PreviousHealthy: previous,
+			Timestamp:       time.Now(),
+		}
+		if err != nil {
+			change.Error = err.Error()
+		}
+		m.eventLog.add(Event{Time: change.Timestamp, Type: EventTransition, MountPoint: mountPoint, Healthy: &change.Healthy, Error: change.Error})
+		m.notifyStateChange(change)
+	}
+
+	// Self-healing, not while the reported state is frozen.
+	if held {
+		return
+	}
+	if action, due := m.recordFailure(mp, err, time.Now()); due {
+		m.remount(mp, action)
+	}
+}
+
+// unixSeconds converts t to fractional Unix seconds for timestamp gauges.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// heldHealth returns the last known health of a checked mount point when its
+// reported health is frozen: its NFS server is held for maintenance, or err
+// is a mount table read error that started less than mountTableHold ago, so
+// an infrastructure error does not flip the state.
+func (m *Watchdog) heldHealth(mountPoint string, err error, now time.Time) (healthy bool, held bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unreadableHeld := false
+	if errors.Is(err, errMountTableUnreadable) {
+		since, ok := m.unreadableSince[mountPoint]
+		if !ok {
+			since = now
+			m.unreadableSince[mountPoint] = now
+		}
+		unreadableHeld = now.Sub(since) < m.mountTableHold
+	} else {
+		delete(m.unreadableSince, mountPoint)
+	}
+
+	server, known := m.servers[mountPoint]
+	serverHeld := known && m.serverHeld(server, now)
+	if !m.checked[mountPoint] || !(unreadableHeld || serverHeld) {
+		return false, false
+	}
+	return m.lastHealthy[mountPoint], true
+}
+
+// awaitDependency reports whether mp is pending because its depends-on path
+// does not exist, or does not answer within the check timeout. A met
+// dependency is not checked again unless strictDependencies is set.
+func (m *Watchdog) awaitDependency(mp MountPoint) bool {
+	if mp.DependsOn == "" {
+		return false
+	}
+	m.mu.RLock()
+	met, seen := m.dependencyMet[mp.Path]
+	m.mu.RUnlock()
+	if met && !m.strictDependencies {
+		return false
+	}
+
+	err := m.statDependencyWithTimeout(mp)
+	pending := err != nil
+
+	m.mu.Lock()
+	if _, ok := m.lastHealthy[mp.Path]; !ok {
+		// Removed in the meantime, do not recreate its state.
+		m.mu.Unlock()
+		return true
+	}
+	m.pending[mp.Path] = pending
+	m.dependencyMet[mp.Path] = !pending
+	m.mu.Unlock()
+
+	switch {
+	case pending && (met || !seen):
+		slog.Info("mount point pending, waiting for its dependency", "mountpoint", mp.Path, "depends_on", mp.DependsOn, "error", err.Error())
+	case !pending && !met:
+		slog.Info("dependency is ready, starting checks", "mountpoint", mp.Path, "depends_on", mp.DependsOn)
+	}
+	if pending {
+		m.nfsPending.WithLabelValues(m.labels.values(mp)...).Set(1)
+	} else {
+		m.nfsPending.WithLabelValues(m.labels.values(mp)...).Set(0)
+	}
+	return pending
+}
+
+func (m *Watchdog) observeCheckDuration(mp MountPoint, at time.Time, d time.Duration) {
+	m.mu.Lock()
+	window, ok := m.latencies[mp.Path]
+	if !ok {
+		window = &latencyWindow{window: m.latencyWindow}
+		m.latencies[mp.Path] = window
+	}
+	slowest := window.add(at, d)
+	m.mu.Unlock()
+
+	m.nfsSlowestCheck.WithLabelValues(m.labels.values(mp)...).Set(slowest.Seconds())
+	m.nfsCheckDuration.WithLabelValues(m.labels.values(mp)...).Observe(d.Seconds())
+}
+
+func (m *Watchdog) isMonitored(mountPoint string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.lastHealthy[mountPoint]
+	return ok
+}
+
+// mountPointOrigin returns the origin of a monitored mount point.
+func (m *Watchdog) mountPointOrigin(mountPoint string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mp := range m.mountPoints {
+		if mp.Path == mountPoint {
+			return mp.Origin, true
+		}
+	}
+	return "", false
+}
+
+// CheckAll checks all mount points, reading the mount table once per cycle:
+// O(lines + mount points) rather than O(lines × mount points).
+func (m *Watchdog) CheckAll() {
+	m.checkMounts(m.MountPoints())
+}
+
+// checkMounts checks the given mount points against one read of the mount
+// table, then refreshes the server-level state of all mount points.
+func (m *Watchdog) checkMounts(due []MountPoint) {
+	m.mu.Lock()
+	m.cycleStarted = time.Now()
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.cycleStarted = time.Time{}
+		m.mu.Unlock()
+		m.firstCycleOnce.Do(func() { close(m.firstCycle) })
+	}()
+
+	table := readMountTable(m.mounts)
+	m.checkConcurrently(due, table)
+	points := m.MountPoints()
+	if m.nfsServerReachable != nil {
+		m.checkNFSServers(points, table)
+	}
+	if m.serverTCPReachable != nil {
+		m.probeServers(points, table)
+	}
+
+	m.nfsServerHealthy.Reset()
+	for server, healthy := range m.ServerHealth() {
+		if healthy {
+			m.nfsServerHealthy.WithLabelValues(server).Set(1)
+		} else {
+			m.nfsServerHealthy.WithLabelValues(server).Set(0)
+		}
+	}
+
+	m.mu.RLock()
+	cycleListeners := m.cycleListeners
+	m.mu.RUnlock()
+	for _, fn := range cycleListeners {
+		fn()
+	}
+}
+
+// checkConcurrently checks the mount points against table with at most
+// maxConcurrentChecks checks running at once, so a slow or hung mount point
+// does not hold back the checks of the others. It returns when all finished.
+func (m *Watchdog) checkConcurrently(points []MountPoint, table *mountTable) {
+	if m.maxConcurrentChecks <= 1 || len(points) <= 1 {
+		for _, mp := range points {
+			m.checkMountPoint(mp, table)
+		}
+		return
+	}
+
+	slots := make(chan struct{}, m.maxConcurrentChecks)
+	var wg sync.WaitGroup
+	for _, mp := range points {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			m.checkMountPoint(mp, table)
+		}()
+	}
+	wg.Wait()
+}
+
+// checkNFSServers exports for every server of the monitored mounts whether
+// the kernel NFS client still holds a record for it in /proc/fs/nfsfs/servers.
+// A server missing or unused there while /proc/mounts still lists its mounts
+// points at a server-level problem affecting all of them.
+func (m *Watchdog) checkNFSServers(points []MountPoint, table *mountTable) {
+	servers, err := readNFSFSServers(m.nfsfsServersFile)
+	if err != nil {
+		// Log once per distinct error instead of on every cycle.
+		if err.Error() != m.nfsProcErr {
+			slog.Warn("cannot read NFS client state, nfs_server_reachable not exported", "error", err.Error())
+			m.nfsProcErr = err.Error()
+		}
+		m.nfsServerReachable.Reset()
+		return
+	}
+	m.nfsProcErr = ""
+
+	inUse := make(map[netip.Addr]bool, len(servers))
+	for _, s := range servers {
+		inUse[s.Addr] = inUse[s.Addr] || s.Use > 0
+	}
+
+	m.nfsServerReachable.Reset()
+	for _, mp := range points {
+		entry, err := table.findFor(mp)
+		if err != nil || mp.Absent || !entry.isNFS() {
+			continue
+		}
+		addrs, err := entry.serverAddrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if inUse[addr] {
+				m.nfsServerReachable.WithLabelValues(addr.String()).Set(1)
+			} else {
+				m.nfsServerReachable.WithLabelValues(addr.String()).Set(0)
+			}
+		}
+	}
+}
+
+func (m *Watchdog) checkMounted(mp MountPoint, table *mountTable) error {
+	mountPoint := mp.Path
+
+	if mp.Absent {
+		return m.checkAbsent(mountPoint, table)
+	}
+
+	entry, err := m.checkPresent(mp, table)
+	if err != nil {
+		return err
+	}
+
+	// Subdirectory the app actually uses, the target of the remaining checks
+	dir := mp.CheckDir()
+	if err := checkSubpath(mp); err != nil {
+		return err
+	}
+
+	// Expected mount options, drift does not fail the check
+	m.recordOptionDrift(mp, entry)
+
+	// Required mount options
+	if len(mp.RequireOptions) > 0 {
+		missing := missingOptions(entry.Options, mp.RequireOptions)
+		m.nfsMissingOptions.WithLabelValues(m.labels.values(mp)...).Set(float64(len(missing)))
+		if len(missing) > 0 {
+			return classified(reasonMissingOption, fmt.Errorf("missing_option: %s is mounted without required option(s) %s", mountPoint, strings.Join(missing, ",")))
+		}
+	}
+
+	// NFS server pinned to known networks
+	if len(mp.AllowedServerCIDRs) > 0 {
+		addrs, err := entry.serverAddrs()
+		if err != nil {
+			return classified(reasonServerNotAllowed, fmt.Errorf("server_not_allowed: cannot determine the NFS server of %s: %w", mountPoint, err))
+		}
+		if addr, found := disallowedAddr(addrs, mp.AllowedServerCIDRs); found {
+			return classified(reasonServerNotAllowed, fmt.Errorf("server_not_allowed: %s is served from %s (%s), outside the allowed networks", mountPoint, addr, entry.Source))
+		}
+	}
+
+	// Usage and read-only state as seen by the kernel
+	st, err := statfs(dir)
+	switch {
+	case errors.Is(err, errStatfsUnsupported) && !m.enableStatfsCheck:
+		// No usage metrics outside linux.
+	case err != nil:
+		return classified(reasonStatFailed, fmt.Errorf("statfs(%s) failed: %w", dir, err))
+	default:
+		m.recordUsage(mp, st)
+	}
+	if m.enableStatfsCheck {
+		if st.readOnly {
+			m.nfsReadOnly.WithLabelValues(m.labels.values(mp)...).Set(1)
+		} else {
+			m.nfsReadOnly.WithLabelValues(m.labels.values(mp)...).Set(0)
+		}
+		if st.readOnly && len(missingOptions(entry.Options, []string{"ro"})) > 0 {
+			return classified(reasonReadOnlyForced, fmt.Errorf("read_only_forced: %s is read-only although mounted rw, the kernel may have forced it after errors", mountPoint))
+		}
+	}
+
+	// Write test
+	if m.writeTestEnabled(mp) {
+		if err := m.writeTest(mp); err != nil {
+			return fmt.Errorf("%w on %s: %w", errWriteTest, dir, err)
+		}
+	}
+
+	// Lock test
+	if m.enableLockTest {
+		if err := m.lockTest(mp); err != nil {
+			return fmt.Errorf("%w on %s: %w", errLockTest, dir, err)
+		}
+	}
+	return nil
+}
+
+// checkSubpath verifies that the check subpath of mp, if any, is a directory.
+func checkSubpath(mp MountPoint) error {
+	if mp.CheckSubpath == "" {
+		return nil
+	}
+	dir := mp.CheckDir()
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return classified(reasonSubpathMissing, fmt.Errorf("subpath_missing: %s does not exist on %s", mp.CheckSubpath, mp.Path))
+	}
+	if err != nil {
+		return classified(reasonStatFailed, fmt.Errorf("stat(%s) failed: %w", dir, err))
+	}
+	if !info.IsDir() {
+		return classified(reasonNotDirectory, fmt.Errorf("%s is not a directory", dir))
+	}
+	return nil
+}
+
+// checkAbsent verifies that nothing is mounted on the mount point.
+func (m *Watchdog) checkAbsent(mountPoint string, table *mountTable) error {
+	entry, err := table.find(mountPoint)
+	if errors.Is(err, errMountNotFound) {
+		return nil
+	}
+	if errors.Is(err, errMountTableUnreadable) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("checking %s failed: %w", table.file, err)
+	}
+	return classified(reasonPresent, fmt.Errorf("present: %s is still mounted (%s)", mountPoint, entry.describe()))
+}
+
+// checkPresent verifies that the mount point is a directory mounted as NFS, or
+// as one of its filesystem types. With EnclosingMount the nearest enclosing
+// mount is verified instead.
+func (m *Watchdog) checkPresent(mp MountPoint, table *mountTable) (MountEntry, error) {
+	mountPoint := mp.Path
+	// Check directory exists
+	info, err := os.Stat(mountPoint)
+	if err != nil {
+		return MountEntry{}, classified(reasonStatFailed, fmt.Errorf("stat(%s) failed: %w", mountPoint, err))
+	}
+	if !info.IsDir() {
+		return MountEntry{}, classified(reasonNotDirectory, fmt.Errorf("%s is not a directory", mountPoint))
+	}
+
+	// Check /proc/mounts for NFS
+	entry, err := table.findFor(mp)
+	if errors.Is(err, errMountTableUnreadable) {
+		return MountEntry{}, err
+	}
+	if err != nil {
+		return MountEntry{}, fmt.Errorf("checking %s failed: %w", table.file, err)
+	}
+	if entry.MountPoint != mountPoint {
+		mountPoint = fmt.Sprintf("%s (enclosed by %s)", mountPoint, entry.MountPoint)
+	}
+	switch {
+	case mp.acceptsFSType(entry):
+		return entry, nil
+	case len(mp.FSTypes) == 0:
+		return MountEntry{}, classified(reasonNotNFS, fmt.Errorf("%s is not an NFS mount (%s)", mountPoint, entry.describe()))
+	default:
+		return MountEntry{}, classified(reasonWrongFSType, fmt.Errorf("%s is not a %s mount (%s)", mountPoint, strings.Join(mp.FSTypes, " or "), entry.describe()))
+	}
+}
+
+// writeTestEnabled reports whether the write test runs for mp: its own
+// setting if present, the global one otherwise.
+func (m *Watchdog) writeTestEnabled(mp MountPoint) bool {
+	if mp.WriteTest != nil {
+		return *mp.WriteTest
+	}
+	return m.enableWriteTest
+}
+
+// anyWriteTest reports whether a mount point enables the write test itself.
+func anyWriteTest(points []MountPoint) bool {
+	for _, mp := range points {
+		if mp.WriteTest != nil && *mp.WriteTest {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Watchdog) writeTest(mp MountPoint) error {
+	// The write test metrics only exist if a write test was enabled at
+	// construction; a mount point enabling it by a later reload runs untimed.
+	if m.nfsWriteTestDuration != nil {
+		timer := prometheus.NewTimer(m.nfsWriteTestDuration.WithLabelValues(m.labels.values(mp)...))
+		defer timer.ObserveDuration()
+	}
+
+	read, err := m.probe(mp)
+	if read > 0 && m.nfsWriteTestRead != nil {
+		m.nfsWriteTestRead.WithLabelValues(m.labels.values(mp)...).Observe(read.Seconds())
+	}
+	if (err == nil || errors.Is(err, errProbeCleanup)) && m.orphanScanDue(mp, time.Now()) {
+		m.removeOrphanProbes(mp)
+	}
+	if errors.Is(err, errProbeCleanup) {
+		// The mount accepted the write, only the cleanup failed.
+		if m.nfsCleanupFailures != nil {
+			m.nfsCleanupFailures.WithLabelValues(m.labels.values(mp)...).Inc()
+		}
+		if !m.strictCleanup {
+			slog.Warn("write test cleanup failed", "mountpoint", mp.Path, "error", err.Error())
+			return nil
+		}
+	}
+	return err
+}
+
+// probeFilePrefix starts the name of every probe file, followed by the owner
+// of the agent writing it, the pid of the process and a nanosecond timestamp.
+const probeFilePrefix = ".nfs_mounter_test_"
+
+// newProbeOwner returns the owner part of the probe file names of this agent,
+// <hostname>_<instance>: agents on several hosts mounting the same export can
+// share a pid, agents on one host cannot share an instance. Characters other
+// than letters, digits, dots and dashes become dashes, so the name splits at
+// its underscores.
+func newProbeOwner(instance string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		slog.Warn("cannot determine the hostname for the probe file names", "error", err.Error())
+		hostname = "unknown"
+	}
+	if instance == "" {
+		instance = fmt.Sprintf("%08x", rand.Uint32())
+	}
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '.' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+				return r
+			}
+			return '-'
+		}, s)
+	}
+	return clean(hostname) + "_" + clean(instance)
+}
+
+// probeFileName returns the name of a probe file written by owner's process
+// pid at time at.
+func probeFileName(owner string, pid int, at time.Time) string {
+	if owner == "" {
+		return fmt.Sprintf("%s%d_%d", probeFilePrefix, pid, at.UnixNano())
+	}
+	return fmt.Sprintf("%s%s_%d_%d", probeFilePrefix, owner, pid, at.UnixNano())
+}
+
+// probeFileOwner returns the owner part of a probe file name, "" for the
+// names of agents predating owners.
+func probeFileOwner(name string) string {
+	rest := strings.TrimPrefix(name, probeFilePrefix)
+	for range 2 {
+		if i := strings.LastIndexByte(rest, '_'); i >= 0 {
+			rest = rest[:i]
+		} else {
+			return ""
+		}
+	}
+	return rest
+}
+
+// probePattern describes the path of the probe files written for mp, e.g.
+// /data/app/.nfs_mounter_test_vm-1_3f2a9c01_<pid>_<unixnano>.
+func (m *Watchdog) probePattern(mp MountPoint) string {
+	return filepath.Join(mp.WriteTestPath(), probeFilePrefix+m.writeVerify.owner+"_<pid>_<unixnano>")
+}
+
+// errProbeCleanup marks a probe file that was written but could not be removed.
+var errProbeCleanup = errors.New("cannot remove probe file")
+
+// removeProbe removes a probe file, replaceable in tests.
+var removeProbe = os.Remove
+
+// writeVerify configures the read-back verification of the write test, and
+// where and what it writes.
+type writeVerify struct {
+	enabled bool
+	// skew is the tolerated difference between the probe file mtime, set by
+	// the NFS server clock, and the local clock; 0 skips the freshness check.
+	skew time.Duration
+	// fsync flushes the probe file to the server and evicts it from the page
+	// cache after writing, so a write the server never stored is caught.
+	fsync bool
+	// size is the size of the payload in bytes, at least the few bytes of
+	// the nonce or marker.
+	size int
+	// subdir is the directory below the checked one the probe file goes to,
+	// created if missing.
+	subdir string
+	// owner names the agent in the probe file name, see newProbeOwner.
+	owner string
+}
+
+// probeWrite creates and removes a test file in dir, or in its subdir. With
+// verification, the file holds a random nonce that must be read back
+// unchanged: a nonce rather than a timestamp, so clock skew between NFS nodes
+// cannot fail the comparison. It returns how long the read-back took, 0
+// without verification.
+func probeWrite(dir string, verify writeVerify) (time.Duration, error) {
+	if verify.subdir != "" {
+		dir = filepath.Join(dir, verify.subdir)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, fmt.Errorf("cannot create the write test directory: %w", err)
+		}
+	}
+	path := filepath.Join(dir, probeFileName(verify.owner, os.Getpid(), time.Now()))
+
+	content := []byte("ok\n")
+	if verify.enabled {
+		content = []byte(crand.Text() + "\n")
+	}
+	if verify.size > len(content) {
+		// Repeated up to size, the nonce still makes the whole payload unique.
+		content = bytes.Repeat(content, verify.size/len(content)+1)[:verify.size]
+	}
+	if err := writeProbe(path, content, verify.fsync); err != nil {
+		return 0, err
+	}
+
+	var read time.Duration
+	var verifyErr error
+	if verify.enabled {
+		start := time.Now()
+		verifyErr = verifyProbe(path, content, verify.skew, start)
+		read = time.Since(start)
+	}
+	if err := removeProbe(path); err != nil && verifyErr == nil {
+		return read, fmt.Errorf("%w: %w", errProbeCleanup, err)
+	}
+	return read, verifyErr
+}
+
+// writeProbe writes the probe file like os.WriteFile, but fails when the file
+// exists: a unique name already taken means another agent or a client stuck
+// with a stale view of the directory. With fsync, the file
+// is synced, which on NFS commits it to stable storage on the server and
+// reports write errors the close would otherwise be the first to see, and
+// dropped from the page cache so the read-back goes to the server.
+func writeProbe(path string, content []byte, fsync bool) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return classified(reasonProbeCollision, fmt.Errorf("%s: probe file %s already exists, written by another agent or a stuck client", reasonProbeCollision, path))
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if fsync {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("fsync failed: %w", err)
+		}
+		if err := dropPageCache(f); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("cannot drop the probe file from the page cache: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// verifyProbe reads the probe file back and compares it with what was
+// written, then checks that its mtime is within skew of now.
+func verifyProbe(path string, want []byte, skew time.Duration, now time.Time) error {
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return classified(reasonWriteVerify, fmt.Errorf("write_verify: cannot read back probe file: %w", err))
+	}
+	if !bytes.Equal(got, want) {
+		return classified(reasonWriteVerify, fmt.Errorf("write_verify: %s", describeMismatch(got, want)))
+	}
+	if skew <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return classified(reasonWriteVerify, fmt.Errorf("write_verify: stat(%s) failed: %w", path, err))
+	}
+	if diff := now.Sub(info.ModTime()).Abs(); diff > skew {
+		return classified(reasonWriteVerify, fmt.Errorf("write_verify: probe file mtime %s differs from the local clock by %s, more than the tolerated %s",
+			info.ModTime().Format(time.RFC3339), diff.Truncate(time.Millisecond), skew))
+	}
+	return nil
+}
+
+// maxMismatchExcerpt bounds the bytes of each payload quoted by a read-back
+// mismatch, as the payload can be megabytes.
+const maxMismatchExcerpt = 32
+
+// describeMismatch describes how the read-back payload got differs from the
+// written want: both lengths, the first differing offset and a bounded
+// excerpt of both from there.
+func describeMismatch(got, want []byte) string {
+	offset := 0
+	for offset < len(got) && offset < len(want) && got[offset] == want[offset] {
+		offset++
+	}
+	excerpt := func(b []byte) []byte {
+		b = b[offset:]
+		return b[:min(len(b), maxMismatchExcerpt)]
+	}
+	return fmt.Sprintf("read back %d bytes, wrote %d, first difference at offset %d: read %q, wrote %q",
+		len(got), len(want), offset, excerpt(got), excerpt(want))
+}
+
+func (m *Watchdog) Start(ctx context.Context) {
+	m.mu.Lock()
+	m.probeCtx = ctx
+	m.mu.Unlock()
+	slog.Info("starting watchdog", "interval", m.CheckInterval().String(), "mountpoints", mountPointPaths(m.MountPoints()))
+	for _, mp := range m.MountPoints() {
+		if m.writeTestEnabled(mp) {
+			slog.Info("write test probe files are created and removed", "mountpoint", mp.Path, "pattern", m.probePattern(mp))
+		}
+	}
+
+	// Mount points keep reporting unhealthy until mounted and checked.
+	if m.mountOnStartup && !m.MountAll(ctx) {
+		slog.Info("watchdog received context cancellation, stopping")
+		return
+	}
+
+	// Staggered start: mount points keep reporting unhealthy until the first check.
+	if m.initialDelay > 0 {
+		slog.Info("delaying the first check", "delay", m.initialDelay.String())
+		select {
+		case <-ctx.Done():
+			slog.Info("watchdog received context cancellation, stopping")
+			return
+		case <-time.After(m.initialDelay):
+		}
+	}
+
+	// Initial check so /health reflects state quickly
+	scheduler := newCheckScheduler(m.checkJitter)
+	scheduler.reset(m.MountPoints(), m.CheckInterval(), time.Now())
+	if m.skipInitialCheck {
+		slog.Info("initial check skipped, mount points stay unhealthy until the first tick")
+	} else {
+		m.CheckAll()
+		m.scheduleRetries(scheduler, m.MountPoints(), m.CheckInterval(), time.Now())
+	}
+
+	// Each mount point is checked on its own interval, the timer fires when
+	// the next one is due.
+	timer := time.NewTimer(scheduler.wait(m.CheckInterval(), time.Now()))
+	defer timer.Stop()
+
+	var events chan struct{}
+	if m.watchMountEvents {
+		events = make(chan struct{}, 1)
+		go func() {
+			err := errors.New("only mount table files can be watched")
+			if file, ok := m.mounts.(MountsFile); ok {
+				err = watchMountTable(ctx, string(file), events)
+			}
+			if err != nil {
+				slog.Warn("cannot watch mount table events, falling back to polling", "error", err.Error())
+			}
+		}()
+	}
+
+	// deferred fires the check for events that arrived within the minimum
+	// interval of the previous event-triggered check.
+	var (
+		deferred       <-chan time.Time
+		lastEventCheck time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("watchdog received context cancellation, stopping")
 			return
-		case <-ticker.C:
+		case interval := <-m.intervalChanged:
+			slog.Info("check interval changed", "interval", interval.String())
+			scheduler.reset(m.MountPoints(), interval, time.Now())
+			m.scheduleRetries(scheduler, m.MountPoints(), interval, time.Now())
+			timer.Reset(scheduler.wait(interval, time.Now()))
+		case <-timer.C:
+			interval := m.CheckInterval()
+			if due := scheduler.due(m.MountPoints(), interval, time.Now()); len(due) > 0 {
+				m.checkMounts(due)
+				m.scheduleRetries(scheduler, due, interval, time.Now())
+			}
+			timer.Reset(scheduler.wait(interval, time.Now()))
+		case <-events:
+			if deferred != nil {
+				continue
+			}
+			if wait := m.eventsMinInterval - time.Since(lastEventCheck); wait > 0 {
+				deferred = time.After(wait)
+				continue
+			}
+			lastEventCheck = time.Now()
+			m.CheckAll()
+			m.scheduleRetries(scheduler, m.MountPoints(), m.CheckInterval(), time.Now())
+			timer.Reset(scheduler.wait(m.CheckInterval(), time.Now()))
+		case <-deferred:
+			deferred = nil
+			lastEventCheck = time.Now()
 			m.CheckAll()
+			m.scheduleRetries(scheduler, m.MountPoints(), m.CheckInterval(), time.Now())
+			timer.Reset(scheduler.wait(m.CheckInterval(), time.Now()))
 		}
 	}
 }
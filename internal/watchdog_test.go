@@ -1,30 +1,32 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
-// resetPrometheusRegistry ensures each test has a fresh registry so that
-// promauto.* metric registration does not panic with duplicate names.
-func resetPrometheusRegistry(t *testing.T) {
-	t.Helper()
-	r := prometheus.NewRegistry()
-	prometheus.DefaultRegisterer = r
-	prometheus.DefaultGatherer = r
+func testMountPoints(paths ...string) []MountPoint {
+	points := make([]MountPoint, len(paths))
+	for i, p := range paths {
+		points[i] = MountPoint{Path: p}
+	}
+	return points
 }
 
 func TestNewWatchdogInitialState(t *testing.T) {
-	resetPrometheusRegistry(t)
-
 	points := []string{"/mnt/a", "/mnt/b"}
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, time.Second, false)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: time.Second})
 
 	// lastHealthy should have an entry for each mount point, default false
 	if len(w.lastHealthy) != len(points) {
@@ -50,24 +52,71 @@ func TestNewWatchdogInitialState(t *testing.T) {
 	if w.nfsWriteTestDuration != nil {
 		t.Errorf("expected nfsWriteTestDuration to be nil when enableWriteTest=false")
 	}
+
+	if started := testutil.ToFloat64(w.startTime); time.Since(time.Unix(int64(started), 0)) > time.Minute {
+		t.Errorf("expected start time close to now, got %v", started)
+	}
 }
 
-func TestNewWatchdogWithWriteTestMetric(t *testing.T) {
-	resetPrometheusRegistry(t)
+func TestNewWatchdogRegisterer(t *testing.T) {
+	// Without a registerer, watchdogs in one process do not collide.
+	NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{})
+	NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{})
 
+	reg := prometheus.NewRegistry()
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{Registerer: reg})
+	if w.Registerer() != reg {
+		t.Error("expected the watchdog to keep its registerer")
+	}
+	if n, err := testutil.GatherAndCount(reg, "test_ns_monitored_mounts"); err != nil || n != 1 {
+		t.Errorf("expected the watchdog metrics in the registry, got %d series, error %v", n, err)
+	}
+	if err := reg.Register(NewHookRunner("test_ns", w, time.Second).hooksTotal); err == nil {
+		t.Error("expected the hook runner counter to be registered with the watchdog registerer")
+	}
+}
+
+func TestNewWatchdogWithWriteTestMetric(t *testing.T) {
 	points := []string{"/mnt/a"}
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, time.Second, true)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: time.Second, EnableWriteTest: true})
 
 	if w.nfsWriteTestDuration == nil {
 		t.Fatalf("expected nfsWriteTestDuration to be non-nil when enableWriteTest=true")
 	}
 }
 
-func TestSetHealthyAndIsHealthy(t *testing.T) {
-	resetPrometheusRegistry(t)
+func TestWriteTestEnabledPerMountPoint(t *testing.T) {
+	enabled := true
+	tmpDir := t.TempDir()
+	mp := MountPoint{Path: tmpDir, WriteTest: &enabled}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second})
+
+	if !w.writeTestEnabled(mp) || w.writeTestEnabled(MountPoint{Path: "/mnt/other"}) {
+		t.Errorf("expected the per-mount setting to override the global one")
+	}
+	if w.nfsWriteTestDuration == nil {
+		t.Fatalf("expected nfsWriteTestDuration to be created for a per-mount write test")
+	}
+	if err := w.writeTest(mp); err != nil {
+		t.Errorf("writeTest failed: %v", err)
+	}
+}
+
+func TestWriteTestWithoutMetrics(t *testing.T) {
+	// A mount point enabling the write test after construction, e.g. by a
+	// reload, must not panic on the missing histogram.
+	enabled := true
+	mp := MountPoint{Path: t.TempDir(), WriteTest: &enabled}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{CheckInterval: time.Second})
+
+	if err := w.writeTest(mp); err != nil {
+		t.Errorf("writeTest failed: %v", err)
+	}
+}
 
+func TestSetHealthyAndIsHealthy(t *testing.T) {
 	points := []string{"/mnt/a", "/mnt/b"}
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, time.Second, false)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: time.Second})
 
 	// Initially all false → IsHealthy should be false.
 	if w.IsHealthy() {
@@ -96,30 +145,26 @@ func TestSetHealthyAndIsHealthy(t *testing.T) {
 }
 
 func TestCheckMountedDirectoryDoesNotExist(t *testing.T) {
-	resetPrometheusRegistry(t)
-
 	// Use a clearly non-existent path
 	nonexistent := "/this/path/should/not/exist/for_nfs_watchdog_test"
 	points := []string{nonexistent}
 
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, time.Second, false)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: time.Second})
 
-	err := w.checkMounted(nonexistent)
+	err := w.checkMounted(MountPoint{Path: nonexistent}, readMountTable(w.mounts))
 	if err == nil {
 		t.Fatalf("expected error from checkMounted on non-existent directory, got nil")
 	}
 }
 
 func TestWriteTestCreatesAndRemovesFile(t *testing.T) {
-	resetPrometheusRegistry(t)
-
 	tmpDir := t.TempDir()
 	points := []string{tmpDir}
 
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, time.Second, true)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: time.Second, EnableWriteTest: true})
 
 	// We call writeTest directly (same package) to avoid isOnNFS dependency.
-	if err := w.writeTest(tmpDir); err != nil {
+	if err := w.writeTest(MountPoint{Path: tmpDir}); err != nil {
 		t.Fatalf("writeTest failed in temp dir: %v", err)
 	}
 
@@ -130,19 +175,17 @@ func TestWriteTestCreatesAndRemovesFile(t *testing.T) {
 	}
 
 	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), ".nfs_mounter_test_") {
+		if strings.HasPrefix(e.Name(), probeFilePrefix) {
 			t.Errorf("found leftover test file %q after writeTest", filepath.Join(tmpDir, e.Name()))
 		}
 	}
 }
 
 func TestStartStopsOnContextCancel(t *testing.T) {
-	resetPrometheusRegistry(t)
-
 	tmpDir := t.TempDir()
 	points := []string{tmpDir}
 
-	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, 10*time.Millisecond, false)
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(points...), WatchdogOptions{CheckInterval: 10 * time.Millisecond})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -165,3 +208,861 @@ func TestStartStopsOnContextCancel(t *testing.T) {
 		t.Fatalf("Start did not return after context cancel")
 	}
 }
+
+func TestCheckMountPointNotifiesStateChanges(t *testing.T) {
+	nonexistent := "/this/path/should/not/exist/for_nfs_watchdog_test"
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(nonexistent), WatchdogOptions{CheckInterval: time.Second})
+
+	var changes []StateChange
+	w.OnStateChange(func(change StateChange) {
+		changes = append(changes, change)
+	})
+
+	// The first check only establishes the state.
+	w.CheckMountPoint(MountPoint{Path: nonexistent})
+	if len(changes) != 0 {
+		t.Fatalf("expected no state change on first check, got %d", len(changes))
+	}
+
+	w.setHealthy(nonexistent, true)
+	w.CheckMountPoint(MountPoint{Path: nonexistent})
+	if len(changes) != 1 {
+		t.Fatalf("expected one state change, got %d", len(changes))
+	}
+	if changes[0].Healthy || !changes[0].PreviousHealthy || changes[0].Error == "" {
+		t.Errorf("unexpected state change %+v", changes[0])
+	}
+}
+
+func TestStartSkipsInitialCheck(t *testing.T) {
+	nonexistent := "/this/path/should/not/exist/for_nfs_watchdog_test"
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(nonexistent), WatchdogOptions{
+		CheckInterval:    time.Hour,
+		SkipInitialCheck: true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	if w.checked[nonexistent] {
+		t.Errorf("expected no check before the first tick")
+	}
+}
+
+func TestIsHealthyIgnoresOptionalMountPoints(t *testing.T) {
+	points := []MountPoint{{Path: "/mnt/critical"}, {Path: "/mnt/archive", Optional: true}}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{CheckInterval: time.Second})
+
+	w.setHealthy("/mnt/critical", true)
+	if !w.IsHealthy() {
+		t.Errorf("expected IsHealthy() to ignore the unhealthy optional mount point")
+	}
+	if h, ok := w.IsMountHealthy("/mnt/archive"); !ok || h {
+		t.Errorf("expected optional mount point to still report its own state")
+	}
+
+	w.setHealthy("/mnt/critical", false)
+	if w.IsHealthy() {
+		t.Errorf("expected IsHealthy() to be false when a critical mount point is unhealthy")
+	}
+}
+
+func TestSetMountPoints(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{{Path: "/mnt/a"}, {Path: "/mnt/b"}}, WatchdogOptions{CheckInterval: time.Second})
+	w.setHealthy("/mnt/a", true)
+	w.nfsMountHealthy.WithLabelValues("/mnt/b", "/mnt/b", "", "").Set(1)
+
+	diff, err := w.SetMountPoints([]MountPoint{{Path: "/mnt/a", Alias: "a"}, {Path: "/mnt/c"}})
+	if err != nil {
+		t.Fatalf("SetMountPoints failed: %v", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0] != "/mnt/c" {
+		t.Errorf("unexpected added %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "/mnt/b" {
+		t.Errorf("unexpected removed %v", diff.Removed)
+	}
+	if len(diff.Updated) != 1 || diff.Updated[0] != "/mnt/a" {
+		t.Errorf("unexpected updated %v", diff.Updated)
+	}
+
+	if h, ok := w.IsMountHealthy("/mnt/a"); !ok || !h {
+		t.Errorf("expected updated mount point to keep its health state")
+	}
+	if _, ok := w.IsMountHealthy("/mnt/b"); ok {
+		t.Errorf("expected removed mount point to be forgotten")
+	}
+	if _, ok := w.LookupAlias("a"); !ok {
+		t.Errorf("expected new alias to be resolvable")
+	}
+	if n := testutil.CollectAndCount(w.nfsMountHealthy); n != 0 {
+		t.Errorf("expected removed mount point series to be deleted, got %d series", n)
+	}
+}
+
+func TestReloadMountPoints(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{{Path: "/mnt/a", Origin: OriginFlag}, {Path: "/mnt/b", Origin: OriginConfig}}, WatchdogOptions{CheckInterval: time.Second})
+	for _, mp := range []MountPoint{{Path: "/mnt/runtime", Alias: "rt", Origin: OriginRuntime}, {Path: "/mnt/found", Origin: OriginDiscovered}} {
+		if _, err := w.AddMountPoint(mp); err != nil {
+			t.Fatalf("AddMountPoint failed: %v", err)
+		}
+	}
+	w.setHealthy("/mnt/runtime", true)
+	if err := w.SetMountPaused("/mnt/found", true); err != nil {
+		t.Fatal(err)
+	}
+	paths := func() []string {
+		paths := mountPointPaths(w.MountPoints())
+		slices.Sort(paths)
+		return paths
+	}
+
+	diff, err := w.ReloadMountPoints([]MountPoint{{Path: "/mnt/a", Origin: OriginFlag}, {Path: "/mnt/c", Origin: OriginConfig}})
+	if err != nil {
+		t.Fatalf("ReloadMountPoints failed: %v", err)
+	}
+	if !slices.Equal(diff.Added, []string{"/mnt/c"}) || !slices.Equal(diff.Removed, []string{"/mnt/b"}) || len(diff.Updated) != 0 {
+		t.Errorf("expected only the configured mount points to change, got %+v", diff)
+	}
+	if got := paths(); !slices.Equal(got, []string{"/mnt/a", "/mnt/c", "/mnt/found", "/mnt/runtime"}) {
+		t.Errorf("expected the runtime and discovered mount points to be kept, got %v", got)
+	}
+	if healthy, _ := w.IsMountHealthy("/mnt/runtime"); !healthy {
+		t.Error("expected the runtime mount point to keep its state")
+	}
+	if !w.IsMountPaused("/mnt/found") {
+		t.Error("expected the discovered mount point to stay paused")
+	}
+
+	// An alias taken by a kept mount point fails the reload.
+	if _, err := w.ReloadMountPoints([]MountPoint{{Path: "/mnt/d", Alias: "rt", Origin: OriginConfig}}); err == nil {
+		t.Error("expected a conflict with the alias of the runtime mount point")
+	}
+	if got := paths(); !slices.Equal(got, []string{"/mnt/a", "/mnt/c", "/mnt/found", "/mnt/runtime"}) {
+		t.Errorf("expected a failed reload to change nothing, got %v", got)
+	}
+
+	// A configured mount point takes over the path of a runtime one.
+	diff, err = w.ReloadMountPoints([]MountPoint{{Path: "/mnt/runtime", Origin: OriginConfig}})
+	if err != nil {
+		t.Fatalf("ReloadMountPoints failed: %v", err)
+	}
+	if !slices.Equal(diff.Updated, []string{"/mnt/runtime"}) || !slices.Equal(diff.Removed, []string{"/mnt/a", "/mnt/c"}) {
+		t.Errorf("expected the runtime mount point to be taken over, got %+v", diff)
+	}
+	if origin, _ := w.mountPointOrigin("/mnt/runtime"); origin != OriginConfig {
+		t.Errorf("expected the configured mount point to win, got origin %q", origin)
+	}
+}
+
+func TestSetCheckInterval(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{CheckInterval: time.Second})
+	w.SetCheckInterval(2 * time.Second)
+	w.SetCheckInterval(3 * time.Second)
+
+	if got := w.CheckInterval(); got != 3*time.Second {
+		t.Errorf("expected interval 3s, got %s", got)
+	}
+	if got := <-w.intervalChanged; got != 3*time.Second {
+		t.Errorf("expected only the latest pending change, got %s", got)
+	}
+}
+
+func TestMetricsCarryMountPointTags(t *testing.T) {
+	nonexistent := "/this/path/should/not/exist/for_nfs_watchdog_test"
+	points := []MountPoint{
+		{Path: nonexistent, Tags: map[string]string{"team": "payments"}},
+		{Path: "/mnt/untagged"},
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{CheckInterval: time.Second})
+
+	w.CheckMountPoint(points[0])
+
+	expected := `
+# HELP test_ns_mount_healthy 1 if NFS mount is healthy, 0 otherwise
+# TYPE test_ns_mount_healthy gauge
+test_ns_mount_healthy{export="",mountpoint="` + nonexistent + `",name="` + nonexistent + `",server="",team="payments"} 0
+`
+	if err := testutil.CollectAndCompare(w.nfsMountHealthy, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDrainingRejectsNewMountPoints(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a", "/mnt/b"), WatchdogOptions{CheckInterval: time.Second})
+	w.SetDraining()
+
+	if !w.IsDraining() {
+		t.Fatalf("expected watchdog to be draining")
+	}
+	if got := testutil.ToFloat64(w.drainingGauge); got != 1 {
+		t.Errorf("expected draining gauge 1, got %v", got)
+	}
+	if _, err := w.SetMountPoints(testMountPoints("/mnt/a", "/mnt/c")); !errors.Is(err, ErrDraining) {
+		t.Errorf("expected ErrDraining when adding a mount point, got %v", err)
+	}
+	if _, err := w.SetMountPoints(testMountPoints("/mnt/a")); err != nil {
+		t.Errorf("expected removing mount points to be allowed while draining, got %v", err)
+	}
+}
+
+func TestCheckMountedAbsentAssertion(t *testing.T) {
+	mountsFile := writeMountsFixture(t, "server:/old /mnt/old nfs4 rw,hard 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+
+	if err := w.checkMounted(MountPoint{Path: "/mnt/old", Absent: true}, readMountTable(w.mounts)); err == nil {
+		t.Errorf("expected a still mounted path to fail the absent assertion")
+	}
+	if err := w.checkMounted(MountPoint{Path: "/mnt/gone", Absent: true}, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected an unmounted path to satisfy the absent assertion, got %v", err)
+	}
+
+	w.mounts = MountsFile(filepath.Join(t.TempDir(), "missing"))
+	if err := w.checkMounted(MountPoint{Path: "/mnt/gone", Absent: true}, readMountTable(w.mounts)); err == nil {
+		t.Errorf("expected an unreadable mount table to fail the absent assertion")
+	}
+}
+
+func TestCheckMountPointHoldsOnUnreadableMountTable(t *testing.T) {
+	original := mountTableRetryDelay
+	mountTableRetryDelay = 0
+	t.Cleanup(func() { mountTableRetryDelay = original })
+
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "server:/export "+dir+" nfs4 rw,hard 0 0\n")
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:       time.Second,
+		MountsFile:          mountsFile,
+		MountTableErrorHold: time.Minute,
+	})
+	w.CheckMountPoint(mp)
+	if !w.IsHealthy() {
+		t.Fatalf("expected the mount point to be healthy")
+	}
+
+	if err := os.Remove(mountsFile); err != nil {
+		t.Fatal(err)
+	}
+	w.CheckMountPoint(mp)
+	if !w.IsHealthy() {
+		t.Errorf("expected the last known state to be held while the mount table is unreadable")
+	}
+	if status := w.Status()[0]; !strings.HasPrefix(status.Error, "mounttable_unreadable: ") {
+		t.Errorf("expected a mounttable_unreadable error, got %q", status.Error)
+	}
+
+	// Once the hold has expired, the mount point is reported unhealthy.
+	w.mu.Lock()
+	w.unreadableSince[dir] = time.Now().Add(-2 * time.Minute)
+	w.mu.Unlock()
+	w.CheckMountPoint(mp)
+	if w.IsHealthy() {
+		t.Errorf("expected the mount point to turn unhealthy after the hold")
+	}
+}
+
+func TestCheckMountedSubpath(t *testing.T) {
+	root := t.TempDir()
+	mountsFile := writeMountsFixture(t, "server:/export "+root+" nfs4 rw,hard 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second, EnableWriteTest: true, MountsFile: mountsFile})
+	mp := MountPoint{Path: root, CheckSubpath: "uploads"}
+
+	err := w.checkMounted(mp, readMountTable(w.mounts))
+	if err == nil || !strings.HasPrefix(err.Error(), "subpath_missing:") {
+		t.Fatalf("expected subpath_missing error, got %v", err)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "uploads"), 0o755); err != nil {
+		t.Fatalf("cannot create subpath: %v", err)
+	}
+	if err := w.checkMounted(mp, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected existing subpath to be healthy, got %v", err)
+	}
+}
+
+func TestWriteTestCleanupFailure(t *testing.T) {
+	removeProbe = func(string) error { return errors.New("permission denied") }
+	t.Cleanup(func() { removeProbe = os.Remove })
+
+	mp := MountPoint{Path: t.TempDir()}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, EnableWriteTest: true})
+
+	if err := w.writeTest(mp); err != nil {
+		t.Errorf("expected a cleanup failure not to fail the write test, got %v", err)
+	}
+	if got := testutil.ToFloat64(w.nfsCleanupFailures.WithLabelValues(mp.Path, mp.Path)); got != 1 {
+		t.Errorf("expected cleanup failure counter 1, got %v", got)
+	}
+
+	w.strictCleanup = true
+	if err := w.writeTest(mp); !errors.Is(err, errProbeCleanup) {
+		t.Errorf("expected a cleanup failure to fail the strict write test, got %v", err)
+	}
+}
+
+func TestCheckMountedAllowedServerCIDR(t *testing.T) {
+	root := t.TempDir()
+	mountsFile := writeMountsFixture(t, "[2001:db8::5]:/export "+root+" nfs4 rw,hard 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+
+	allowed, _ := ParseCIDRs([]string{"10.0.0.0/8", "2001:db8::/32"})
+	if err := w.checkMounted(MountPoint{Path: root, AllowedServerCIDRs: allowed}, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected server within the allowed networks to be healthy, got %v", err)
+	}
+
+	other, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+	err := w.checkMounted(MountPoint{Path: root, AllowedServerCIDRs: other}, readMountTable(w.mounts))
+	if err == nil || !strings.HasPrefix(err.Error(), "server_not_allowed:") {
+		t.Errorf("expected server_not_allowed error, got %v", err)
+	}
+}
+
+func TestCheckMountPointWaitsForDependency(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "vpn-up")
+	mp := MountPoint{Path: "/this/path/should/not/exist/for_nfs_watchdog_test", DependsOn: marker}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second})
+
+	w.CheckMountPoint(mp)
+	if !w.IsMountPending(mp.Path) || !w.IsHealthy() {
+		t.Errorf("expected a pending mount point not to affect global health")
+	}
+	if got := testutil.ToFloat64(w.nfsPending.WithLabelValues(mp.Path, mp.Path)); got != 1 {
+		t.Errorf("expected mount_pending 1, got %v", got)
+	}
+	if r := w.Readiness(); r.State != ReadinessReady || len(r.Pending) != 1 {
+		t.Errorf("unexpected readiness %+v", r)
+	}
+
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatalf("cannot create dependency: %v", err)
+	}
+	w.CheckMountPoint(mp)
+	if w.IsMountPending(mp.Path) || w.IsHealthy() {
+		t.Errorf("expected real checks to start once the dependency exists")
+	}
+
+	// A met dependency stays met unless dependencies are re-checked.
+	if err := os.Remove(marker); err != nil {
+		t.Fatalf("cannot remove dependency: %v", err)
+	}
+	w.CheckMountPoint(mp)
+	if w.IsMountPending(mp.Path) {
+		t.Errorf("expected the dependency to stay satisfied")
+	}
+	w.strictDependencies = true
+	w.CheckMountPoint(mp)
+	if !w.IsMountPending(mp.Path) {
+		t.Errorf("expected a strict re-check to make the mount point pending again")
+	}
+}
+
+func TestCheckMountedBindMounts(t *testing.T) {
+	nfsBind, localBind := t.TempDir(), t.TempDir()
+	mountsFile := writeMountsFixture(t, ""+
+		"37 25 0:52 /app/uploads "+nfsBind+" rw,relatime shared:1 - nfs4 10.0.0.1:/exports rw,hard\n"+
+		"38 25 8:1 /srv/data "+localBind+" rw,relatime - ext4 /dev/sda1 rw\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+
+	if err := w.checkMounted(MountPoint{Path: nfsBind, RequireOptions: []string{"hard"}}, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected a bind of an NFS subtree to be healthy, got %v", err)
+	}
+	err := w.checkMounted(MountPoint{Path: localBind}, readMountTable(w.mounts))
+	if err == nil || !strings.Contains(err.Error(), "bind mount of /srv/data on ext4") {
+		t.Errorf("expected a bind of a local directory not to be NFS, got %v", err)
+	}
+}
+
+func TestStartDelaysInitialCheck(t *testing.T) {
+	nonexistent := "/this/path/should/not/exist/for_nfs_watchdog_test"
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(nonexistent), WatchdogOptions{
+		CheckInterval: time.Hour,
+		InitialDelay:  100 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if w.isChecked(nonexistent) {
+		t.Errorf("expected no check during the initial delay")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !w.isChecked(nonexistent) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the first check after the initial delay")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRandomizedInitialDelay(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		w := NewWatchdog("test-program", "1.0.0", "test_ns", nil, WatchdogOptions{
+			CheckInterval:         time.Second,
+			InitialDelay:          time.Minute,
+			RandomizeInitialDelay: true,
+		})
+		if w.initialDelay < 0 || w.initialDelay > time.Minute {
+			t.Fatalf("expected a delay between 0 and 1m, got %s", w.initialDelay)
+		}
+	}
+}
+
+func (m *Watchdog) isChecked(mountPoint string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checked[mountPoint]
+}
+
+func TestCheckNFSServers(t *testing.T) {
+	mountsFile := writeMountsFixture(t, ""+
+		"10.0.0.1:/a /mnt/a nfs4 rw,addr=10.0.0.1 0 0\n"+
+		"10.0.0.2:/b /mnt/b nfs4 rw,addr=10.0.0.2 0 0\n")
+	points := testMountPoints("/mnt/a", "/mnt/b")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile, EnableNFSProc: true})
+	w.nfsfsServersFile = writeNFSFSFixture(t, "NV SERVER   PORT USE HOSTNAME\nv4 0a000001  801   1 10.0.0.1\n")
+
+	w.checkNFSServers(points, readMountTable(MountsFile(mountsFile)))
+	if got := testutil.ToFloat64(w.nfsServerReachable.WithLabelValues("10.0.0.1")); got != 1 {
+		t.Errorf("expected server in use to be reachable, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsServerReachable.WithLabelValues("10.0.0.2")); got != 0 {
+		t.Errorf("expected server without client record to be unreachable, got %v", got)
+	}
+
+	w.nfsfsServersFile = filepath.Join(t.TempDir(), "missing")
+	w.checkNFSServers(points, readMountTable(MountsFile(mountsFile)))
+	if got := testutil.CollectAndCount(w.nfsServerReachable); got != 0 {
+		t.Errorf("expected no series without NFS client state, got %d", got)
+	}
+}
+
+func TestCheckAllServerHealthRollup(t *testing.T) {
+	a1, a2 := t.TempDir(), t.TempDir()
+	b1 := filepath.Join(t.TempDir(), "missing")
+	mountsFile := writeMountsFixture(t, ""+
+		"10.0.0.1:/a1 "+a1+" nfs4 rw 0 0\n"+
+		"10.0.0.1:/a2 "+a2+" nfs4 rw 0 0\n"+
+		"nfs.example.com:/b1 "+b1+" nfs4 rw 0 0\n")
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(a1, a2, b1), WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+
+	w.CheckAll()
+	if got := testutil.ToFloat64(w.nfsServerHealthy.WithLabelValues("10.0.0.1")); got != 1 {
+		t.Errorf("expected server with all mounts healthy to be healthy, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsServerHealthy.WithLabelValues("nfs.example.com")); got != 0 {
+		t.Errorf("expected server with an unhealthy mount to be unhealthy, got %v", got)
+	}
+
+	// The mount vanishes from the table, its last known server still counts.
+	if err := os.WriteFile(mountsFile, []byte("10.0.0.1:/a1 "+a1+" nfs4 rw 0 0\n"), 0o644); err != nil {
+		t.Fatalf("cannot rewrite mounts fixture: %v", err)
+	}
+	w.CheckAll()
+	if health := w.ServerHealth(); health["10.0.0.1"] {
+		t.Errorf("expected server to be unhealthy after losing a mount, got %v", health)
+	}
+}
+
+func TestProbeFileOwner(t *testing.T) {
+	owner := newProbeOwner("blue_2")
+	if host, instance, _ := strings.Cut(owner, "_"); host == "" || instance != "blue-2" {
+		t.Errorf("expected <hostname>_<instance> with underscores replaced, got %q", owner)
+	}
+	if random := newProbeOwner(""); random == newProbeOwner("") {
+		t.Errorf("expected a random instance per agent, got %q twice", random)
+	}
+
+	name := probeFileName(owner, 123, time.Unix(0, 42))
+	if got := probeFileOwner(name); got != owner {
+		t.Errorf("expected owner %q of %q, got %q", owner, name, got)
+	}
+	if got := probeFileOwner(probeFileName("", 123, time.Unix(0, 42))); got != "" {
+		t.Errorf("expected no owner in a name without one, got %q", got)
+	}
+}
+
+func TestProbeWriteCollision(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "probe")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("cannot write probe: %v", err)
+	}
+	err := writeProbe(path, []byte("ok\n"), false)
+	if got := errorReason(err); got != reasonProbeCollision {
+		t.Errorf("expected a pre-existing probe file to be a %s, got %q: %v", reasonProbeCollision, got, err)
+	}
+	if got := errorReason(newHelperError(err.Error())); got != reasonProbeCollision {
+		t.Errorf("expected the helper to report the %s, got %q", reasonProbeCollision, got)
+	}
+}
+
+func TestProbeWriteSubdirAndSize(t *testing.T) {
+	dir := t.TempDir()
+	var size int64
+	original := removeProbe
+	removeProbe = func(path string) error {
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		return original(path)
+	}
+	t.Cleanup(func() { removeProbe = original })
+
+	if _, err := probeWrite(dir, writeVerify{enabled: true, size: 10000, subdir: ".nfsma/probes"}); err != nil {
+		t.Fatalf("probeWrite failed: %v", err)
+	}
+	if size != 10000 {
+		t.Errorf("expected a payload of 10000 bytes, got %d", size)
+	}
+	if entries, err := os.ReadDir(filepath.Join(dir, ".nfsma", "probes")); err != nil || len(entries) != 0 {
+		t.Errorf("expected an empty write test directory, got %d entries, %v", len(entries), err)
+	}
+}
+
+func TestProbeWriteVerify(t *testing.T) {
+	dir := t.TempDir()
+	read, err := probeWrite(dir, writeVerify{enabled: true, skew: time.Minute})
+	if err != nil {
+		t.Fatalf("probeWrite with verification failed: %v", err)
+	}
+	if read <= 0 {
+		t.Errorf("expected a read-back duration, got %v", read)
+	}
+	if read, err := probeWrite(dir, writeVerify{fsync: true}); err != nil || read != 0 {
+		t.Errorf("expected a synced probe without read-back to pass untimed, got %v, %v", read, err)
+	}
+	if _, err := probeWrite(dir, writeVerify{enabled: true, fsync: true}); err != nil {
+		t.Errorf("expected a synced probe to read back, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the probe file to be removed, found %d entries", len(entries))
+	}
+
+	path := filepath.Join(dir, "probe")
+	if err := os.WriteFile(path, []byte("nonce\n"), 0o644); err != nil {
+		t.Fatalf("cannot write probe: %v", err)
+	}
+	if err := verifyProbe(path, []byte("other\n"), 0, time.Now()); err == nil {
+		t.Errorf("expected a different read-back content to fail")
+	}
+
+	// A large payload must not end up in the error, it is logged and sent on.
+	large := bytes.Repeat([]byte("a"), 4<<20)
+	if err := os.WriteFile(path, large, 0o644); err != nil {
+		t.Fatalf("cannot write probe: %v", err)
+	}
+	want := bytes.Repeat([]byte("a"), 4<<20)
+	want[1<<20] = 'b'
+	if err := verifyProbe(path, want, 0, time.Now()); err == nil {
+		t.Error("expected a different large read-back content to fail")
+	} else if len(err.Error()) > 256 || !strings.Contains(err.Error(), "offset 1048576") {
+		t.Errorf("expected a short error naming the first difference, got %d bytes: %.300s", len(err.Error()), err)
+	}
+	if err := os.WriteFile(path, []byte("nonce\n"), 0o644); err != nil {
+		t.Fatalf("cannot write probe: %v", err)
+	}
+
+	// The server clock may be skewed: tolerated within the window only.
+	skewed := time.Now().Add(10 * time.Second)
+	if err := verifyProbe(path, []byte("nonce\n"), time.Minute, skewed); err != nil {
+		t.Errorf("expected skew within the window to be tolerated, got %v", err)
+	}
+	if err := verifyProbe(path, []byte("nonce\n"), time.Second, skewed); err == nil || !strings.HasPrefix(err.Error(), "write_verify:") {
+		t.Errorf("expected skew beyond the window to fail, got %v", err)
+	}
+	if err := verifyProbe(path, []byte("nonce\n"), 0, time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("expected no freshness check with skew 0, got %v", err)
+	}
+}
+
+func TestWriteTestReadDuration(t *testing.T) {
+	mp := MountPoint{Path: t.TempDir()}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, EnableWriteTest: true})
+	if w.nfsWriteTestRead != nil {
+		t.Fatal("expected no read histogram without write verification")
+	}
+
+	w = NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		CheckInterval:   time.Second,
+		EnableWriteTest: true,
+		WriteVerify:     true,
+		WriteFsync:      true,
+	})
+	if err := w.writeTest(mp); err != nil {
+		t.Fatalf("write test failed: %v", err)
+	}
+	if got := testutil.CollectAndCount(w.nfsWriteTestRead); got != 1 {
+		t.Errorf("expected one read duration series, got %d", got)
+	}
+
+	w.deleteSeries(mp.Path)
+	if got := testutil.CollectAndCount(w.nfsWriteTestRead); got != 0 {
+		t.Errorf("expected the read duration series to be deleted, got %d", got)
+	}
+}
+
+func TestCheckMountsConcurrently(t *testing.T) {
+	var dirs []string
+	var fixture strings.Builder
+	for range 3 {
+		dir := t.TempDir()
+		dirs = append(dirs, dir)
+		fixture.WriteString("nfs1:/export " + dir + " nfs4 rw 0 0\n")
+	}
+	mountsFile := writeMountsFixture(t, fixture.String())
+
+	// The write test cleanup of every mount point takes a while, long enough
+	// for concurrent checks to overlap there.
+	var running, peak atomic.Int32
+	removeProbe = func(path string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return os.Remove(path)
+	}
+	t.Cleanup(func() { removeProbe = os.Remove })
+
+	for _, limit := range []int{1, 2} {
+		peak.Store(0)
+		w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(dirs...), WatchdogOptions{
+			CheckInterval:       time.Second,
+			EnableWriteTest:     true,
+			MountsFile:          mountsFile,
+			MaxConcurrentChecks: limit,
+		})
+		w.CheckAll()
+		if !w.IsHealthy() {
+			t.Errorf("limit %d: expected all mount points healthy after the cycle, got %+v", limit, w.Status())
+		}
+		if got := peak.Load(); got != int32(limit) {
+			t.Errorf("limit %d: expected %d checks at once, got %d", limit, limit, got)
+		}
+	}
+}
+
+func TestCheckTimestampMetrics(t *testing.T) {
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "nfs1:/export "+dir+" nfs4 rw 0 0\n")
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	timestamps := func() (check, success, transition float64) {
+		return testutil.ToFloat64(w.nfsLastCheckTime.WithLabelValues(dir, dir)),
+			testutil.ToFloat64(w.nfsLastSuccessTime.WithLabelValues(dir, dir)),
+			testutil.ToFloat64(w.nfsTransitionTime.WithLabelValues(dir, dir))
+	}
+
+	before := unixSeconds(time.Now())
+	w.CheckAll()
+	check, success, transition := timestamps()
+	if check < before || success != check || transition != check {
+		t.Fatalf("expected the first passed check to set all timestamps, got check %v success %v transition %v", check, success, transition)
+	}
+
+	// A failure advances the last check and marks the transition, the last
+	// success stays.
+	if err := os.WriteFile(mountsFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.CheckAll()
+	failedCheck, failedSuccess, failedTransition := timestamps()
+	if failedCheck <= check || failedSuccess != success || failedTransition != failedCheck {
+		t.Errorf("expected a failed check to keep the last success, got check %v success %v transition %v", failedCheck, failedSuccess, failedTransition)
+	}
+
+	w.CheckAll()
+	if again, _, stillTransition := timestamps(); again <= failedCheck || stillTransition != failedTransition {
+		t.Errorf("expected no transition without a state change, got check %v transition %v", again, stillTransition)
+	}
+
+	w.deleteSeries(dir)
+	if got := testutil.CollectAndCount(w.nfsLastCheckTime); got != 0 {
+		t.Errorf("expected the timestamp series to be deleted, got %d", got)
+	}
+}
+
+func TestSetMountPointsDiffSorted(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/z", "/mnt/y"), WatchdogOptions{CheckInterval: time.Second})
+	diff, err := w.SetMountPoints(testMountPoints("/mnt/c", "/mnt/a", "/mnt/b"))
+	if err != nil {
+		t.Fatalf("SetMountPoints failed: %v", err)
+	}
+	if !slices.IsSorted(diff.Added) || !slices.IsSorted(diff.Removed) {
+		t.Errorf("expected sorted diff, got %+v", diff)
+	}
+}
+
+func TestMountLookupsWriteProbe(t *testing.T) {
+	dir := t.TempDir()
+	mountsFile := writeMountsFixture(t, "server:/export "+dir+" nfs4 rw,hard 0 0\n")
+	disabled := false
+	points := []MountPoint{{Path: dir, CheckSubpath: "uploads"}, {Path: "/mnt/other", WriteTest: &disabled}}
+	if err := os.Mkdir(filepath.Join(dir, "uploads"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", points, WatchdogOptions{
+		CheckInterval:   time.Second,
+		EnableWriteTest: true,
+		MountsFile:      mountsFile,
+	})
+	w.CheckAll()
+
+	lookups := w.MountLookups()
+	if len(lookups) != 2 {
+		t.Fatalf("expected 2 lookups, got %+v", lookups)
+	}
+	want := filepath.Join(dir, "uploads", probeFilePrefix+w.writeVerify.owner+"_<pid>_<unixnano>")
+	for _, l := range lookups {
+		switch l.MountPoint {
+		case dir:
+			if l.WriteProbe != want {
+				t.Errorf("expected write probe %q, got %q", want, l.WriteProbe)
+			}
+		default:
+			if l.WriteProbe != "" {
+				t.Errorf("expected no write probe with the write test disabled, got %q", l.WriteProbe)
+			}
+		}
+	}
+}
+
+func TestNoMountPointsIsUnhealthy(t *testing.T) {
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints("/mnt/a"), WatchdogOptions{CheckInterval: time.Second})
+	w.setHealthy("/mnt/a", true)
+	if _, err := w.SetMountPoints(nil); err != nil {
+		t.Fatalf("SetMountPoints failed: %v", err)
+	}
+
+	if w.IsHealthy() {
+		t.Errorf("expected an empty watchlist to be unhealthy")
+	}
+	if r := w.Readiness(); r.State != ReadinessNotReady || !r.NoMountPoints {
+		t.Errorf("unexpected readiness %+v", r)
+	}
+	if got := testutil.ToFloat64(w.monitoredMounts); got != 0 {
+		t.Errorf("expected monitored_mounts 0, got %v", got)
+	}
+
+	w.healthyWhenEmpty = true
+	if !w.IsHealthy() || w.Readiness().State != ReadinessReady {
+		t.Errorf("expected an empty watchlist to be healthy with healthyWhenEmpty")
+	}
+}
+
+func TestCheckMountPointFSTypes(t *testing.T) {
+	share := MountPoint{Path: t.TempDir(), FSTypes: []string{"cifs"}}
+	nfs := MountPoint{Path: t.TempDir()}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{share, nfs}, WatchdogOptions{
+		MountsFile: writeMountsFixture(t, ""+
+			"//fs1/share "+share.Path+" cifs rw 0 0\n"+
+			"//fs1/other "+nfs.Path+" cifs rw 0 0\n"),
+	})
+
+	w.CheckAll()
+	if healthy, _ := w.IsMountHealthy(share.Path); !healthy {
+		t.Errorf("expected the cifs mount point to accept a cifs mount: %+v", w.Status())
+	}
+	if healthy, _ := w.IsMountHealthy(nfs.Path); healthy {
+		t.Errorf("expected an NFS mount point to reject a cifs mount")
+	}
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues(nfs.Path, nfs.Path, "", "", "error", reasonNotNFS)); got != 1 {
+		t.Errorf("expected a not_nfs failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsFSTypeInfo.WithLabelValues(share.Path, share.Path, "cifs")); got != 1 {
+		t.Errorf("expected the fstype info of the share, got %v", got)
+	}
+
+	w.mounts = MountsFile(writeMountsFixture(t, "nfs1:/export "+share.Path+" nfs4 rw 0 0\n"))
+	w.CheckMountPoint(share)
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues(share.Path, share.Path, "nfs1", "/export", "error", reasonWrongFSType)); got != 1 {
+		t.Errorf("expected a wrong_fstype failure, got %v", got)
+	}
+	if got := testutil.CollectAndCount(w.nfsFSTypeInfo); got != 2 {
+		t.Errorf("expected one fstype series per mount point, got %d", got)
+	}
+}
+
+func TestCheckEnclosingMount(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "app", "data")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mountsFile := writeMountsFixture(t, "/dev/sda1 / ext4 rw 0 0\nnfs1:/export "+dir+" nfs4 rw 0 0\n")
+
+	exact := NewWatchdog("test-program", "1.0.0", "test_ns", testMountPoints(sub), WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	if err := exact.checkMounted(MountPoint{Path: sub}, readMountTable(exact.mounts)); err == nil {
+		t.Errorf("expected a subdirectory to fail the exact mount point match")
+	}
+
+	mp := MountPoint{Path: sub, EnclosingMount: true}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{CheckInterval: time.Second, MountsFile: mountsFile})
+	if err := w.checkMounted(mp, readMountTable(w.mounts)); err != nil {
+		t.Errorf("expected the enclosing NFS mount to be accepted, got %v", err)
+	}
+	if lookup := readMountTable(w.mounts).lookup(mp); !lookup.Matched || lookup.Entry.MountPoint != dir {
+		t.Errorf("expected the lookup to report the enclosing mount, got %+v", lookup)
+	}
+
+	other := MountPoint{Path: t.TempDir(), EnclosingMount: true}
+	err := w.checkMounted(other, readMountTable(w.mounts))
+	if errorReason(err) != reasonNotNFS || !strings.Contains(err.Error(), "enclosed by /") {
+		t.Errorf("expected the root filesystem to be rejected as not NFS, got %v", err)
+	}
+}
+
+func TestServerAndExportLabels(t *testing.T) {
+	dir := t.TempDir()
+	mp := MountPoint{Path: dir}
+	w := NewWatchdog("test-program", "1.0.0", "test_ns", []MountPoint{mp}, WatchdogOptions{
+		MountsFile: writeMountsFixture(t, "nfs1:/export/app "+dir+" nfs4 rw 0 0\n"),
+	})
+	w.CheckMountPoint(mp)
+	if got := testutil.ToFloat64(w.nfsMountHealthy.WithLabelValues(dir, dir, "nfs1", "/export/app")); got != 1 {
+		t.Errorf("expected the mount health labeled with server and export, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsSourceInfo.WithLabelValues(dir, dir, "nfs1", "/export/app")); got != 1 {
+		t.Errorf("expected the source info, got %v", got)
+	}
+
+	// A new source replaces the series, a missing mount keeps the last known one.
+	w.mounts = MountsFile(writeMountsFixture(t, "nfs2:/export/moved "+dir+" nfs4 rw 0 0\n"))
+	w.CheckMountPoint(mp)
+	w.mounts = MountsFile(writeMountsFixture(t, ""))
+	w.CheckMountPoint(mp)
+	for _, vec := range []prometheus.Collector{w.nfsMountHealthy, w.nfsSourceInfo} {
+		if n := testutil.CollectAndCount(vec); n != 1 {
+			t.Errorf("expected the series of the old source to be removed, got %d series", n)
+		}
+	}
+	if got := testutil.ToFloat64(w.nfsMountHealthy.WithLabelValues(dir, dir, "nfs2", "/export/moved")); got != 0 {
+		t.Errorf("expected the unmounted mount labeled with its last known source, got %v", got)
+	}
+	if got := testutil.ToFloat64(w.nfsChecksTotal.WithLabelValues(dir, dir, "nfs2", "/export/moved", "error", reasonNotInMountTable)); got != 1 {
+		t.Errorf("expected the failure counted against the last known source, got %v", got)
+	}
+}
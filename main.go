@@ -2,15 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"nfs_mounter_agent/internal"
-	"path/filepath"
+	"nfs_mounter_agent/internal/config"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -19,63 +32,906 @@ var ProgramVersion = "dev"
 const (
 	programName        = "nfs_mounter_agent"
 	mountPointsSubpath = "mount-points/"
+	shutdownTimeout    = 10 * time.Second
+	// defaultBuckets are the Prometheus default histogram buckets.
+	defaultBuckets = "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10"
 )
 
 // MountPoints implements flag.Value to allow --mount-point repeated.
-type MountPoints []string
+type MountPoints []internal.MountPoint
 
 func (m *MountPoints) String() string {
-	return strings.Join(*m, ",")
+	paths := make([]string, len(*m))
+	for i, mp := range *m {
+		paths[i] = mp.Path
+	}
+	return strings.Join(paths, ",")
 }
 
 func (m *MountPoints) Set(value string) error {
-	if !filepath.IsAbs(value) {
-		return fmt.Errorf("mount point must be an absolute path: %q", value)
+	mp, err := internal.ParseMountPoint(value)
+	if err != nil {
+		return err
+	}
+	mp.Origin = internal.OriginFlag
+	*m = append(*m, mp)
+	return nil
+}
+
+// Listens implements flag.Value to allow --listen repeated.
+type Listens []internal.ListenSpec
+
+func (l *Listens) String() string {
+	specs := make([]string, len(*l))
+	for i, spec := range *l {
+		specs[i] = spec.Address + "=" + strings.Join(spec.Groups, ",")
+		if spec.Network == "unix" {
+			specs[i] = "unix:" + specs[i]
+		}
+	}
+	return strings.Join(specs, " ")
+}
+
+func (l *Listens) Set(value string) error {
+	spec, err := internal.ParseListenSpec(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, spec)
+	return nil
+}
+
+// SocketListens implements flag.Value to allow --listen-socket repeated,
+// adding Unix domain socket listeners to Listens.
+type SocketListens struct {
+	listens *Listens
+}
+
+func (l SocketListens) String() string {
+	if l.listens == nil {
+		return ""
+	}
+	return l.listens.String()
+}
+
+func (l SocketListens) Set(value string) error {
+	spec, err := internal.ParseSocketSpec(value)
+	if err != nil {
+		return err
+	}
+	*l.listens = append(*l.listens, spec)
+	return nil
+}
+
+// URLs implements flag.Value to allow --notify-url repeated.
+type URLs []string
+
+func (u *URLs) String() string {
+	return strings.Join(*u, ",")
+}
+
+func (u *URLs) Set(value string) error {
+	if _, err := parseHTTPURL(value); err != nil {
+		return err
+	}
+	*u = append(*u, value)
+	return nil
+}
+
+// parseHTTPURL parses an absolute http or https URL.
+func parseHTTPURL(value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("not an http or https URL: %q", value)
 	}
-	*m = append(*m, value)
+	return parsed, nil
+}
+
+// Labels implements flag.Value to allow --label repeated.
+type Labels map[string]string
+
+func (l *Labels) String() string {
+	pairs := make([]string, 0, len(*l))
+	for key, value := range *l {
+		pairs = append(pairs, key+"="+value)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l *Labels) Set(value string) error {
+	key, labelValue, err := internal.ParseConstLabel(value)
+	if err != nil {
+		return err
+	}
+	if *l == nil {
+		*l = Labels{}
+	}
+	if _, ok := (*l)[key]; ok {
+		return fmt.Errorf("label %q given twice", key)
+	}
+	(*l)[key] = labelValue
 	return nil
 }
 
+// reloadConfig re-reads the config file and applies its mount points and runtime
+// tunables. Settings given explicitly as flags keep precedence over the file.
+func reloadConfig(watchdog *internal.Watchdog, path string, flagMountPoints []internal.MountPoint, explicit map[string]bool, running *config.Config) (*internal.ReloadResult, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	points := append(append([]internal.MountPoint(nil), flagMountPoints...), cfg.WatchdogMountPoints()...)
+	if err := internal.ValidateMountPoints(points); err != nil {
+		return nil, err
+	}
+
+	diff, err := watchdog.ReloadMountPoints(points)
+	if err != nil {
+		return nil, err
+	}
+	result := &internal.ReloadResult{
+		MountPointsDiff: diff,
+		Changed:         []string{},
+		RequiresRestart: []string{},
+	}
+	if !explicit["check-interval"] && cfg.CheckInterval != 0 && cfg.CheckInterval != watchdog.CheckInterval() {
+		watchdog.SetCheckInterval(cfg.CheckInterval)
+		result.Changed = append(result.Changed, "check_interval")
+	}
+	failureThreshold, successThreshold := watchdog.HealthThresholds()
+	if !explicit["failure-threshold"] && cfg.FailureThreshold != 0 && cfg.FailureThreshold != failureThreshold {
+		failureThreshold = cfg.FailureThreshold
+		result.Changed = append(result.Changed, "failure_threshold")
+	}
+	if !explicit["success-threshold"] && cfg.SuccessThreshold != 0 && cfg.SuccessThreshold != successThreshold {
+		successThreshold = cfg.SuccessThreshold
+		result.Changed = append(result.Changed, "success_threshold")
+	}
+	watchdog.SetHealthThresholds(failureThreshold, successThreshold)
+	if spaceWarnPercent := watchdog.SpaceWarnPercent(); !explicit["space-warn-percent"] && cfg.SpaceWarnPercent != spaceWarnPercent {
+		switch {
+		case cfg.SpaceWarnPercent > 0 && spaceWarnPercent > 0:
+			watchdog.SetSpaceWarnPercent(cfg.SpaceWarnPercent)
+			result.Changed = append(result.Changed, "space_warn_percent")
+		case cfg.SpaceWarnPercent > 0:
+			// The low space metric is only registered with a threshold at startup.
+			result.RequiresRestart = append(result.RequiresRestart, "space_warn_percent")
+		}
+	}
+	if !slices.Equal(internal.TagKeys(points), watchdog.TagKeys()) {
+		// Label names of registered metrics cannot change, new tag keys are not emitted.
+		result.RequiresRestart = append(result.RequiresRestart, "mount_point_tags")
+	}
+	if !explicit["listen-address"] && cfg.ListenAddress != "" && cfg.ListenAddress != running.ListenAddress {
+		result.RequiresRestart = append(result.RequiresRestart, "listen_address")
+	}
+	if !explicit["telemetry-path"] && cfg.TelemetryPath != "" && cfg.TelemetryPath != running.TelemetryPath {
+		result.RequiresRestart = append(result.RequiresRestart, "telemetry_path")
+	}
+	if !explicit["telemetry-namespace"] && cfg.TelemetryNamespace != "" && cfg.TelemetryNamespace != running.TelemetryNamespace {
+		result.RequiresRestart = append(result.RequiresRestart, "telemetry_namespace")
+	}
+	if !explicit["mount-backend"] && cfg.MountBackend != "" && cfg.MountBackend != running.MountBackend {
+		result.RequiresRestart = append(result.RequiresRestart, "mount_backend")
+	}
+	return result, nil
+}
+
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	// Re-executed by the watchdog to run the write test as --probe-uid/--probe-gid.
+	if os.Getenv(internal.ProbeHelperEnv) != "" {
+		os.Exit(internal.RunProbeHelper(os.Args[1:]))
+	}
 
-	listenAddressPtr := flag.String("listen-address", "0.0.0.0:9090", "Listen address for HTTP server")
+	configPtr := flag.String("config", "", "YAML/JSON config file or directory of config files (flags take precedence)")
+	adminTokenPtr := flag.String("admin-token", "", "Bearer token required by the admin API (admin API disabled when empty)")
+	enablePprofPtr := flag.Bool("enable-pprof", false, "Serve the net/http/pprof handlers and a /debug/goroutines dump on the listeners serving the admin API or listing pprof, behind --admin-token when set")
+	listenAddressPtr := flag.String("listen-address", "0.0.0.0:9090", "Listen address for HTTP server (disabled when empty, e.g. with --textfile-output)")
+	var listens Listens
+	flag.Var(&listens, "listen", "HTTP listener address=groups serving only the endpoint groups "+strings.Join(internal.ListenGroups, ", ")+", e.g. 127.0.0.1:9100=metrics,admin (can be repeated, replaces --listen-address)")
+	flag.Var(SocketListens{&listens}, "listen-socket", "Unix domain socket path[=groups] serving the HTTP endpoints to local processes, e.g. /run/nfsma.sock (can be repeated)")
+	listenSocketModePtr := flag.String("listen-socket-mode", "0660", "File mode of the --listen-socket sockets, octal")
+	tlsCertPtr := flag.String("tls-cert", "", "PEM certificate to serve HTTPS with (requires --tls-key, read again on every handshake)")
+	tlsKeyPtr := flag.String("tls-key", "", "PEM private key of --tls-cert")
+	tlsClientCAPtr := flag.String("tls-client-ca", "", "PEM CA bundle; when set, clients must present a certificate signed by one of its CAs (mTLS)")
+	authBasicFilePtr := flag.String("auth-basic-file", "", "File of basic auth users, one user:bcrypt-hash line each as written by htpasswd -nB (disabled when empty)")
+	authTokenPtr := flag.String("auth-token", "", "Bearer token accepted by the protected endpoints (disabled when empty)")
+	authEndpointsPtr := flag.String("auth-endpoints", strings.Join(internal.AuthGroups, ","), "Endpoint groups requiring --auth-basic-file or --auth-token credentials: metrics, health, status")
+	textfileOutputPtr := flag.String("textfile-output", "", "Write the metrics to this .prom file for the node_exporter textfile collector after every check cycle (disabled when empty)")
 	telemetryPathPtr := flag.String("telemetry-path", "/metrics", "Telemetry path")
 	namespacePtr := flag.String("telemetry-namespace", "nfsma", "Metrics namespace")
+	var constLabels Labels
+	flag.Var(&constLabels, "label", "Constant label key=value attached to all exported metrics, e.g. environment=prod (can be repeated)")
+	noGoCollectorPtr := flag.Bool("no-go-collector", false, "Leave the Go runtime metrics (go_*) out of the exported metrics")
+	noProcessCollectorPtr := flag.Bool("no-process-collector", false, "Leave the process metrics (process_*) out of the exported metrics")
+	httpTimeoutPtr := flag.Duration("http-timeout", 10*time.Second, "Maximum handler execution time of health endpoints before answering 503 (0 disables)")
+	grpcHealthAddressPtr := flag.String("grpc-health-address", "", "Listen address of the gRPC health checking service over plaintext HTTP/2, e.g. 0.0.0.0:9091 (disabled when empty)")
 	healthPathPtr := flag.String("health-path", "/health", "Health check path (global and per mount-point sub-path: '"+mountPointsSubpath+"')")
+	enableRemountPtr := flag.Bool("enable-remount", false, "Remount mount points with a remount-source after --remount-after consecutive failed checks")
+	unmountOnShutdownPtr := flag.Bool("unmount-on-shutdown", false, "Unmount mount points whose remount-source is mounted when the agent stops")
+	mountOnStartupPtr := flag.Bool("mount-on-startup", false, "Mount the remount-source of mount points that are not mounted yet before monitoring, retrying until mounted")
+	remountAfterPtr := flag.Int("remount-after", 3, "Consecutive failed checks before a remount attempt")
+	mountBackendPtr := flag.String("mount-backend", internal.MountBackendSyscall, "How mounts and unmounts are done: syscall (mount(2), falling back to the mount command for Kerberos or without vers=) or exec (the mount and umount commands, for /sbin/mount.nfs helpers)")
+	remountBackoffPtr := flag.Duration("remount-backoff", 30*time.Second, "Least time between two remount attempts of a mount point, doubling after every attempt up to 30m (0: only --remount-after applies)")
+	failureThresholdPtr := flag.Int("failure-threshold", 1, "Consecutive failed checks before a healthy mount point is reported unhealthy")
+	successThresholdPtr := flag.Int("success-threshold", 1, "Consecutive passed checks before an unhealthy mount point is reported healthy")
+	retryIntervalPtr := flag.Duration("retry-interval", 0, "Re-check a mount point failing its checks after this delay instead of its check interval, so a recovery is detected quickly (0 disables)")
+	retryBackoffPtr := flag.Float64("retry-backoff", 2, "Factor applied to --retry-interval for every further failed check, up to the check interval (1 keeps it constant)")
+	maxConcurrentChecksPtr := flag.Int("max-concurrent-checks", 4, "Maximum number of mount points checked at the same time (1 checks them one after another)")
+	checkTimeoutPtr := flag.Duration("check-timeout", 10*time.Second, "Maximum duration of a mount point check before it is reported as a timeout (0 disables)")
+	healthyWhenEmptyPtr := flag.Bool("healthy-when-empty", false, "Report healthy when no mount point is monitored, e.g. after a reload or before discovery (unhealthy by default)")
+	minHealthyCountPtr := flag.Int("min-healthy-count", 0, "Global health endpoint is healthy while at least this many mount points are, regardless of which (0: all must be healthy)")
+	readinessPathPtr := flag.String("readiness-path", "/readyz", "Three-state readiness endpoint (ready, degraded, not ready) as JSON (disabled when empty)")
+	degradedStatusPtr := flag.Int("degraded-status", http.StatusOK, "HTTP status of the readiness endpoint when only optional mount points are unhealthy")
+	unknownStatusPtr := flag.Int("health-unknown-status", http.StatusServiceUnavailable, "HTTP status of the health endpoints while the health is unknown, before the first check of the mount points")
+	startupGracePeriodPtr := flag.Duration("startup-grace-period", 0, "How long after startup --health-unknown-status applies; afterwards an unknown health counts as unhealthy (0: until the first check)")
+	statusPathPtr := flag.String("status-path", "/status", "Snapshot of all mount points as JSON, or as a text table with ?format=text (disabled when empty)")
+	probePathPtr := flag.String("probe-path", "/probe", "Live check of an arbitrary path, blackbox exporter style: ?target=/path (disabled when empty)")
+	probeTimeoutPtr := flag.Duration("probe-timeout", 10*time.Second, "Maximum duration of a probe, lowered by the scrape timeout of Prometheus")
+	eventsPathPtr := flag.String("events-path", "/events", "Server-Sent Events stream of mount state changes (disabled when empty)")
+	eventLogPathPtr := flag.String("event-log-path", "/api/v1/events", "Recent transitions, check errors and remount attempts as JSON (disabled when empty)")
+	eventLogSizePtr := flag.Int("event-log-size", 1000, "Number of recent events kept in memory for the event log (0 disables)")
+	eventsBufferPtr := flag.Int("events-buffer", 16, "Per-client event buffer, clients falling further behind are disconnected")
 	checkIntervalPtr := flag.Duration("check-interval", 30*time.Second, "Interval between mount checks")
+	checkJitterPtr := flag.Float64("check-jitter", 0, "Random fraction by which every check interval is lengthened or shortened, e.g. 0.1 for ±10%, to spread the checks of many agents (0 disables)")
 	enableWriteTestPtr := flag.Bool("enable-write-test", false, "Enable write-test as part of the mount health check")
+	writeVerifyPtr := flag.Bool("write-verify", false, "Read the write test probe back and compare its random nonce")
+	writeVerifySkewPtr := flag.Duration("write-verify-skew", 5*time.Minute, "Tolerated difference between the probe file mtime (NFS server clock) and the local clock (0 disables)")
+	writeFsyncPtr := flag.Bool("write-fsync", false, "Fsync the write test probe and drop it from the page cache before it is read back")
+	probeUIDPtr := flag.Int("probe-uid", -1, "Run the write test in a helper process as this uid (requires --probe-gid, disabled when negative); helpers are killed when the agent stops, but one stuck on a hung hard mount stays alive, one per mount")
+	probeGIDPtr := flag.Int("probe-gid", -1, "Group id of the write test helper process (requires --probe-uid)")
+	enableLockTestPtr := flag.Bool("enable-lock-test", false, "Take and release a POSIX lock on a test file in every check")
+	instanceIDPtr := flag.String("instance-id", "", "Agent instance ID in the write test probe file names after the hostname, telling apart agents on one host (default: random per start)")
+	orphanProbeAgePtr := flag.Duration("write-test-orphan-age", time.Hour, "Remove write test probe files older than this, left by interrupted write tests, after the first passed write test of a mount point (0 disables)")
+	orphanProbeIntervalPtr := flag.Duration("write-test-orphan-interval", 0, "Scan for orphaned write test probe files again at this interval (0: only after the first passed write test)")
+	strictCleanupPtr := flag.Bool("strict-write-test-cleanup", false, "Fail the write test when the probe file cannot be removed (counted and logged otherwise)")
+	enableStatfsCheckPtr := flag.Bool("enable-statfs-check", false, "Detect mounts forced read-only by the kernel using statfs flags")
+	degradeOnOptionDriftPtr := flag.Bool("degrade-on-option-drift", false, "Mark a mount point degraded while its mount options drifted from its expect-options")
+	spaceWarnPercentPtr := flag.Float64("space-warn-percent", 0, "Mark a mount point degraded while its used space exceeds this percentage (0 disables)")
+	checkDurationBucketsPtr := flag.String("check-duration-buckets", defaultBuckets, "Comma-separated buckets of the check duration histogram in seconds")
+	writeTestBucketsPtr := flag.String("write-test-buckets", defaultBuckets, "Comma-separated buckets of the write test duration histograms in seconds")
+	lockTestBucketsPtr := flag.String("lock-test-buckets", defaultBuckets, "Comma-separated buckets of the lock test duration histogram in seconds")
+	availabilityWindowsPtr := flag.String("availability-windows", "5m,1h", "Comma-separated sliding windows of the availability ratio metric (disabled when empty)")
+	latencyWindowPtr := flag.Duration("latency-window", 5*time.Minute, "Sliding window of the slowest check duration metric")
+	mountsFilePtr := flag.String("mounts-file", "/proc/mounts", "Mount table used to detect NFS mounts")
+	discoverPtr := flag.Bool("discover", false, "Monitor the NFS mounts found in the mount table, in addition to the configured mount points")
+	discoverIncludePtr := flag.String("discover-include", "", "Regular expression the path of a discovered mount must match (all when empty)")
+	discoverExcludePtr := flag.String("discover-exclude", "", "Regular expression excluding discovered mounts by path (none when empty)")
+	discoverIntervalPtr := flag.Duration("discover-interval", time.Minute, "Interval between mount table scans of --discover")
+	discoverGracePtr := flag.Duration("discover-grace", 5*time.Minute, "Time a discovered mount may be missing from the mount table before it is no longer monitored")
+	enableNFSProcPtr := flag.Bool("enable-nfs-proc", false, "Export nfs_server_reachable from the kernel NFS client state in /proc/fs/nfsfs/servers")
+	enableServerProbePtr := flag.Bool("enable-server-probe", false, "Dial the NFS server of every monitored mount over TCP and export server_reachable")
+	serverProbeRPCBindPtr := flag.Bool("server-probe-rpcbind", false, "Also dial rpcbind (port 111) with --enable-server-probe")
+	serverProbeTimeoutPtr := flag.Duration("server-probe-timeout", 2*time.Second, "Timeout of a single server probe connection")
+	enableMountStatsPtr := flag.Bool("enable-mountstats", false, "Export NFS client statistics of the monitored mounts from /proc/self/mountstats")
+	oncePtr := flag.Bool("once", false, "Check all mount points once, print the results and exit 0 (ready), 1 (degraded) or 2 (not ready) without serving HTTP")
+	systemdNotifyPtr := flag.Bool("systemd-notify", false, "Send READY=1 to systemd after the first check cycle and WATCHDOG=1 pings while the check loop progresses (Type=notify, WatchdogSec=)")
+	selfTestPtr := flag.Bool("self-test", false, "Verify the environment on startup and exit non-zero on failure")
+	drainTimeoutPtr := flag.Duration("drain-timeout", 0, "Grace period on SIGTERM/SIGINT during which /health reports draining before shutdown")
+	recheckDependenciesPtr := flag.Bool("recheck-dependencies", false, "Check depends-on paths on every cycle instead of only until they are first met")
+	watchMountEventsPtr := flag.Bool("watch-mount-events", false, "Check all mount points as soon as the mount table changes (Linux, falls back to polling)")
+	mountEventsMinIntervalPtr := flag.Duration("mount-events-min-interval", time.Second, "Minimum time between two checks triggered by mount table changes")
+	initialDelayPtr := flag.Duration("initial-delay", 0, "Delay before the first check, to spread the startup load of a fleet on shared NFS servers")
+	initialDelayRandomPtr := flag.Bool("initial-delay-random", false, "Pick the initial delay uniformly between 0 and --initial-delay")
+	mountTableErrorHoldPtr := flag.Duration("mount-table-error-hold", 0, "Keep the last known mount health for up to this long while the mount table cannot be read (0 reports unhealthy at once)")
+	noInitialCheckPtr := flag.Bool("no-initial-check", false, "Skip the synchronous check on startup, the first check runs on the first tick")
+	scrapeTimeChecksPtr := flag.Bool("scrape-time-checks", false, "Check mount presence at scrape time (exported as mount_present)")
+	scrapeCheckCachePtr := flag.Duration("scrape-check-cache", 5*time.Second, "How long a scrape-time presence check result is reused")
+	scrapeCheckTimeoutPtr := flag.Duration("scrape-check-timeout", 2*time.Second, "Maximum time a scrape waits for a presence check")
+	influxPushURLPtr := flag.String("influx-push-url", "", "Endpoint receiving the mount state in InfluxDB line protocol (disabled when empty)")
+	influxPushIntervalPtr := flag.Duration("influx-push-interval", 30*time.Second, "Interval between InfluxDB line protocol pushes")
+	otlpEndpointPtr := flag.String("otlp-endpoint", "", "OpenTelemetry collector receiving the metrics and a span per check over OTLP/HTTP, e.g. http://collector:4318 (disabled when empty)")
+	otlpIntervalPtr := flag.Duration("otlp-interval", 30*time.Second, "Interval between OTLP exports")
+	pushgatewayURLPtr := flag.String("pushgateway-url", "", "Prometheus Pushgateway receiving the metrics (disabled when empty)")
+	pushIntervalPtr := flag.Duration("push-interval", 30*time.Second, "Interval between Pushgateway pushes")
+	pushJobPtr := flag.String("push-job", programName, "Job label of the Pushgateway group")
+	pushInstancePtr := flag.String("push-instance", "", "Instance label of the Pushgateway group (default: the hostname)")
+	pushDeleteOnShutdownPtr := flag.Bool("push-delete-on-shutdown", false, "Delete the Pushgateway group on shutdown instead of pushing the final state")
+	notifyTimeoutPtr := flag.Duration("notify-timeout", 5*time.Second, "Timeout of a single webhook request")
+	notifyRetriesPtr := flag.Int("notify-retries", 3, "Number of webhook retries on connection errors and 5xx responses")
+	notifyQueueSizePtr := flag.Int("notify-queue-size", 100, "Maximum number of pending webhook notifications (oldest are dropped)")
+	hookTimeoutPtr := flag.Duration("hook-timeout", 30*time.Second, "Maximum duration of an on-unhealthy or on-healthy hook command")
+	logFormatPtr := flag.String("log-format", "text", "Log format: text (logfmt) or json")
+	logLevelPtr := flag.String("log-level", "info", "Minimum log level: debug (also logs passed checks), info, warn or error")
 
 	var mountPoints MountPoints
-	flag.Var(&mountPoints, "mount-point", "Mount point to monitor (can be repeated, absolute paths only)")
+	flag.Var(&mountPoints, "mount-point", "Mount point to monitor as PATH[=ALIAS][?key=value&...], with =, ? and % in PATH and ALIAS escaped as %3D, %3F and %25 (can be repeated, absolute paths only)")
+	var notifyURLs URLs
+	flag.Var(&notifyURLs, "notify-url", "Webhook URL receiving mount state changes as JSON (can be repeated, disabled when not set)")
 
 	flag.Parse()
 
-	if len(mountPoints) == 0 {
-		log.Fatal("no mount points configured (use --mount-point /path/to/mount)")
+	logger, err := internal.NewLogger(os.Stderr, *logFormatPtr, *logLevelPtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Also routes the log package, e.g. net/http server errors, through the logger.
+	slog.SetDefault(logger)
+	if *oncePtr {
+		// Keep 1 and 2 for the health of the mount points.
+		fatalExitCode = 3
+	}
+
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	listenAddress := *listenAddressPtr
+	telemetryPath := *telemetryPathPtr
+	namespace := *namespacePtr
+	checkInterval := *checkIntervalPtr
+	failureThreshold := *failureThresholdPtr
+	successThreshold := *successThresholdPtr
+	spaceWarnPercent := *spaceWarnPercentPtr
+	mountBackendName := *mountBackendPtr
+	allMountPoints := append([]internal.MountPoint(nil), mountPoints...)
+	if *configPtr != "" {
+		cfg, err := config.Load(*configPtr)
+		if err != nil {
+			fatalf("cannot load config: %v", err)
+		}
+		if cfg.ListenAddress != "" && !explicit["listen-address"] {
+			listenAddress = cfg.ListenAddress
+		}
+		if cfg.TelemetryPath != "" && !explicit["telemetry-path"] {
+			telemetryPath = cfg.TelemetryPath
+		}
+		if cfg.TelemetryNamespace != "" && !explicit["telemetry-namespace"] {
+			namespace = cfg.TelemetryNamespace
+		}
+		if cfg.CheckInterval != 0 && !explicit["check-interval"] {
+			checkInterval = cfg.CheckInterval
+		}
+		if cfg.FailureThreshold != 0 && !explicit["failure-threshold"] {
+			failureThreshold = cfg.FailureThreshold
+		}
+		if cfg.SuccessThreshold != 0 && !explicit["success-threshold"] {
+			successThreshold = cfg.SuccessThreshold
+		}
+		if cfg.SpaceWarnPercent != 0 && !explicit["space-warn-percent"] {
+			spaceWarnPercent = cfg.SpaceWarnPercent
+		}
+		if cfg.MountBackend != "" && !explicit["mount-backend"] {
+			mountBackendName = cfg.MountBackend
+		}
+		allMountPoints = append(allMountPoints, cfg.WatchdogMountPoints()...)
+	}
+
+	if *orphanProbeAgePtr < 0 || *orphanProbeIntervalPtr < 0 {
+		fatalf("invalid --write-test-orphan-age or --write-test-orphan-interval: %s, %s", *orphanProbeAgePtr, *orphanProbeIntervalPtr)
+	}
+	if *remountAfterPtr < 1 {
+		fatalf("invalid --remount-after: %d", *remountAfterPtr)
+	}
+	if *remountBackoffPtr < 0 {
+		fatalf("invalid --remount-backoff: %s", *remountBackoffPtr)
+	}
+	mountBackend, err := internal.NewMountBackend(mountBackendName)
+	if err != nil {
+		fatalf("invalid --mount-backend: %v", err)
+	}
+	if failureThreshold < 1 {
+		fatalf("invalid --failure-threshold: %d", failureThreshold)
+	}
+	if successThreshold < 1 {
+		fatalf("invalid --success-threshold: %d", successThreshold)
+	}
+	if *retryIntervalPtr < 0 {
+		fatalf("invalid --retry-interval: %s", *retryIntervalPtr)
+	}
+	if *checkJitterPtr < 0 || *checkJitterPtr >= 1 {
+		fatalf("invalid --check-jitter: %g, must be at least 0 and below 1", *checkJitterPtr)
+	}
+	if *retryBackoffPtr < 1 {
+		fatalf("invalid --retry-backoff: %g, must be at least 1", *retryBackoffPtr)
+	}
+	if *maxConcurrentChecksPtr < 1 {
+		fatalf("invalid --max-concurrent-checks: %d", *maxConcurrentChecksPtr)
+	}
+	if *probeTimeoutPtr <= 0 {
+		fatalf("invalid --probe-timeout: %s", *probeTimeoutPtr)
+	}
+	checkDurationBuckets, err := internal.ParseBuckets(*checkDurationBucketsPtr)
+	if err != nil {
+		fatalf("invalid --check-duration-buckets: %v", err)
+	}
+	writeTestBuckets, err := internal.ParseBuckets(*writeTestBucketsPtr)
+	if err != nil {
+		fatalf("invalid --write-test-buckets: %v", err)
+	}
+	lockTestBuckets, err := internal.ParseBuckets(*lockTestBucketsPtr)
+	if err != nil {
+		fatalf("invalid --lock-test-buckets: %v", err)
+	}
+	availabilityWindows, err := internal.ParseWindows(*availabilityWindowsPtr)
+	if err != nil {
+		fatalf("invalid --availability-windows: %v", err)
+	}
+	if *eventLogSizePtr < 0 {
+		fatalf("invalid --event-log-size: %d", *eventLogSizePtr)
+	}
+	if *hookTimeoutPtr <= 0 {
+		fatalf("invalid --hook-timeout: %s", *hookTimeoutPtr)
+	}
+	if *checkTimeoutPtr < 0 {
+		fatalf("invalid --check-timeout: %s", *checkTimeoutPtr)
+	}
+	if spaceWarnPercent < 0 || spaceWarnPercent >= 100 {
+		fatalf("invalid --space-warn-percent: %g", spaceWarnPercent)
+	}
+	if *minHealthyCountPtr < 0 {
+		fatalf("invalid --min-healthy-count: %d", *minHealthyCountPtr)
+	}
+	if http.StatusText(*degradedStatusPtr) == "" {
+		fatalf("invalid --degraded-status: %d", *degradedStatusPtr)
+	}
+	if http.StatusText(*unknownStatusPtr) == "" {
+		fatalf("invalid --health-unknown-status: %d", *unknownStatusPtr)
+	}
+	if *startupGracePeriodPtr < 0 {
+		fatalf("invalid --startup-grace-period: %s", *startupGracePeriodPtr)
+	}
+
+	var tlsConfig *tls.Config
+	if *tlsCertPtr != "" || *tlsKeyPtr != "" || *tlsClientCAPtr != "" {
+		if tlsConfig, err = internal.NewTLSConfig(*tlsCertPtr, *tlsKeyPtr, *tlsClientCAPtr); err != nil {
+			fatalf("invalid TLS settings: %v", err)
+		}
+	}
+
+	// protect requires credentials on the endpoints of the group, when configured.
+	protect := func(group string, h http.Handler) http.Handler { return h }
+	if *authBasicFilePtr != "" || *authTokenPtr != "" {
+		authenticator, err := internal.NewAuthenticator(*authBasicFilePtr, *authTokenPtr)
+		if err != nil {
+			fatalf("invalid authentication settings: %v", err)
+		}
+		groups, err := internal.ParseAuthGroups(*authEndpointsPtr)
+		if err != nil {
+			fatalf("invalid --auth-endpoints: %v", err)
+		}
+		protect = func(group string, h http.Handler) http.Handler {
+			if !groups[group] {
+				return h
+			}
+			return authenticator.Require(h)
+		}
+	}
+	// HTTP handlers are registered by endpoint group, each listener serves its groups.
+	router := internal.NewRouter()
+	handle := func(group, pattern string, h http.Handler) {
+		router.Handle(group, pattern, protect(group, h))
+	}
+	isTCP := func(spec internal.ListenSpec) bool { return spec.Network == "tcp" }
+	if !slices.ContainsFunc(listens, isTCP) && listenAddress != "" {
+		listens = append(Listens{{Network: "tcp", Address: listenAddress, Groups: internal.DefaultListenGroups()}}, listens...)
+	}
+	servesPprof := func(spec internal.ListenSpec) bool { return slices.Contains(spec.Groups, internal.ListenGroupPprof) }
+	switch {
+	case !*enablePprofPtr && slices.ContainsFunc(listens, servesPprof):
+		fatalf("the pprof endpoint group requires --enable-pprof")
+	case *enablePprofPtr:
+		router.HandleDebug(func(h http.Handler) http.Handler {
+			if *adminTokenPtr == "" {
+				return h
+			}
+			return internal.RequireBearerToken(h, *adminTokenPtr)
+		})
+		// Without a listener listing pprof, the debug endpoints go with the admin API.
+		if !slices.ContainsFunc(listens, servesPprof) {
+			for i, spec := range listens {
+				if slices.Contains(spec.Groups, internal.ListenGroupAdmin) {
+					listens[i].Groups = append(slices.Clip(spec.Groups), internal.ListenGroupPprof)
+				}
+			}
+		}
+	}
+	socketMode, err := strconv.ParseUint(*listenSocketModePtr, 8, 32)
+	if err != nil || socketMode > 0o777 {
+		fatalf("invalid --listen-socket-mode: %q", *listenSocketModePtr)
+	}
+
+	var probeCredential *internal.ProbeCredential
+	if *probeUIDPtr >= 0 || *probeGIDPtr >= 0 {
+		if *probeUIDPtr < 0 || *probeGIDPtr < 0 {
+			fatalf("--probe-uid and --probe-gid must be set together")
+		}
+		probeCredential = &internal.ProbeCredential{UID: uint32(*probeUIDPtr), GID: uint32(*probeGIDPtr)}
+	}
+
+	if len(allMountPoints) == 0 && !*discoverPtr {
+		fatalf("no mount points configured (use --mount-point /path/to/mount, --config or --discover)")
+	}
+	var discoverInclude, discoverExclude *regexp.Regexp
+	if *discoverPtr {
+		if *discoverIncludePtr != "" {
+			if discoverInclude, err = regexp.Compile(*discoverIncludePtr); err != nil {
+				fatalf("invalid --discover-include: %v", err)
+			}
+		}
+		if *discoverExcludePtr != "" {
+			if discoverExclude, err = regexp.Compile(*discoverExcludePtr); err != nil {
+				fatalf("invalid --discover-exclude: %v", err)
+			}
+		}
+		if *discoverIntervalPtr <= 0 {
+			fatalf("invalid --discover-interval: %s", *discoverIntervalPtr)
+		}
+		if *discoverGracePtr < 0 {
+			fatalf("invalid --discover-grace: %s", *discoverGracePtr)
+		}
+	}
+	if err := internal.ValidateMountPoints(allMountPoints); err != nil {
+		fatalf("invalid mount points: %v", err)
+	}
+	if err := internal.ValidateConstLabels(constLabels, allMountPoints); err != nil {
+		fatalf("invalid --label: %v", err)
+	}
+
+	// Metrics are registered through registerer, which adds the --label labels,
+	// and gathered from registry.
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels(constLabels), registry)
+	if !*noGoCollectorPtr {
+		registerer.MustRegister(collectors.NewGoCollector())
+	}
+	if !*noProcessCollectorPtr {
+		registerer.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	watchdog := internal.NewWatchdog(programName, ProgramVersion, *namespacePtr, mountPoints, *checkIntervalPtr, *enableWriteTestPtr)
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+
+	watchdog := internal.NewWatchdog(programName, ProgramVersion, namespace, allMountPoints, internal.WatchdogOptions{
+		CheckInterval:          checkInterval,
+		EnableWriteTest:        *enableWriteTestPtr,
+		StrictWriteTestCleanup: *strictCleanupPtr,
+		OrphanProbeAge:         *orphanProbeAgePtr,
+		OrphanProbeInterval:    *orphanProbeIntervalPtr,
+		InstanceID:             *instanceIDPtr,
+		WriteVerify:            *writeVerifyPtr,
+		WriteVerifySkew:        *writeVerifySkewPtr,
+		WriteFsync:             *writeFsyncPtr,
+		ProbeCredential:        probeCredential,
+		EnableLockTest:         *enableLockTestPtr,
+		EnableStatfsCheck:      *enableStatfsCheckPtr,
+		SpaceWarnPercent:       spaceWarnPercent,
+		DegradeOnOptionDrift:   *degradeOnOptionDriftPtr,
+		MountsFile:             *mountsFilePtr,
+		EnableNFSProc:          *enableNFSProcPtr,
+		LatencyWindow:          *latencyWindowPtr,
+		AvailabilityWindows:    availabilityWindows,
+		CheckDurationBuckets:   checkDurationBuckets,
+		WriteTestBuckets:       writeTestBuckets,
+		LockTestBuckets:        lockTestBuckets,
+		SkipInitialCheck:       *noInitialCheckPtr,
+		MountTableErrorHold:    *mountTableErrorHoldPtr,
+		HealthyWhenEmpty:       *healthyWhenEmptyPtr,
+		EnableRemount:          *enableRemountPtr,
+		RemountAfter:           *remountAfterPtr,
+		RemountBackoff:         *remountBackoffPtr,
+		MountBackend:           mountBackend,
+		CheckTimeout:           *checkTimeoutPtr,
+		MaxConcurrentChecks:    *maxConcurrentChecksPtr,
+		MountOnStartup:         *mountOnStartupPtr,
+		FailureThreshold:       failureThreshold,
+		EnableServerProbe:      *enableServerProbePtr,
+		ServerProbeRPCBind:     *serverProbeRPCBindPtr,
+		ServerProbeTimeout:     *serverProbeTimeoutPtr,
+		SuccessThreshold:       successThreshold,
+		RetryInterval:          *retryIntervalPtr,
+		RetryBackoff:           *retryBackoffPtr,
+		CheckJitter:            *checkJitterPtr,
+		InitialDelay:           *initialDelayPtr,
+		RandomizeInitialDelay:  *initialDelayRandomPtr,
+		StrictDependencies:     *recheckDependenciesPtr,
+		WatchMountEvents:       *watchMountEventsPtr,
+		MountEventsMinInterval: *mountEventsMinIntervalPtr,
+		EventLogSize:           *eventLogSizePtr,
+		Registerer:             registerer,
+	})
+	// Discovered mount points are monitored from the first check on.
+	var discoverer *internal.Discoverer
+	if *discoverPtr {
+		discoverer = internal.NewDiscoverer(namespace, watchdog, discoverInclude, discoverExclude, *discoverIntervalPtr, *discoverGracePtr)
+		discoverer.Scan(time.Now())
+	}
+	if *selfTestPtr {
+		failed := false
+		for _, result := range watchdog.SelfTest() {
+			if result.Err != nil {
+				failed = true
+				slog.Error("self-test FAIL", "check", result.Name, "error", result.Err.Error())
+			} else {
+				slog.Info("self-test PASS", "check", result.Name)
+			}
+		}
+		if failed {
+			fatalf("self-test failed")
+		}
+	}
+	if *textfileOutputPtr != "" {
+		watchdog.OnCheckCycle(internal.NewTextfileWriter(namespace, *textfileOutputPtr, registerer, registry).Write)
+	}
+	var pusher *internal.PushgatewayPusher
+	if *pushgatewayURLPtr != "" {
+		if *pushIntervalPtr <= 0 {
+			fatalf("invalid --push-interval: %s", *pushIntervalPtr)
+		}
+		pusher = internal.NewPushgatewayPusher(namespace, *pushgatewayURLPtr, *pushJobPtr, *pushInstancePtr, *pushIntervalPtr, *pushDeleteOnShutdownPtr, registerer, registry)
+	}
+	if *oncePtr {
+		code := watchdog.CheckOnce(os.Stdout)
+		if pusher != nil {
+			pushCtx, cancel := context.WithTimeout(context.Background(), *pushIntervalPtr)
+			if err := pusher.Push(pushCtx); err != nil {
+				slog.Warn("pushgateway push failed", "error", err.Error())
+			}
+			cancel()
+		}
+		os.Exit(code)
+	}
+
 	healthHandler := internal.NewHealthHandler(watchdog, *healthPathPtr, mountPointsSubpath)
+	healthHandler.SetMinHealthyCount(*minHealthyCountPtr)
+	healthHandler.SetUnknownStatus(*unknownStatusPtr, *startupGracePeriodPtr)
+
+	if *scrapeTimeChecksPtr {
+		registerer.MustRegister(internal.NewPresenceCollector(namespace, watchdog, *scrapeCheckCachePtr, *scrapeCheckTimeoutPtr))
+	}
+	if *enableMountStatsPtr {
+		registerer.MustRegister(internal.NewMountStatsCollector(namespace, watchdog))
+	}
+
+	if discoverer != nil {
+		go discoverer.Run(ctx)
+	}
+
+	watchdog.OnStateChange(internal.NewHookRunner(namespace, watchdog, *hookTimeoutPtr).Run)
+
+	if len(notifyURLs) > 0 {
+		notifier := internal.NewWebhookNotifier(namespace, notifyURLs, *notifyTimeoutPtr, *notifyRetriesPtr, *notifyQueueSizePtr, registerer)
+		watchdog.OnStateChange(notifier.Notify)
+		go notifier.Run(ctx)
+	}
+
+	if *influxPushURLPtr != "" {
+		if *influxPushIntervalPtr <= 0 {
+			fatalf("invalid --influx-push-interval: %s", *influxPushIntervalPtr)
+		}
+		go internal.NewInfluxPusher(namespace, *influxPushURLPtr, *influxPushIntervalPtr, watchdog).Run(ctx)
+	}
+
+	if *otlpEndpointPtr != "" {
+		if *otlpIntervalPtr <= 0 {
+			fatalf("invalid --otlp-interval: %s", *otlpIntervalPtr)
+		}
+		endpoint, err := parseHTTPURL(*otlpEndpointPtr)
+		if err != nil {
+			fatalf("invalid --otlp-endpoint: %v", err)
+		}
+		go internal.NewOTLPExporter(namespace, endpoint, *otlpIntervalPtr, watchdog, registry).Run(ctx)
+	}
+
+	pushDone := make(chan struct{})
+	if pusher != nil {
+		watchdog.OnCheckCycle(pusher.Trigger)
+		go func() {
+			pusher.Run(ctx)
+			close(pushDone)
+		}()
+	} else {
+		close(pushDone)
+	}
 
-	go watchdog.Start(ctx)
+	if *eventsPathPtr != "" {
+		broadcaster := internal.NewEventBroadcaster(*eventsBufferPtr)
+		watchdog.OnStateChange(broadcaster.Publish)
+		// Long-lived stream, not subject to --http-timeout
+		handle(internal.AuthGroupStatus, *eventsPathPtr, broadcaster)
+	}
+
+	watchdogDone := make(chan struct{})
+	go func() {
+		watchdog.Start(ctx)
+		close(watchdogDone)
+	}()
+
+	var systemd *internal.SystemdNotifier
+	if *systemdNotifyPtr {
+		if systemd, err = internal.NewSystemdNotifier(); err != nil {
+			slog.Warn("systemd notifications disabled", "error", err.Error())
+		} else {
+			slog.Info("notifying systemd", "watchdog_timeout", systemd.WatchdogTimeout().String())
+			go systemd.Run(ctx, watchdog)
+		}
+	}
 
 	// HTTP handlers
-	http.Handle(*telemetryPathPtr, promhttp.Handler())
+	metricsHandler := promhttp.InstrumentMetricHandler(registerer, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	handle(internal.AuthGroupMetrics, telemetryPath, metricsHandler)
 
 	// Global health: all mount points must be healthy
-	http.HandleFunc(*healthPathPtr, healthHandler.HandleMain)
+	handle(internal.AuthGroupHealth, *healthPathPtr, internal.WithTimeout(http.HandlerFunc(healthHandler.HandleMain), *httpTimeoutPtr))
 
 	// Per-mount health: /health/mount-points/var/vcap/store/dir -> /var/vcap/store/dir
-	http.HandleFunc(*healthPathPtr+"/mount-points/", healthHandler.HandleMountPoints)
+	handle(internal.AuthGroupHealth, *healthPathPtr+"/mount-points/", internal.WithTimeout(http.HandlerFunc(healthHandler.HandleMountPoints), *httpTimeoutPtr))
+
+	// Readiness: ready, degraded (only optional mount points down) or not ready
+	if *readinessPathPtr != "" {
+		handle(internal.AuthGroupHealth, *readinessPathPtr, internal.WithTimeout(internal.NewReadinessHandler(watchdog, *degradedStatusPtr), *httpTimeoutPtr))
+	}
+
+	// Snapshot of all mount points: JSON, a text table for terminals or an HTML
+	// page for browsers, which find it from the root as well
+	if *statusPathPtr != "" {
+		handle(internal.AuthGroupStatus, *statusPathPtr, internal.WithTimeout(internal.NewStatusHandler(watchdog), *httpTimeoutPtr))
+		if *statusPathPtr != "/" {
+			router.Handle(internal.AuthGroupStatus, "/{$}", http.RedirectHandler(*statusPathPtr+"?format=html", http.StatusFound))
+		}
+	}
+
+	// Recent events for post-incident debugging
+	if *eventLogPathPtr != "" && watchdog.EventLog() != nil {
+		handle(internal.AuthGroupStatus, *eventLogPathPtr, internal.WithTimeout(watchdog.EventLog(), *httpTimeoutPtr))
+	}
 
-	log.Printf("Starting %s v%s on %s (metrics: %s, health: %s, per-mount health base: %s/%s...)",
-		programName, ProgramVersion, *listenAddressPtr, *telemetryPathPtr, *healthPathPtr, *healthPathPtr, mountPointsSubpath)
+	// Live probe of ad-hoc paths for Prometheus, bounded by its own timeout
+	if *probePathPtr != "" {
+		handle(internal.AuthGroupMetrics, *probePathPtr, internal.NewProbeHandler(namespace, watchdog, *probeTimeoutPtr))
+	}
 
-	if err := http.ListenAndServe(*listenAddressPtr, nil); err != nil {
-		log.Fatalf("cannot start server: %v", err)
+	// Config reload, by the admin API or SIGHUP, one at a time.
+	// Settings in effect, changes of those only apply after a restart.
+	running := &config.Config{ListenAddress: listenAddress, TelemetryPath: telemetryPath, TelemetryNamespace: namespace, MountBackend: mountBackendName}
+	var reloadMu sync.Mutex
+	reload := func() (*internal.ReloadResult, error) {
+		if *configPtr == "" {
+			return nil, errors.New("no config file (use --config)")
+		}
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		return reloadConfig(watchdog, *configPtr, mountPoints, explicit, running)
 	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for range hup {
+			result, err := reload()
+			if err != nil {
+				slog.Error("reload on SIGHUP failed", "error", err.Error())
+				continue
+			}
+			slog.Info("reloaded on SIGHUP", "added", result.Added, "removed", result.Removed, "updated", result.Updated,
+				"changed", result.Changed, "requires_restart", result.RequiresRestart)
+		}
+	}()
+
+	// Admin API, gated by a bearer token
+	if *adminTokenPtr != "" {
+		adminHandlers := internal.NewAdminHandlers(watchdog, reload)
+		router.Handle(internal.ListenGroupAdmin, "/admin/reload", internal.RequireBearerToken(internal.WithTimeout(http.HandlerFunc(adminHandlers.HandleReload), *httpTimeoutPtr), *adminTokenPtr))
+		router.Handle(internal.ListenGroupAdmin, "/admin/hold", internal.RequireBearerToken(internal.WithTimeout(http.HandlerFunc(adminHandlers.HandleHold), *httpTimeoutPtr), *adminTokenPtr))
+		router.Handle(internal.ListenGroupAdmin, "/admin/mounts", internal.RequireBearerToken(internal.WithTimeout(http.HandlerFunc(adminHandlers.HandleMounts), *httpTimeoutPtr), *adminTokenPtr))
+		mountPointsAPI := internal.RequireBearerToken(internal.WithTimeout(http.HandlerFunc(adminHandlers.HandleMountPoints), *httpTimeoutPtr), *adminTokenPtr)
+		router.Handle(internal.ListenGroupAdmin, internal.MountPointsAPIPath, mountPointsAPI)
+		router.Handle(internal.ListenGroupAdmin, internal.MountPointsAPIPath+"/", mountPointsAPI)
+	}
+
+	slog.Info("starting "+programName, "version", ProgramVersion, "listen", listens.String(), "tls", tlsConfig != nil, "metrics", telemetryPath,
+		"health", *healthPathPtr, "mount_point_health", *healthPathPtr+"/"+mountPointsSubpath)
+
+	// Closing a socket listener on shutdown removes its file.
+	servers := make([]*http.Server, len(listens))
+	for i, spec := range listens {
+		ln, err := internal.Listen(spec, os.FileMode(socketMode))
+		if err != nil {
+			fatalf("cannot listen on %s: %v", spec.Address, err)
+		}
+		server := &http.Server{
+			Addr:    spec.Address,
+			Handler: router.Mux(spec.Groups),
+			// Long-lived requests (/events) end when the agent stops.
+			BaseContext: func(net.Listener) context.Context { return ctx },
+			TLSConfig:   tlsConfig,
+		}
+		servers[i] = server
+		go func() {
+			var err error
+			if tlsConfig != nil && isTCP(spec) {
+				// The certificate comes from TLSConfig.GetCertificate.
+				err = server.ServeTLS(ln, "", "")
+			} else {
+				err = server.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatalf("cannot start server on %s: %v", server.Addr, err)
+			}
+		}()
+	}
+	if len(servers) == 0 {
+		slog.Info("HTTP server disabled, no listen address")
+	}
+
+	// gRPC health checking for service mesh probes, prior-knowledge HTTP/2 without TLS.
+	var grpcServer *http.Server
+	if *grpcHealthAddressPtr != "" {
+		grpcServer = &http.Server{
+			Addr:        *grpcHealthAddressPtr,
+			Handler:     internal.NewGRPCHealthServer(healthHandler),
+			BaseContext: func(net.Listener) context.Context { return ctx },
+			Protocols:   new(http.Protocols),
+		}
+		grpcServer.Protocols.SetUnencryptedHTTP2(true)
+		slog.Info("starting gRPC health service", "listen_address", *grpcHealthAddressPtr)
+		go func() {
+			if err := grpcServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatalf("cannot start gRPC health server: %v", err)
+			}
+		}()
+	}
+
+	<-signalCtx.Done()
+	// A second signal terminates immediately.
+	stopSignals()
+
+	if systemd != nil {
+		if err := systemd.Notify("STOPPING=1"); err != nil {
+			slog.Warn("cannot notify systemd", "state", "STOPPING=1", "error", err.Error())
+		}
+	}
+
+	// Drain: /health reports 503 so load balancers stop routing, checks keep running.
+	slog.Info("shutdown requested, draining", "drain_timeout", drainTimeoutPtr.String())
+	watchdog.SetDraining()
+	time.Sleep(*drainTimeoutPtr)
+
+	// Stop the watchdog, letting an in-flight check cycle finish.
+	cancel()
+	select {
+	case <-watchdogDone:
+	case <-time.After(shutdownTimeout):
+		slog.Warn("in-flight checks did not finish", "timeout", shutdownTimeout.String())
+	}
+	// Final Pushgateway push or group deletion.
+	select {
+	case <-pushDone:
+	case <-time.After(shutdownTimeout):
+		slog.Warn("final pushgateway push did not finish", "timeout", shutdownTimeout.String())
+	}
+	if *unmountOnShutdownPtr {
+		watchdog.UnmountAll()
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("HTTP server shutdown failed", "listen_address", server.Addr, "error", err.Error())
+		}
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("gRPC health server shutdown failed", "error", err.Error())
+		}
+	}
+	slog.Info(programName + " stopped")
+}
+
+// fatalExitCode is the exit code of startup errors.
+var fatalExitCode = 1
+
+// fatalf logs a startup error and exits.
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(fatalExitCode)
 }